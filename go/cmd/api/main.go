@@ -1,29 +1,105 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"io/fs"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/logger"
 
 	"github.com/go-goal/tagger/internal/api"
+	"github.com/go-goal/tagger/internal/artifacts"
 )
 
 func main() {
+	configFlag := flag.String("config", "", "config file (default is $TAGGER_CONFIG or ./config.yaml)")
+	flag.Parse()
+
+	configPath, err := findConfig(*configFlag)
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+	if err := api.LoadConfig(configPath); err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+	serverCfg := api.Config().Server
+
 	// Create a new Fiber app
-	app := fiber.New()
+	app := fiber.New(fiber.Config{
+		ReadTimeout:  serverCfg.ReadTimeout,
+		WriteTimeout: serverCfg.WriteTimeout,
+		IdleTimeout:  serverCfg.IdleTimeout,
+	})
+
+	// Log requests along with the authenticated client
+	app.Use(logger.New(logger.Config{
+		Format: "${time} | ${status} | ${latency} | ${ip} | ${method} | ${path} | client=${locals:client} | ${error}\n",
+	}))
 
 	// Setup routes
 	api.SetupRoutes(app)
+	log.Printf("Loaded categories %v from %s, version %q", api.Config().Categories, artifacts.Source(api.Config()), api.Tagger().Version())
 
 	// Start the server
 	port := os.Getenv("PORT")
+	if port == "" {
+		port = serverCfg.Port
+	}
 	if port == "" {
 		port = "8000"
 	}
 
-	log.Printf("Starting server on :%s", port)
-	if err := app.Listen(":" + port); err != nil {
-		log.Fatalf("Error starting server: %v", err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listenErr := make(chan error, 1)
+	go func() {
+		log.Printf("Starting server on :%s", port)
+		listenErr <- app.Listen(":" + port)
+	}()
+
+	select {
+	case err := <-listenErr:
+		if err != nil {
+			log.Fatalf("Error starting server: %v", err)
+		}
+		return
+	case <-ctx.Done():
+	}
+
+	// Stop accepting connections and let in-flight requests finish
+	log.Printf("Shutting down server, waiting up to %s for in-flight requests", serverCfg.ShutdownTimeout)
+	if err := app.ShutdownWithTimeout(serverCfg.ShutdownTimeout); err != nil {
+		log.Fatalf("Error shutting down server: %v", err)
+	}
+	log.Printf("Server stopped")
+}
+
+// findConfig returns the config file given by the flag or $TAGGER_CONFIG,
+// falling back to ./config.yaml. Binaries with embedded artifacts run with
+// the default config if there is none.
+func findConfig(configFlag string) (string, error) {
+	if configFlag != "" {
+		return configFlag, nil
+	}
+	if configPath := os.Getenv("TAGGER_CONFIG"); configPath != "" {
+		return configPath, nil
+	}
+
+	_, err := os.Stat("config.yaml")
+	switch {
+	case err == nil:
+		return "config.yaml", nil
+	case errors.Is(err, fs.ErrNotExist) && artifacts.Embedded():
+		return "", nil
+	case errors.Is(err, fs.ErrNotExist):
+		return "", errors.New("config.yaml not found in the working directory, pass -config or set TAGGER_CONFIG")
 	}
+	return "", err
 }
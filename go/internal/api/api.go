@@ -1,48 +1,219 @@
 package api
 
 import (
-	"bytes"
-	"encoding/csv"
+	"context"
+	"errors"
 	"fmt"
-	"path/filepath"
+	"log"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 
+	"github.com/go-goal/tagger/internal/artifacts"
+	"github.com/go-goal/tagger/internal/auth"
 	"github.com/go-goal/tagger/internal/config"
-	"github.com/go-goal/tagger/internal/model"
+	"github.com/go-goal/tagger/internal/feedback"
+	"github.com/go-goal/tagger/internal/index"
 	"github.com/go-goal/tagger/internal/tfidf"
+	"github.com/go-goal/tagger/pkg/corrections"
+	"github.com/go-goal/tagger/pkg/names"
+	"github.com/go-goal/tagger/pkg/overrides"
+	"github.com/go-goal/tagger/pkg/rules"
+	"github.com/go-goal/tagger/pkg/tagger"
+	"github.com/go-goal/tagger/pkg/utils"
 )
 
 var (
-	cfg       *config.Config
-	tfidfData tfidf.TfIdfData
+	cfg     *config.Config
+	tg      *tagger.Tagger
+	ruleSet *rules.RuleSet
+	// overridesWatcher holds the overrides reloaded on change, nil if none are configured
+	overridesWatcher *overrides.Watcher
+	// templates renders the room names, nil if no names file is configured
+	templates *names.Templates
+	// catalog is the reference catalog searched by /similar, nil if none is configured
+	catalog *index.Index
+	// tfidfData vectorizes the rate names paired by /match, nil if a
+	// constraint category is not predicted
+	tfidfData *tfidf.TfIdfData
 )
 
-func init() {
+// LoadConfig loads the API configuration from configPath, or the default
+// configuration if configPath is empty
+func LoadConfig(configPath string) error {
 	var err error
-	cfg, err = config.LoadConfig("config.yaml")
-	if err != nil {
-		panic(fmt.Sprintf("Error loading config: %v", err))
+	if configPath == "" {
+		cfg, err = config.Default()
+	} else {
+		cfg, err = config.LoadConfig(configPath)
 	}
+	return err
+}
 
-	tfidfFile := filepath.Join(cfg.ModelsDir, "tfidf", "tfidf_data.json")
-	tfidfData, err = tfidf.LoadTfIdfData(tfidfFile)
-	if err != nil {
-		panic(fmt.Sprintf("Error loading TF-IDF data: %v", err))
-	}
+// Config returns the configuration loaded by the API
+func Config() *config.Config {
+	return cfg
 }
 
+// Tagger returns the tagger loaded by SetupRoutes
+func Tagger() *tagger.Tagger {
+	return tg
+}
+
+// SetupRoutes loads the models of the configured categories once and
+// registers the API routes. LoadConfig must be called first.
 func SetupRoutes(app *fiber.App) {
+	var cache *tagger.Cache
+	if cfg.Cache.Size > 0 {
+		cache = tagger.NewCache(cfg.Cache.Size, cfg.Cache.TTL)
+	}
+	fsys, err := artifacts.FS(cfg)
+	if err != nil {
+		panic(fmt.Sprintf("Error loading models from %s: %v", artifacts.Source(cfg), err))
+	}
+	tg, err = tagger.NewContext(context.Background(), fsys, &tagger.Options{Categories: cfg.Categories, Cache: cache})
+	if err != nil {
+		panic(fmt.Sprintf("Error loading models from %s: %v", artifacts.Source(cfg), err))
+	}
+	if len(cfg.Categories) == 0 {
+		cfg.Categories = tg.Categories()
+	}
+	ruleSet, err = artifacts.Rules(fsys, cfg.Rules.File)
+	if err != nil {
+		panic(fmt.Sprintf("Error loading rules: %v", err))
+	}
+	overridesWatcher, err = artifacts.Overrides(fsys, cfg.Overrides.File)
+	if err != nil {
+		panic(fmt.Sprintf("Error loading overrides: %v", err))
+	}
+	templates, err = artifacts.Names(fsys, cfg.Names.File)
+	if err != nil {
+		panic(fmt.Sprintf("Error loading names: %v", err))
+	}
+	if templates != nil {
+		if _, err := templates.Resolve(cfg.Names.Locale); err != nil {
+			panic(fmt.Sprintf("Error loading names: %v", err))
+		}
+	}
+	if cfg.Similarity.Catalog != "" {
+		opts := index.DefaultOptions()
+		opts.Method = cfg.Similarity.Method
+		catalog, err = artifacts.Catalog(context.Background(), fsys, cfg.Similarity.Catalog, cfg.InputCol, cfg.Similarity.IDCol, &opts)
+		if err != nil {
+			panic(fmt.Sprintf("Error indexing catalog: %v", err))
+		}
+		log.Printf("Indexed %d catalog rate names (%s)", catalog.Len(), catalog.Method())
+	}
+	if missing := missingCategories(cfg.Matching.Constraints); len(missing) > 0 {
+		log.Printf("Not serving /match, the constraint categories %s are not predicted", strings.Join(missing, ", "))
+	} else {
+		tfidfData, err = artifacts.TfIdf(fsys)
+		if err != nil {
+			panic(fmt.Sprintf("Error loading TF-IDF data: %v", err))
+		}
+	}
+	labelsOf = artifacts.Labels(fsys)
+	if cfg.Corrections != "" {
+		store, err = corrections.Open(cfg.Corrections)
+		if err != nil {
+			panic(fmt.Sprintf("Error loading corrections: %v", err))
+		}
+	}
+	if cfg.Feedback != "" {
+		feedbackLog, err = feedback.Open(cfg.Feedback)
+		if err != nil {
+			panic(fmt.Sprintf("Error opening feedback log: %v", err))
+		}
+	}
+	if store != nil && cfg.CorrectionsReloadInterval > 0 {
+		go store.Watch(context.Background(), cfg.CorrectionsReloadInterval, func(n int, err error) {
+			if err != nil {
+				log.Printf("Error reloading corrections: %v", err)
+				return
+			}
+			log.Printf("Reloaded corrections, %d rate names corrected", n)
+		})
+	}
+	if overridesWatcher != nil && cfg.Overrides.ReloadInterval > 0 {
+		go overridesWatcher.Watch(context.Background(), cfg.Overrides.ReloadInterval, func(set *overrides.Set, err error) {
+			if err != nil {
+				log.Printf("Error reloading overrides, keeping version %q: %v", set.Version, err)
+				return
+			}
+			log.Printf("Reloaded overrides version %q", set.Version)
+		})
+	}
+
+	var authenticator *auth.Authenticator
+	if cfg.Auth.Enabled {
+		authenticator, err = auth.New(cfg.Auth)
+		if err != nil {
+			panic(fmt.Sprintf("Error configuring auth: %v", err))
+		}
+		// The metrics are labeled by client name, so they require a key too
+		app.Get("/metrics", authenticator.Middleware(), getMetrics)
+	} else {
+		app.Get("/metrics", getMetrics)
+	}
+
+	app.Use(observe)
+	app.Use(withDeadline)
+	if authenticator != nil {
+		app.Use(authenticator.Middleware())
+	}
+
 	app.Post("/predict", predictRateNames)
 	app.Post("/predict_csv", predictRateNamesCSV)
+	app.Post("/explain", explainRateName)
+	if store != nil {
+		app.Get("/corrections", getCorrections)
+		// Corrections override the predictions of every client, so they are
+		// only changed with a key allowed to
+		if cfg.Auth.Enabled {
+			app.Put("/corrections", auth.WriteCorrections, putCorrection)
+			app.Delete("/corrections", auth.WriteCorrections, deleteCorrection)
+			app.Post("/corrections/import", auth.WriteCorrections, importCorrections)
+		}
+	}
+	if feedbackLog != nil {
+		app.Post("/feedback", postFeedback)
+	}
+	if catalog != nil {
+		app.Post("/similar", similarRateNames)
+	}
+	if tfidfData != nil {
+		app.Post("/match", matchRateNames)
+	}
+
+	for _, route := range app.GetRoutes() {
+		routePaths[route.Path] = true
+	}
+}
+
+// missingCategories returns the categories that are not configured
+func missingCategories(categories []string) []string {
+	var missing []string
+	for _, category := range categories {
+		if utils.IndexOf(cfg.Categories, category) == -1 {
+			missing = append(missing, category)
+		}
+	}
+	return missing
 }
 
 type RateNameInput struct {
 	RateNames  []string `json:"inputs"`
 	Categories []string `json:"categories"`
+	// Locale of the room names, names.locale of the config if empty
+	Locale string `json:"locale"`
 }
 
 func predictRateNames(c *fiber.Ctx) error {
+	format, err := negotiateFormat(c, utils.FormatJSON)
+	if err != nil {
+		return sendError(c, err)
+	}
+
 	var input RateNameInput
 	if err := c.BodyParser(&input); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
@@ -60,88 +231,299 @@ func predictRateNames(c *fiber.Ctx) error {
 		input.Categories = cfg.Categories
 	}
 
-	// Load models and make predictions
-	cbmDir := filepath.Join(cfg.ModelsDir, "cbm")
-	labelsDir := filepath.Join(cfg.ModelsDir, "labels/json")
-	predictor := model.NewPredictor(&tfidfData, cbmDir, labelsDir, input.Categories)
-	err := predictor.LoadModels()
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	if err := checkPredict(input.Categories, input.Locale); err != nil {
+		return sendError(c, err)
+	}
+	if err := auth.ConsumeRows(c, len(cleanedRateNames)); err != nil {
+		return sendError(c, err)
 	}
 
-	results, err := predictor.PredictAll(cleanedRateNames)
+	results, err := predict(c, cleanedRateNames, input.Categories, input.Locale)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return sendError(c, err)
 	}
 
-	return c.JSON(results)
+	// JSON keeps the original shape keyed by rate name, other formats are tabular
+	if format == utils.FormatJSON {
+		return c.JSON(labelsByInput(results))
+	}
+
+	headers, rows := tagger.Table(cfg.InputCol, input.Categories, results)
+	if store != nil {
+		headers, rows = tagger.AppendSources(headers, rows, results)
+	}
+	if overridesWatcher != nil {
+		headers, rows = tagger.AppendOverrides(headers, rows, results)
+	}
+	if ruleSet != nil {
+		headers, rows = tagger.AppendViolations(headers, rows, results)
+	}
+	if templates != nil {
+		headers, rows = tagger.AppendRoomNames(headers, rows, results)
+	}
+	if cfg.Attributes {
+		headers, rows = tagger.AppendAttributes(headers, rows, results)
+	}
+	return sendRows(c, format, headers, rows, "")
 }
 
 func predictRateNamesCSV(c *fiber.Ctx) error {
-	file, err := c.FormFile("file")
+	format, err := negotiateFormat(c, utils.FormatCSV)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "File upload failed"})
+		return sendError(c, err)
 	}
 
-	fileContent, err := file.Open()
+	headers, records, err := readUpload(c)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to open file"})
+		return sendError(c, err)
 	}
-	defer fileContent.Close()
 
-	csvReader := csv.NewReader(fileContent)
-	records, err := csvReader.ReadAll()
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to parse CSV"})
+	if len(headers) == 0 || len(records) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "File must contain at least a header row and one data row"})
 	}
 
-	if len(records) < 2 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "CSV file must contain at least a header row and one data row"})
+	columns, err := selectColumns(headers, formParam(c, "input_col"), formList(c, "categories"), formParam(c, "passthrough") != "false")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	headers := records[0]
-	categories := headers[1:]
-	rateNames := make([]string, len(records)-1)
-	for i, record := range records[1:] {
-		if len(record) > 0 {
-			rateNames[i] = record[0]
+	rateNames := make([]string, len(records))
+	for i, record := range records {
+		if len(record) > columns.inputIndex {
+			rateNames[i] = record[columns.inputIndex]
 		}
 	}
 
-	// Load models and make predictions
-	cbmDir := filepath.Join(cfg.ModelsDir, "cbm")
-	labelsDir := filepath.Join(cfg.ModelsDir, "labels/json")
-	predictor := model.NewPredictor(&tfidfData, cbmDir, labelsDir, categories)
-	err = predictor.LoadModels()
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	if err := checkPredict(columns.categories, formParam(c, "locale")); err != nil {
+		return sendError(c, err)
+	}
+	if err := auth.ConsumeRows(c, len(rateNames)); err != nil {
+		return sendError(c, err)
 	}
 
-	results, err := predictor.PredictAll(rateNames)
+	results, err := predict(c, rateNames, columns.categories, formParam(c, "locale"))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return sendError(c, err)
+	}
+
+	headers, rows := columns.headers, columns.buildRows(records, results)
+	if store != nil {
+		headers, rows = tagger.AppendSources(headers, rows, results)
+	}
+	if overridesWatcher != nil {
+		headers, rows = tagger.AppendOverrides(headers, rows, results)
+	}
+	if ruleSet != nil {
+		headers, rows = tagger.AppendViolations(headers, rows, results)
+	}
+	if templates != nil {
+		headers, rows = tagger.AppendRoomNames(headers, rows, results)
+	}
+	if cfg.Attributes {
+		headers, rows = tagger.AppendAttributes(headers, rows, results)
+	}
+	return sendRows(c, format, headers, rows, "predictions")
+}
+
+// checkPredict reports unknown categories and locales as a bad request, for
+// the handlers to reject a request before its rows are charged to the quota
+func checkPredict(categories []string, locale string) error {
+	for _, category := range categories {
+		if _, err := tg.Labels(category); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("%v, known categories: %s", err, strings.Join(tg.Categories(), ", ")))
+		}
+	}
+	if templates != nil {
+		if locale == "" {
+			locale = cfg.Names.Locale
+		}
+		if _, err := templates.Resolve(locale); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+	}
+	return nil
+}
+
+// predict runs the shared tagger within the request context. Unknown
+// categories and locales are reported as a bad request.
+func predict(c *fiber.Ctx, rateNames []string, categories []string, locale string) ([]tagger.Result, error) {
+	opts := &tagger.PredictOptions{Categories: categories, Rules: ruleSet, Resolve: cfg.Rules.Resolve, Corrections: store, Attributes: cfg.Attributes}
+	if overridesWatcher != nil {
+		opts.Overrides = overridesWatcher.Current()
+	}
+	if templates != nil {
+		opts.Names = templates
+		opts.Locale = locale
+		if opts.Locale == "" {
+			opts.Locale = cfg.Names.Locale
+		}
+	}
+	results, err := tg.PredictBatch(c.UserContext(), rateNames, opts)
+	if errors.Is(err, tagger.ErrUnknownCategory) || errors.Is(err, names.ErrUnknownLocale) {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return results, err
+}
+
+// labelsByInput returns the predicted labels keyed by input as in the original
+// /predict response, with the source, the fired overrides, the violated rules,
+// the room name and the attribute columns under their column names if they
+// are configured
+func labelsByInput(results []tagger.Result) map[string]map[string]string {
+	labels := make(map[string]map[string]string, len(results))
+	for _, result := range results {
+		labels[result.Input] = result.Labels()
+		if store != nil {
+			labels[result.Input][tagger.SourceColumn] = result.Source()
+		}
+		if overridesWatcher != nil {
+			labels[result.Input][tagger.OverridesColumn] = result.FiredOverrides()
+		}
+		if ruleSet != nil {
+			labels[result.Input][tagger.ViolationsColumn] = result.ViolatedRules()
+		}
+		if templates != nil {
+			labels[result.Input][tagger.RoomNameColumn] = result.RoomName
+		}
+		if cfg.Attributes {
+			for column, value := range result.AttributeValues() {
+				labels[result.Input][column] = value
+			}
+		}
+	}
+	return labels
+}
+
+// withDeadline bounds the request context by the configured request timeout,
+// so predictions of abandoned requests stop consuming CPU
+func withDeadline(c *fiber.Ctx) error {
+	if cfg.Server.RequestTimeout <= 0 {
+		return c.Next()
 	}
 
-	// Create CSV from predictions
-	var buf bytes.Buffer
-	csvWriter := csv.NewWriter(&buf)
+	ctx, cancel := context.WithTimeout(c.UserContext(), cfg.Server.RequestTimeout)
+	defer cancel()
+	c.SetUserContext(ctx)
+	return c.Next()
+}
+
+// uploadColumns describes how the columns of an uploaded table map to the output
+type uploadColumns struct {
+	inputIndex int
+	categories []string
+	headers    []string
+	// sources holds for each output column the index of the uploaded column
+	// it is copied from, or -1 if it is a predicted category
+	sources []int
+}
+
+// selectColumns resolves the input column, the categories to predict and the
+// columns passed through unchanged. Without explicit categories every header
+// matching a configured category is predicted, and all configured categories
+// are predicted if none match. Headers that are neither the input column nor
+// a category are passed through, or rejected if passthrough is disabled.
+func selectColumns(headers []string, inputCol string, categories []string, passthrough bool) (*uploadColumns, error) {
+	if inputCol == "" {
+		inputCol = cfg.InputCol
+	}
+
+	columns := &uploadColumns{inputIndex: utils.IndexOf(headers, inputCol)}
+	if columns.inputIndex == -1 {
+		return nil, fmt.Errorf("input column '%s' not found, available columns: %s", inputCol, strings.Join(headers, ", "))
+	}
+
+	for _, category := range categories {
+		if utils.IndexOf(cfg.Categories, category) == -1 {
+			return nil, fmt.Errorf("unknown category '%s', known categories: %s", category, strings.Join(cfg.Categories, ", "))
+		}
+	}
+
+	if len(categories) == 0 {
+		for _, header := range headers {
+			if utils.IndexOf(cfg.Categories, header) != -1 {
+				categories = append(categories, header)
+			}
+		}
+	}
+	if len(categories) == 0 {
+		categories = cfg.Categories
+	}
+	columns.categories = categories
 
-	// Write header
-	csvWriter.Write(headers)
+	for i, header := range headers {
+		switch {
+		case i == columns.inputIndex:
+			columns.headers = append(columns.headers, header)
+			columns.sources = append(columns.sources, i)
+		case utils.IndexOf(categories, header) != -1:
+			columns.headers = append(columns.headers, header)
+			columns.sources = append(columns.sources, -1)
+		case passthrough:
+			columns.headers = append(columns.headers, header)
+			columns.sources = append(columns.sources, i)
+		case utils.IndexOf(cfg.Categories, header) != -1:
+			// Known category columns that were not requested are dropped
+		default:
+			return nil, fmt.Errorf("column '%s' does not match a known category (%s), enable passthrough to keep it", header, strings.Join(cfg.Categories, ", "))
+		}
+	}
 
-	// Write data
-	for _, rateName := range rateNames {
-		predictions := results[rateName]
-		row := make([]string, len(headers))
-		row[0] = rateName
-		for i, category := range categories {
-			row[i+1] = predictions[category]
+	// Requested categories missing from the upload are appended
+	for _, category := range categories {
+		if utils.IndexOf(headers, category) == -1 {
+			columns.headers = append(columns.headers, category)
+			columns.sources = append(columns.sources, -1)
 		}
-		csvWriter.Write(row)
 	}
-	csvWriter.Flush()
 
-	c.Set("Content-Type", "text/csv")
-	c.Set("Content-Disposition", "attachment; filename=predictions.csv")
-	return c.Send(buf.Bytes())
+	return columns, nil
+}
+
+func (u *uploadColumns) buildRows(records [][]string, results []tagger.Result) [][]string {
+	rows := make([][]string, len(records))
+	for i, record := range records {
+		row := make([]string, len(u.headers))
+		for j, source := range u.sources {
+			if source == -1 {
+				row[j] = results[i].Tags[u.headers[j]].Label
+			} else if source < len(record) {
+				row[j] = record[source]
+			}
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+// formParam returns a query parameter, falling back to a form field of the same name
+func formParam(c *fiber.Ctx, name string) string {
+	if value := c.Query(name); value != "" {
+		return value
+	}
+	return c.FormValue(name)
+}
+
+// formList returns a comma-separated or repeated parameter as a list
+func formList(c *fiber.Ctx, name string) []string {
+	var values []string
+	for _, value := range c.Context().QueryArgs().PeekMulti(name) {
+		values = append(values, string(value))
+	}
+	if len(values) == 0 {
+		if form, err := c.MultipartForm(); err == nil {
+			values = form.Value[name]
+		} else if value := c.FormValue(name); value != "" {
+			values = []string{value}
+		}
+	}
+
+	var list []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
 }
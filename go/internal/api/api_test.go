@@ -0,0 +1,160 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/go-goal/tagger/internal/auth"
+)
+
+// setupTestApp serves the test artifacts with the given config, appended to
+// their models_dir
+func setupTestApp(t *testing.T, config string) *fiber.App {
+	t.Helper()
+	modelsDir, err := filepath.Abs("../../testdata/artifacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := fmt.Sprintf("models_dir: %s\ninput_col: rate_name\ncategories: [class, view]\n%s", modelsDir, config)
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadConfig(configPath); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	SetupRoutes(app)
+	return app
+}
+
+func TestAuth(t *testing.T) {
+	type request struct {
+		method string
+		path   string
+		key    string
+		bearer string
+		body   string
+		// wantStatus is the expected status, wantRetry the Retry-After header
+		wantStatus int
+		wantRetry  string
+	}
+	const key = "secret"
+	predict := func(key string, inputs ...string) request {
+		body := fmt.Sprintf(`{"inputs": ["%s"]}`, strings.Join(inputs, `", "`))
+		return request{method: http.MethodPost, path: "/predict", key: key, body: body}
+	}
+	withStatus := func(r request, status int) request {
+		r.wantStatus = status
+		return r
+	}
+
+	tests := []struct {
+		name              string
+		requestsPerMinute int
+		rowsPerMinute     int
+		requests          []request
+	}{
+		{
+			name: "missing key",
+			requests: []request{
+				withStatus(predict("", "suite"), fiber.StatusUnauthorized),
+			},
+		},
+		{
+			name: "invalid key",
+			requests: []request{
+				withStatus(predict("wrong", "suite"), fiber.StatusUnauthorized),
+			},
+		},
+		{
+			name: "bearer key",
+			requests: []request{
+				{method: http.MethodPost, path: "/predict", bearer: key, body: `{"inputs": ["suite"]}`, wantStatus: fiber.StatusOK},
+			},
+		},
+		{
+			name: "metrics require a key",
+			requests: []request{
+				{method: http.MethodGet, path: "/metrics", wantStatus: fiber.StatusUnauthorized},
+				{method: http.MethodGet, path: "/metrics", key: key, wantStatus: fiber.StatusOK},
+			},
+		},
+		{
+			name:              "request quota",
+			requestsPerMinute: 1,
+			requests: []request{
+				withStatus(predict(key, "suite"), fiber.StatusOK),
+				{method: http.MethodPost, path: "/predict", key: key, body: `{"inputs": ["suite"]}`, wantStatus: fiber.StatusTooManyRequests, wantRetry: "60"},
+			},
+		},
+		{
+			name:          "row quota",
+			rowsPerMinute: 3,
+			requests: []request{
+				withStatus(predict(key, "suite", "room"), fiber.StatusOK),
+				{method: http.MethodPost, path: "/predict", key: key, body: `{"inputs": ["suite", "room"]}`, wantStatus: fiber.StatusTooManyRequests, wantRetry: "20"},
+			},
+		},
+		{
+			name:          "batch larger than the row quota",
+			rowsPerMinute: 2,
+			requests: []request{
+				withStatus(predict(key, "suite", "room", "suite vista"), fiber.StatusRequestEntityTooLarge),
+				withStatus(predict(key, "suite", "room"), fiber.StatusOK),
+			},
+		},
+		{
+			name:          "rows charged after validation",
+			rowsPerMinute: 2,
+			requests: []request{
+				{method: http.MethodPost, path: "/predict", key: key, body: `{"inputs": ["suite", "room"], "categories": ["bedding"]}`, wantStatus: fiber.StatusBadRequest},
+				{method: http.MethodPost, path: "/predict", key: key, body: `{"inputs": ["suite", "room"`, wantStatus: fiber.StatusBadRequest},
+				withStatus(predict(key, "suite", "room"), fiber.StatusOK),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := setupTestApp(t, fmt.Sprintf(`auth:
+  enabled: true
+  keys:
+    - name: test
+      key_sha256: %s
+      requests_per_minute: %d
+      rows_per_minute: %d
+`, auth.HashKey(key), tt.requestsPerMinute, tt.rowsPerMinute))
+
+			for i, r := range tt.requests {
+				req := httptest.NewRequest(r.method, r.path, strings.NewReader(r.body))
+				req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+				if r.key != "" {
+					req.Header.Set(auth.HeaderAPIKey, r.key)
+				}
+				if r.bearer != "" {
+					req.Header.Set(fiber.HeaderAuthorization, "Bearer "+r.bearer)
+				}
+				resp, err := app.Test(req, -1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != r.wantStatus {
+					t.Errorf("request %d %s %s: status %d, want %d", i, r.method, r.path, resp.StatusCode, r.wantStatus)
+				}
+				if retry := resp.Header.Get(fiber.HeaderRetryAfter); retry != r.wantRetry {
+					t.Errorf("request %d %s %s: Retry-After %q, want %q", i, r.method, r.path, retry, r.wantRetry)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/go-goal/tagger/internal/auth"
+	"github.com/go-goal/tagger/pkg/corrections"
+)
+
+var (
+	// store holds the human-verified tags, nil if no store is configured
+	store *corrections.Store
+	// labelsOf returns the labels of a category of the artifacts
+	labelsOf func(category string) ([]string, error)
+)
+
+type CorrectionInput struct {
+	RateName string            `json:"input"`
+	Tags     map[string]string `json:"tags"`
+}
+
+// getCorrections returns the correction of the input query parameter, or all corrections
+func getCorrections(c *fiber.Ctx) error {
+	input := c.Query("input")
+	if input == "" {
+		return c.JSON(fiber.Map{"corrections": store.List()})
+	}
+
+	correction, exists := store.Get(input)
+	if !exists {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("no correction of '%s'", input)})
+	}
+	return c.JSON(correction)
+}
+
+// putCorrection stores the verified tags of a rate name, keeping the
+// corrections of the categories it does not mention
+func putCorrection(c *fiber.Ctx) error {
+	var input CorrectionInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if strings.TrimSpace(input.RateName) == "" || len(input.Tags) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "input and tags are required"})
+	}
+
+	for category, label := range input.Tags {
+		known, err := labelsOf(category)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if !slices.Contains(known, label) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("unknown label '%s' of category %s, known labels: %s", label, category, strings.Join(known, ", "))})
+		}
+	}
+
+	err := store.Put(corrections.Correction{Input: input.RateName, Tags: input.Tags, Author: author(c)})
+	if err != nil {
+		return sendError(c, err)
+	}
+	correction, _ := store.Get(input.RateName)
+	return c.JSON(correction)
+}
+
+// deleteCorrection removes the correction of the input query parameter
+func deleteCorrection(c *fiber.Ctx) error {
+	input := c.Query("input")
+	if input == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "input is required"})
+	}
+
+	deleted, err := store.Delete(input)
+	if err != nil {
+		return sendError(c, err)
+	}
+	if !deleted {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("no correction of '%s'", input)})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// importCorrections stores the tags of an uploaded table shaped like the
+// training data. Nothing is stored if a label is unknown.
+func importCorrections(c *fiber.Ctx) error {
+	headers, records, err := readUpload(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	inputCol := formParam(c, "input_col")
+	if inputCol == "" {
+		inputCol = cfg.InputCol
+	}
+	imported, err := corrections.FromRows(headers, records, inputCol, labelsOf, author(c))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := store.Put(imported...); err != nil {
+		return sendError(c, err)
+	}
+	return c.JSON(fiber.Map{"imported": len(imported), "total": store.Len()})
+}
+
+// author returns the authenticated client making a correction
+func author(c *fiber.Ctx) string {
+	if client, ok := c.Locals(auth.LocalsClient).(string); ok && client != "" {
+		return "api:" + client
+	}
+	return "api"
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/go-goal/tagger/internal/auth"
+	"github.com/go-goal/tagger/pkg/tagger"
+)
+
+type ExplainInput struct {
+	RateName   string   `json:"input"`
+	Categories []string `json:"categories"`
+	// Top limits the contributions returned per category, zero returns all
+	Top int `json:"top"`
+}
+
+// explainRateName reports the n-grams that drove the label of every requested category
+func explainRateName(c *fiber.Ctx) error {
+	var input ExplainInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if strings.TrimSpace(input.RateName) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "input is required"})
+	}
+
+	if len(input.Categories) == 0 {
+		input.Categories = cfg.Categories
+	}
+
+	if err := checkPredict(input.Categories, ""); err != nil {
+		return sendError(c, err)
+	}
+	if err := auth.ConsumeRows(c, 1); err != nil {
+		return sendError(c, err)
+	}
+
+	explanations := make([]*tagger.Explanation, 0, len(input.Categories))
+	for _, category := range input.Categories {
+		explanation, err := tg.Explain(c.UserContext(), input.RateName, category)
+		if errors.Is(err, tagger.ErrUnknownCategory) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return sendError(c, err)
+		}
+
+		if input.Top > 0 && len(explanation.Contributions) > input.Top {
+			explanation.Contributions = explanation.Contributions[:input.Top]
+		}
+		explanations = append(explanations, explanation)
+	}
+
+	return c.JSON(fiber.Map{"input": input.RateName, "explanations": explanations})
+}
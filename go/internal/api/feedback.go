@@ -0,0 +1,70 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/go-goal/tagger/internal/auth"
+	"github.com/go-goal/tagger/internal/feedback"
+)
+
+// feedbackLog records the corrections sent to /feedback, nil if no feedback file is configured
+var feedbackLog *feedback.Log
+
+type FeedbackInput struct {
+	RateName  string            `json:"input"`
+	Predicted map[string]string `json:"predicted"`
+	Corrected map[string]string `json:"corrected"`
+}
+
+// postFeedback appends the corrected tags of a prediction to the feedback log
+// along with the client and the model version
+func postFeedback(c *fiber.Ctx) error {
+	var input FeedbackInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if strings.TrimSpace(input.RateName) == "" || len(input.Corrected) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "input and corrected are required"})
+	}
+
+	// The predicted tags end up in the training data as much as the corrected
+	// ones, so both are checked against the labels of the artifacts
+	for _, tags := range []map[string]string{input.Predicted, input.Corrected} {
+		if err := checkLabels(tags); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	client, _ := c.Locals(auth.LocalsClient).(string)
+	record := feedback.Record{
+		Time:         time.Now().UTC(),
+		Client:       client,
+		ModelVersion: tg.Version(),
+		Input:        input.RateName,
+		Predicted:    input.Predicted,
+		Corrected:    input.Corrected,
+	}
+	if err := feedbackLog.Append(record); err != nil {
+		return sendError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(record)
+}
+
+// checkLabels returns an error for a category or label unknown to the artifacts
+func checkLabels(tags map[string]string) error {
+	for category, label := range tags {
+		known, err := labelsOf(category)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(known, label) {
+			return fmt.Errorf("unknown label '%s' of category %s, known labels: %s", label, category, strings.Join(known, ", "))
+		}
+	}
+	return nil
+}
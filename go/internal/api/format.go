@@ -0,0 +1,132 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/go-goal/tagger/internal/auth"
+	"github.com/go-goal/tagger/pkg/utils"
+)
+
+// inputFormats lists the formats accepted as /predict_csv uploads
+var inputFormats = []string{utils.FormatCSV, utils.FormatTSV, utils.FormatJSON, utils.FormatJSONL}
+
+// negotiateFormat picks the response format from the `format` query parameter
+// or, if it is absent, from the Accept header. defaultFormat is used when the
+// client accepts anything.
+func negotiateFormat(c *fiber.Ctx, defaultFormat string) (string, error) {
+	if query := c.Query("format"); query != "" {
+		format, err := utils.ParseFormat(query)
+		if err != nil {
+			return "", fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("%v, expected one of: %s", err, strings.Join(utils.Formats, ", ")))
+		}
+		return format, nil
+	}
+
+	offers := utils.MediaTypes(defaultFormat)
+	for _, format := range utils.Formats {
+		if format != defaultFormat {
+			offers = append(offers, utils.MediaTypes(format)...)
+		}
+	}
+
+	accepted := c.Accepts(offers...)
+	if accepted == "" {
+		return "", fiber.NewError(fiber.StatusNotAcceptable, fmt.Sprintf("none of the accepted media types are supported, expected one of: %s", strings.Join(offers, ", ")))
+	}
+	return utils.FormatFromContentType(accepted), nil
+}
+
+// readUpload reads the uploaded table either from the multipart `file` field
+// or from the raw request body. The format is taken from the Content-Type of
+// the part or the request, falling back to the file extension and then CSV.
+func readUpload(c *fiber.Ctx) ([]string, [][]string, error) {
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		file, err := c.FormFile("file")
+		if err != nil {
+			return nil, nil, fiber.NewError(fiber.StatusBadRequest, "File upload failed")
+		}
+
+		format := utils.FormatFromContentType(file.Header.Get(fiber.HeaderContentType))
+		if format == "" {
+			format = utils.FormatFromFilename(file.Filename)
+		}
+		if format == "" {
+			format = utils.FormatCSV
+		}
+		if err := checkInputFormat(format); err != nil {
+			return nil, nil, err
+		}
+
+		fileContent, err := file.Open()
+		if err != nil {
+			return nil, nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to open file")
+		}
+		defer fileContent.Close()
+
+		headers, rows, err := utils.ReadRows(fileContent, format)
+		if err != nil {
+			return nil, nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Failed to parse %s: %v", strings.ToUpper(format), err))
+		}
+		return headers, rows, nil
+	}
+
+	format := utils.FormatFromContentType(c.Get(fiber.HeaderContentType))
+	if format == "" {
+		format = utils.FormatCSV
+	}
+	if err := checkInputFormat(format); err != nil {
+		return nil, nil, err
+	}
+
+	headers, rows, err := utils.ReadRows(bytes.NewReader(c.Body()), format)
+	if err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Failed to parse %s: %v", strings.ToUpper(format), err))
+	}
+	return headers, rows, nil
+}
+
+func checkInputFormat(format string) error {
+	for _, f := range inputFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fiber.NewError(fiber.StatusUnsupportedMediaType, fmt.Sprintf("unsupported upload format %s, expected one of: %s", format, strings.Join(inputFormats, ", ")))
+}
+
+// sendRows writes the table in the negotiated format. A non-empty filename
+// makes the response an attachment named after the format extension.
+func sendRows(c *fiber.Ctx, format string, headers []string, rows [][]string, filename string) error {
+	var buf bytes.Buffer
+	if err := utils.PrintRows(&buf, format, headers, rows); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	c.Set(fiber.HeaderContentType, utils.ContentType(format))
+	if filename != "" {
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%s.%s", filename, format))
+	}
+	return c.Send(buf.Bytes())
+}
+
+// sendError writes err as a JSON error response, keeping the status of fiber errors
+func sendError(c *fiber.Ctx, err error) error {
+	var quotaErr *auth.QuotaError
+	if errors.As(err, &quotaErr) {
+		return quotaErr.Send(c)
+	}
+
+	status := fiber.StatusInternalServerError
+	if e, ok := err.(*fiber.Error); ok {
+		status = e.Code
+	} else if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		status = fiber.StatusServiceUnavailable
+	}
+	return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/go-goal/tagger/internal/auth"
+	"github.com/go-goal/tagger/internal/index"
+	"github.com/go-goal/tagger/internal/mapping"
+	"github.com/go-goal/tagger/pkg/utils"
+)
+
+type MatchInput struct {
+	// A and B are the rate names of the two lists, identified by their
+	// position from 1
+	A []string `json:"a"`
+	B []string `json:"b"`
+	// MinScore is the smallest cosine similarity of a pair,
+	// matching.min_score of the config if zero
+	MinScore float64 `json:"min_score"`
+	// OneToOne pairs every rate name of B at most once, also if
+	// matching.one_to_one of the config is set
+	OneToOne bool `json:"one_to_one"`
+}
+
+// MatchPair is a pair of the /match response with the labels of the
+// constraint categories of its rate name of A
+type MatchPair struct {
+	A      index.Item        `json:"a"`
+	B      index.Item        `json:"b"`
+	Score  float64           `json:"score"`
+	Labels map[string]string `json:"labels"`
+}
+
+type MatchOutput struct {
+	Pairs      []MatchPair  `json:"pairs"`
+	UnmatchedA []index.Item `json:"unmatched_a"`
+	UnmatchedB []index.Item `json:"unmatched_b"`
+}
+
+// matchRateNames pairs the rate names of two lists describing the rooms of
+// the same hotel
+func matchRateNames(c *fiber.Ctx) error {
+	format, err := negotiateFormat(c, utils.FormatJSON)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	var input MatchInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	opts := mapping.DefaultOptions()
+	opts.Constraints = cfg.Matching.Constraints
+	opts.MinScore = cfg.Matching.MinScore
+	if input.MinScore != 0 {
+		opts.MinScore = input.MinScore
+	}
+	opts.OneToOne = cfg.Matching.OneToOne || input.OneToOne
+
+	if err := auth.ConsumeRows(c, len(input.A)+len(input.B)); err != nil {
+		return sendError(c, err)
+	}
+
+	a, labelsA, err := matchItems(c, input.A)
+	if err != nil {
+		return sendError(c, err)
+	}
+	b, labelsB, err := matchItems(c, input.B)
+	if err != nil {
+		return sendError(c, err)
+	}
+	m, err := mapping.Match(c.UserContext(), tfidfData, a, b, labelsA, labelsB, &opts)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	if format != utils.FormatJSON {
+		headers, rows := mapping.Table(a, b, labelsA, labelsB, opts.Constraints, m)
+		return sendRows(c, format, headers, rows, "")
+	}
+	output := MatchOutput{Pairs: []MatchPair{}, UnmatchedA: []index.Item{}, UnmatchedB: []index.Item{}}
+	for _, pair := range m.Pairs {
+		output.Pairs = append(output.Pairs, MatchPair{A: a[pair.A], B: b[pair.B], Score: pair.Score, Labels: labelsA[pair.A]})
+	}
+	for _, i := range m.UnmatchedA {
+		output.UnmatchedA = append(output.UnmatchedA, a[i])
+	}
+	for _, i := range m.UnmatchedB {
+		output.UnmatchedB = append(output.UnmatchedB, b[i])
+	}
+	return c.JSON(output)
+}
+
+// matchItems identifies rate names by their position from 1 and predicts the
+// labels of their constraint categories
+func matchItems(c *fiber.Ctx, rateNames []string) ([]index.Item, []map[string]string, error) {
+	items := make([]index.Item, len(rateNames))
+	for i, rateName := range rateNames {
+		items[i] = index.Item{ID: strconv.Itoa(i + 1), Name: rateName}
+	}
+	labels := make([]map[string]string, len(rateNames))
+	if len(cfg.Matching.Constraints) == 0 {
+		return items, labels, nil
+	}
+
+	results, err := predict(c, rateNames, cfg.Matching.Constraints, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, result := range results {
+		labels[i] = result.Labels()
+	}
+	return items, labels, nil
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/go-goal/tagger/internal/auth"
+	"github.com/go-goal/tagger/internal/metrics"
+)
+
+var requestsTotal = metrics.NewCounter("tagger_http_requests_total", "HTTP requests by client, route and status.", "client", "route", "status")
+
+// routePaths holds the registered route paths, other paths are counted as "other"
+var routePaths = map[string]bool{}
+
+// observe counts requests per client, route and response status
+func observe(c *fiber.Ctx) error {
+	err := c.Next()
+
+	status := c.Response().StatusCode()
+	if err != nil {
+		status = fiber.StatusInternalServerError
+		if e, ok := err.(*fiber.Error); ok {
+			status = e.Code
+		}
+	}
+
+	client := auth.Client(c)
+	if client == "" {
+		client = "anonymous"
+	}
+	route := c.Path()
+	if !routePaths[route] {
+		route = "other"
+	}
+	requestsTotal.Inc(client, route, strconv.Itoa(status))
+	return err
+}
+
+func getMetrics(c *fiber.Ctx) error {
+	var buf bytes.Buffer
+	if err := metrics.WriteText(&buf); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	c.Set(fiber.HeaderContentType, "text/plain; version=0.0.4")
+	return c.Send(buf.Bytes())
+}
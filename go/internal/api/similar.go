@@ -0,0 +1,64 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/go-goal/tagger/internal/auth"
+	"github.com/go-goal/tagger/internal/index"
+	"github.com/go-goal/tagger/pkg/utils"
+)
+
+// maxSimilar bounds the matches returned per rate name
+const maxSimilar = 100
+
+type SimilarInput struct {
+	RateNames []string `json:"inputs"`
+	// K is the number of matches per rate name, 5 if zero
+	K int `json:"k"`
+	// MinScore is the smallest cosine similarity of a match
+	MinScore float64 `json:"min_score"`
+}
+
+// similarRateNames returns the catalog rate names most similar to every input
+func similarRateNames(c *fiber.Ctx) error {
+	format, err := negotiateFormat(c, utils.FormatJSON)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	var input SimilarInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if input.K == 0 {
+		input.K = 5
+	}
+	if input.K < 1 || input.K > maxSimilar {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("k must be between 1 and %d", maxSimilar)})
+	}
+
+	if err := auth.ConsumeRows(c, len(input.RateNames)); err != nil {
+		return sendError(c, err)
+	}
+
+	matches, err := catalog.Search(c.UserContext(), input.RateNames, input.K, input.MinScore)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	// JSON is keyed by rate name as /predict, other formats are tabular
+	if format == utils.FormatJSON {
+		byInput := make(map[string][]index.Match, len(matches))
+		for i, rateName := range input.RateNames {
+			byInput[rateName] = matches[i]
+			if byInput[rateName] == nil {
+				byInput[rateName] = []index.Match{}
+			}
+		}
+		return c.JSON(byInput)
+	}
+	headers, rows := index.Table(cfg.InputCol, input.RateNames, matches)
+	return sendRows(c, format, headers, rows, "")
+}
@@ -0,0 +1,199 @@
+// Package artifacts reads the artifacts of the CLI and the API from the binary
+// they are embedded in, a configured bundle or the models directory.
+//
+// Binaries built with the `embed` build tag carry the bundle archive
+// internal/artifacts/artifacts.bundle, created with `tagger artifacts bundle`,
+// and ignore the configured artifacts locations.
+package artifacts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-goal/tagger/internal/config"
+	"github.com/go-goal/tagger/internal/index"
+	"github.com/go-goal/tagger/internal/tfidf"
+	"github.com/go-goal/tagger/pkg/bundle"
+	"github.com/go-goal/tagger/pkg/names"
+	"github.com/go-goal/tagger/pkg/overrides"
+	"github.com/go-goal/tagger/pkg/rules"
+	"github.com/go-goal/tagger/pkg/utils"
+)
+
+// Embedded reports whether the binary carries its own artifacts
+func Embedded() bool {
+	return len(embedded) > 0
+}
+
+// Source describes where Open loads the artifacts from
+func Source(cfg *config.Config) string {
+	switch {
+	case Embedded():
+		return "embedded bundle"
+	case cfg.Bundle != "":
+		return "bundle " + cfg.Bundle
+	}
+	return "directory " + cfg.ModelsDir
+}
+
+// FS returns the file system of the embedded bundle, the configured bundle
+// or the configured models directory, in that order of precedence. A bundle
+// is read and unpacked on every call, so the loaders below take the file
+// system opened once by the caller.
+func FS(cfg *config.Config) (fs.FS, error) {
+	switch {
+	case Embedded():
+		b, err := bundle.Read(embedded)
+		if err != nil {
+			return nil, fmt.Errorf("embedded artifacts: %w", err)
+		}
+		return b, nil
+	case cfg.Bundle != "":
+		return bundle.Open(cfg.Bundle)
+	}
+	return os.DirFS(cfg.ModelsDir), nil
+}
+
+// Labels returns a function reading the labels of a category from the
+// artifacts returned by FS, as expected by the Validate methods of rules and
+// overrides, so they may reference categories that are not predicted
+func Labels(fsys fs.FS) func(category string) ([]string, error) {
+	return func(category string) ([]string, error) {
+		return readLabels(fsys, category)
+	}
+}
+
+// Rules loads a consistency rules file and validates it against the labels
+// of the artifacts. It returns nil without a rules file.
+func Rules(fsys fs.FS, filePath string) (*rules.RuleSet, error) {
+	if filePath == "" {
+		return nil, nil
+	}
+
+	ruleSet, err := rules.Load(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := ruleSet.Validate(Labels(fsys)); err != nil {
+		return nil, fmt.Errorf("invalid rules in %s: %w", filePath, err)
+	}
+	return ruleSet, nil
+}
+
+// Overrides loads an overrides file into a watcher that validates every
+// version against the labels of the artifacts. It returns nil without an
+// overrides file.
+func Overrides(fsys fs.FS, filePath string) (*overrides.Watcher, error) {
+	if filePath == "" {
+		return nil, nil
+	}
+
+	labels := Labels(fsys)
+	return overrides.NewWatcher(filePath, func(set *overrides.Set) error {
+		return set.Validate(labels)
+	})
+}
+
+// Names loads a room name templates file and validates it against the labels
+// of the artifacts. It returns nil without a names file.
+func Names(fsys fs.FS, filePath string) (*names.Templates, error) {
+	if filePath == "" {
+		return nil, nil
+	}
+
+	templates, err := names.Load(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := templates.Validate(Labels(fsys)); err != nil {
+		return nil, fmt.Errorf("invalid names in %s: %w", filePath, err)
+	}
+	return templates, nil
+}
+
+// Catalog reads the rate names of the nameCol column of a catalog file, with
+// the ids of the idCol column or their row number from 1 if idCol is empty,
+// and indexes them with the TF-IDF data of the artifacts
+func Catalog(ctx context.Context, fsys fs.FS, filePath, nameCol, idCol string, opts *index.Options) (*index.Index, error) {
+	items, err := ReadCatalog(filePath, nameCol, idCol)
+	if err != nil {
+		return nil, err
+	}
+	tfidfData, err := TfIdf(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return index.New(ctx, tfidfData, items, opts)
+}
+
+// TfIdf reads the TF-IDF data of the artifacts returned by FS without loading
+// the models
+func TfIdf(fsys fs.FS) (*tfidf.TfIdfData, error) {
+	tfidfData, err := tfidf.LoadTfIdfDataFS(fsys, bundle.TfIdfPath)
+	if err != nil {
+		return nil, err
+	}
+	return &tfidfData, nil
+}
+
+// ReadCatalog reads the entries of a catalog file as described by Catalog.
+// Rows with an empty rate name are skipped.
+func ReadCatalog(filePath, nameCol, idCol string) ([]index.Item, error) {
+	format := utils.FormatFromFilename(filePath)
+	if format == "" {
+		format = utils.FormatCSV
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open catalog: %v", err)
+	}
+	defer file.Close()
+	headers, rows, err := utils.ReadRows(file, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog: %v", err)
+	}
+
+	nameIndex := utils.IndexOf(headers, nameCol)
+	if nameIndex == -1 {
+		return nil, fmt.Errorf("column %s not found in catalog %s", nameCol, filePath)
+	}
+	idIndex := -1
+	if idCol != "" {
+		if idIndex = utils.IndexOf(headers, idCol); idIndex == -1 {
+			return nil, fmt.Errorf("column %s not found in catalog %s", idCol, filePath)
+		}
+	}
+
+	items := make([]index.Item, 0, len(rows))
+	for i, row := range rows {
+		item := index.Item{ID: strconv.Itoa(i + 1)}
+		if nameIndex < len(row) {
+			item.Name = row[nameIndex]
+		}
+		if idIndex != -1 && idIndex < len(row) {
+			item.ID = row[idIndex]
+		}
+		if strings.TrimSpace(item.Name) != "" {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func readLabels(fsys fs.FS, category string) ([]string, error) {
+	content, err := fs.ReadFile(fsys, bundle.LabelsPath(category))
+	if err != nil {
+		return nil, fmt.Errorf("unknown category %s: %w", category, err)
+	}
+
+	var labels []string
+	if err := json.Unmarshal(content, &labels); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal labels of category %s: %v", category, err)
+	}
+	return labels, nil
+}
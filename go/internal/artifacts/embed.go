@@ -0,0 +1,8 @@
+//go:build embed
+
+package artifacts
+
+import _ "embed"
+
+//go:embed artifacts.bundle
+var embedded []byte
@@ -0,0 +1,143 @@
+package artifacts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"time"
+
+	cb "github.com/go-goal/tagger/internal/catboost"
+	"github.com/go-goal/tagger/internal/linear"
+	"github.com/go-goal/tagger/internal/tfidf"
+	"github.com/go-goal/tagger/pkg/bundle"
+)
+
+// ModelInfo describes the deployed model of a category
+type ModelInfo struct {
+	Category string `json:"category"`
+	// Type is ModelCatboost or ModelLinear
+	Type            string          `json:"type"`
+	CatboostVersion string          `json:"catboost_version"`
+	ModelGUID       string          `json:"model_guid"`
+	TrainFinishTime string          `json:"train_finish_time"`
+	Params          json.RawMessage `json:"params"`
+	TreeCount       int             `json:"tree_count"`
+	Dimensions      int             `json:"dimensions"`
+	FloatFeatures   int             `json:"float_features"`
+	UsedFeatures    []UsedFeature   `json:"used_features"`
+	Labels          []string        `json:"labels"`
+}
+
+// Model types of ModelInfo
+const (
+	ModelCatboost = "catboost"
+	ModelLinear   = "linear"
+)
+
+// UsedFeature is a feature the model splits on, mapped back to its TF-IDF n-gram
+type UsedFeature struct {
+	Name string `json:"name"`
+	// Index is the TF-IDF vector index of the feature, -1 if it is unknown
+	Index int    `json:"index"`
+	NGram string `json:"ngram"`
+}
+
+// Inspect reads the catboost metadata of the model of a category, or the
+// training parameters of its linear model
+func Inspect(fsys fs.FS, category string) (*ModelInfo, error) {
+	modelPath, err := bundle.FindModel(fsys, category)
+	if err != nil {
+		return nil, err
+	}
+	modelContent, err := fs.ReadFile(fsys, modelPath)
+	if err != nil {
+		return nil, err
+	}
+	labelsContent, err := fs.ReadFile(fsys, bundle.LabelsPath(category))
+	if err != nil {
+		return nil, err
+	}
+	tfidfData, err := tfidf.LoadTfIdfDataFS(fsys, bundle.TfIdfPath)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ModelInfo{Category: category, Type: ModelCatboost}
+	if err := json.Unmarshal(labelsContent, &info.Labels); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal labels: %v", err)
+	}
+	if modelPath == bundle.LinearModelPath(category) {
+		return inspectLinear(info, modelContent, &tfidfData)
+	}
+
+	model, err := cb.LoadFullModelFromBuffer(modelContent)
+	if err != nil {
+		return nil, err
+	}
+
+	info.CatboostVersion = model.GetModelInfoValue(cb.MetaVersionInfo)
+	info.ModelGUID = model.GetModelInfoValue(cb.MetaModelGUID)
+	info.TrainFinishTime = model.GetModelInfoValue(cb.MetaTrainFinishTime)
+	if params := model.GetModelInfoValue(cb.MetaParams); json.Valid([]byte(params)) {
+		info.Params = json.RawMessage(params)
+	} else {
+		// Keep unparsable params readable as a JSON string
+		info.Params, _ = json.Marshal(params)
+	}
+	info.TreeCount = model.GetTreeCount()
+	info.Dimensions = model.GetDimensionsCount()
+	info.FloatFeatures = model.GetFloatFeaturesCount()
+
+	names, err := model.GetModelUsedFeaturesNames()
+	if err != nil {
+		return nil, err
+	}
+	ngrams := tfidf.Terms(&tfidfData)
+	for _, name := range names {
+		info.UsedFeatures = append(info.UsedFeatures, usedFeature(name, ngrams, &tfidfData))
+	}
+
+	return info, nil
+}
+
+// inspectLinear fills info from a linear model, whose used features are
+// those with a nonzero weight for some label
+func inspectLinear(info *ModelInfo, modelContent []byte, tfidfData *tfidf.TfIdfData) (*ModelInfo, error) {
+	model, err := linear.Parse(modelContent)
+	if err != nil {
+		return nil, err
+	}
+
+	info.Type = ModelLinear
+	info.TrainFinishTime = model.TrainedAt.Format(time.RFC3339)
+	info.Params, err = json.Marshal(model.Params)
+	if err != nil {
+		return nil, err
+	}
+	info.Dimensions = len(model.Labels)
+	info.FloatFeatures = model.Features
+
+	ngrams := tfidf.Terms(tfidfData)
+	for index := 0; index < model.Features; index++ {
+		for _, row := range model.Weights {
+			if row[index] != 0 {
+				info.UsedFeatures = append(info.UsedFeatures, usedFeature(strconv.Itoa(index), ngrams, tfidfData))
+				break
+			}
+		}
+	}
+	return info, nil
+}
+
+// usedFeature maps a feature name to its n-gram. Models trained on the bare
+// TF-IDF matrix name features by their index, others by the n-gram itself.
+func usedFeature(name string, ngrams []string, tfidfData *tfidf.TfIdfData) UsedFeature {
+	if index, err := strconv.Atoi(name); err == nil && index >= 0 && index < len(ngrams) {
+		return UsedFeature{Name: name, Index: index, NGram: ngrams[index]}
+	}
+	if index, exists := tfidfData.Vocabulary[name]; exists {
+		return UsedFeature{Name: name, Index: int(index), NGram: name}
+	}
+	return UsedFeature{Name: name, Index: -1}
+}
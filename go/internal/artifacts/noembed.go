@@ -0,0 +1,5 @@
+//go:build !embed
+
+package artifacts
+
+var embedded []byte
@@ -0,0 +1,234 @@
+package artifacts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"slices"
+	"sort"
+
+	cb "github.com/go-goal/tagger/internal/catboost"
+	"github.com/go-goal/tagger/internal/extract"
+	"github.com/go-goal/tagger/internal/linear"
+	"github.com/go-goal/tagger/internal/tfidf"
+	"github.com/go-goal/tagger/pkg/bundle"
+)
+
+// Checks run by Validate
+const (
+	CheckManifest   = "manifest"
+	CheckVocabulary = "vocabulary"
+	CheckFiles      = "files"
+	CheckLabels     = "labels"
+	CheckMetadata   = "metadata"
+	CheckDimensions = "dimensions"
+	CheckFeatures   = "features"
+)
+
+// Issue is a failed check of the artifacts. Category is empty for checks of
+// the shared TF-IDF data and manifest.
+type Issue struct {
+	Category string `json:"category,omitempty"`
+	Check    string `json:"check"`
+	Message  string `json:"message"`
+}
+
+func (i Issue) String() string {
+	if i.Category == "" {
+		return fmt.Sprintf("%s: %s", i.Check, i.Message)
+	}
+	return fmt.Sprintf("category %s: %s: %s", i.Category, i.Check, i.Message)
+}
+
+// Report lists the issues found by Validate
+type Report struct {
+	Categories []string `json:"categories"`
+	Issues     []Issue  `json:"issues"`
+}
+
+// Valid reports whether no check failed
+func (r *Report) Valid() bool {
+	return len(r.Issues) == 0
+}
+
+func (r *Report) add(category, check string, format string, args ...any) {
+	r.Issues = append(r.Issues, Issue{Category: category, Check: check, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks that the artifacts of the given categories are consistent
+// with each other, so mismatches surface before prediction time:
+//   - the manifest, if any, matches the checksums of the artifacts
+//   - the TF-IDF vocabulary indices are unique and within the IDF values
+//   - every category has a model and a non-empty labels file, equal to the
+//     vocabulary of its extractor if it has one, whose patterns alone serve
+//     it without a model
+//   - the catboost metadata of every model is readable
+//   - the model dimensions match the labels, one dimension for two labels
+//   - the model float features match the TF-IDF vocabulary
+//
+// Linear models are checked for the same labels and features.
+func Validate(fsys fs.FS, categories []string) *Report {
+	report := &Report{Categories: categories}
+
+	manifest, err := bundle.ReadManifest(fsys)
+	if err == nil {
+		err = manifest.Verify(fsys)
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		for _, e := range unjoin(err) {
+			report.add("", CheckManifest, "%v", e)
+		}
+	}
+
+	// Feature counts are only checked against readable TF-IDF data
+	var vectorizer *tfidf.TfIdfData
+	tfidfData, err := tfidf.LoadTfIdfDataFS(fsys, bundle.TfIdfPath)
+	if err != nil {
+		report.add("", CheckVocabulary, "%v", err)
+	} else {
+		vectorizer = &tfidfData
+		validateVocabulary(report, vectorizer)
+	}
+
+	for _, category := range categories {
+		validateCategory(report, fsys, category, vectorizer)
+	}
+
+	return report
+}
+
+func validateVocabulary(report *Report, tfidfData *tfidf.TfIdfData) {
+	if len(tfidfData.Vocabulary) != len(tfidfData.IdfValues) {
+		report.add("", CheckVocabulary, "%d vocabulary terms but %d IDF values", len(tfidfData.Vocabulary), len(tfidfData.IdfValues))
+	}
+
+	sorted := make([]string, 0, len(tfidfData.Vocabulary))
+	for term := range tfidfData.Vocabulary {
+		sorted = append(sorted, term)
+	}
+	sort.Strings(sorted)
+
+	terms := make(map[int32]string, len(tfidfData.Vocabulary))
+	for _, term := range sorted {
+		index := tfidfData.Vocabulary[term]
+		if index < 0 || int(index) >= len(tfidfData.IdfValues) {
+			report.add("", CheckVocabulary, "index %d of term %q is out of range [0, %d)", index, term, len(tfidfData.IdfValues))
+		} else if other, exists := terms[index]; exists {
+			report.add("", CheckVocabulary, "index %d is shared by terms %q and %q", index, other, term)
+		}
+		terms[index] = term
+	}
+
+	for i, idf := range tfidfData.IdfValues {
+		if math.IsNaN(float64(idf)) || math.IsInf(float64(idf), 0) || idf <= 0 {
+			report.add("", CheckVocabulary, "IDF value %d is %v", i, idf)
+		}
+	}
+}
+
+func validateCategory(report *Report, fsys fs.FS, category string, tfidfData *tfidf.TfIdfData) {
+	modelPath, modelErr := bundle.FindModel(fsys, category)
+	var modelContent []byte
+	if modelErr == nil {
+		modelContent, modelErr = fs.ReadFile(fsys, modelPath)
+	}
+	// The patterns alone serve a category of package extract without a model
+	_, extracted := extract.Lookup(category)
+	patternsOnly := extracted && errors.Is(modelErr, fs.ErrNotExist)
+	if patternsOnly {
+		modelErr = nil
+	}
+	if modelErr != nil {
+		report.add(category, CheckFiles, "%v", modelErr)
+	}
+	labelsContent, labelsErr := fs.ReadFile(fsys, bundle.LabelsPath(category))
+	if labelsErr != nil {
+		report.add(category, CheckFiles, "%v", labelsErr)
+	}
+	if modelErr != nil || labelsErr != nil {
+		return
+	}
+
+	var labels []string
+	if err := json.Unmarshal(labelsContent, &labels); err != nil {
+		report.add(category, CheckLabels, "%s is not a JSON array of strings: %v", bundle.LabelsPath(category), err)
+		return
+	}
+	if len(labels) < 2 {
+		report.add(category, CheckLabels, "%d labels, expected at least 2", len(labels))
+	}
+	if extractor, exists := extract.Lookup(category); exists && !slices.Equal(labels, extractor.Labels) {
+		report.add(category, CheckLabels, "labels %v do not match the extractor vocabulary %v", labels, extractor.Labels)
+	}
+	seen := make(map[string]bool, len(labels))
+	for _, label := range labels {
+		if seen[label] {
+			report.add(category, CheckLabels, "duplicate label %q", label)
+		}
+		seen[label] = true
+	}
+
+	if patternsOnly {
+		return
+	}
+	if modelPath == bundle.LinearModelPath(category) {
+		validateLinear(report, category, modelContent, labels, tfidfData)
+		return
+	}
+
+	model, err := cb.LoadFullModelFromBuffer(modelContent)
+	if err != nil {
+		report.add(category, CheckMetadata, "%v", err)
+		return
+	}
+
+	for _, key := range []string{cb.MetaVersionInfo, cb.MetaModelGUID, cb.MetaParams} {
+		if model.GetModelInfoValue(key) == "" {
+			report.add(category, CheckMetadata, "%s is missing", key)
+		}
+	}
+	if params := model.GetModelInfoValue(cb.MetaParams); params != "" && !json.Valid([]byte(params)) {
+		report.add(category, CheckMetadata, "%s is not valid JSON", cb.MetaParams)
+	}
+
+	// Binary models output a single logit
+	expected := len(labels)
+	if expected == 2 {
+		expected = 1
+	}
+	if dimensions := model.GetDimensionsCount(); dimensions != expected {
+		report.add(category, CheckDimensions, "model has %d dimensions for %d labels, expected %d", dimensions, len(labels), expected)
+	}
+
+	if features := model.GetFloatFeaturesCount(); tfidfData != nil && (features != len(tfidfData.Vocabulary) || features != len(tfidfData.IdfValues)) {
+		report.add(category, CheckFeatures, "model expects %d float features, TF-IDF has %d terms and %d IDF values", features, len(tfidfData.Vocabulary), len(tfidfData.IdfValues))
+	}
+	if features := model.GetCatFeaturesCount(); features != 0 {
+		report.add(category, CheckFeatures, "model expects %d categorical features, expected none", features)
+	}
+}
+
+func validateLinear(report *Report, category string, modelContent []byte, labels []string, tfidfData *tfidf.TfIdfData) {
+	model, err := linear.Parse(modelContent)
+	if err != nil {
+		report.add(category, CheckMetadata, "%v", err)
+		return
+	}
+
+	if !slices.Equal(model.Labels, labels) {
+		report.add(category, CheckDimensions, "linear model labels %v do not match %s", model.Labels, bundle.LabelsPath(category))
+	}
+	if tfidfData != nil && (model.Features != len(tfidfData.Vocabulary) || model.Features != len(tfidfData.IdfValues)) {
+		report.add(category, CheckFeatures, "model expects %d features, TF-IDF has %d terms and %d IDF values", model.Features, len(tfidfData.Vocabulary), len(tfidfData.IdfValues))
+	}
+}
+
+// unjoin splits an error created with errors.Join
+func unjoin(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
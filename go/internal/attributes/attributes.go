@@ -0,0 +1,403 @@
+// Package attributes parses the numbers stated in rate names, such as bed
+// counts and sizes, room area, occupancy, bedroom count and floor, into typed
+// fields. Rate names are split into number and word tokens, which a small
+// grammar matches:
+//
+//	area      = number ("sqm" | "m" "2" | "sq" ("m" | "ft") | "square" ("meters" | "feet") | "sqft" | "ft" "2")
+//	bedrooms  = number ("bedroom" | "bedrooms" | "bdrm" | ...)
+//	beds      = [number] size ["size"] ["sofa"] ["bed" | "beds"] | number ("bed" | "beds")
+//	occupancy = number person {["+" | "and"] number child} | ("max" | "sleeps" | "up" "to") number | "for" number [person]
+//	floor     = number ("st" | "nd" | "rd" | "th") "floor" | ordinal "floor" | ("floor" | "level") number
+//
+// Numbers are digits or the words "one" to "ten", areas in square feet are
+// converted to square meters.
+package attributes
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-goal/tagger/internal/tfidf"
+)
+
+// Attributes are the numbers stated in a rate name, zero values are not stated
+type Attributes struct {
+	Beds []Bed `json:"beds,omitempty"`
+	// AreaM2 is the room area in square meters, rounded to 0.1
+	AreaM2    float64 `json:"area_m2,omitempty"`
+	Occupancy int     `json:"occupancy,omitempty"`
+	Bedrooms  int     `json:"bedrooms,omitempty"`
+	// Floor is the floor number, 0 for the ground floor
+	Floor *int `json:"floor,omitempty"`
+}
+
+// Bed is a number of beds of a type
+type Bed struct {
+	Count int `json:"count"`
+	// Type is one of the bed types, empty if the rate name only counts beds
+	Type string `json:"type,omitempty"`
+}
+
+func (b Bed) String() string {
+	if b.Type == "" {
+		return strconv.Itoa(b.Count)
+	}
+	return fmt.Sprintf("%d %s", b.Count, b.Type)
+}
+
+// Bed types
+const (
+	King   = "king"
+	Queen  = "queen"
+	Double = "double"
+	Single = "single"
+	Twin   = "twin"
+	Bunk   = "bunk"
+	Sofa   = "sofa"
+)
+
+// SquareFoot is the area of a square foot in square meters
+const SquareFoot = 0.09290304
+
+// token is a number or a lowercase word
+type token struct {
+	text   string
+	number float64
+	// isNumber is set for digits and number words
+	isNumber bool
+}
+
+// tokenPattern matches numbers with a decimal part or thousands separators,
+// words and plus signs, other characters separate tokens
+var tokenPattern = regexp.MustCompile(`\d{1,3}(?:,\d{3})+(?:\.\d+)?|\d+(?:[.,]\d+)?|[a-z]+|\+`)
+
+var numberWords = map[string]float64{
+	"one": 1, "two": 2, "three": 3, "four": 4, "five": 5,
+	"six": 6, "seven": 7, "eight": 8, "nine": 9, "ten": 10,
+}
+
+var ordinalWords = map[string]int{
+	"ground": 0, "first": 1, "second": 2, "third": 3, "fourth": 4, "fifth": 5,
+	"sixth": 6, "seventh": 7, "eighth": 8, "ninth": 9, "tenth": 10,
+}
+
+// tokenize splits the normalized input into tokens
+func tokenize(input string) []token {
+	matches := tokenPattern.FindAllString(tfidf.Preprocess(input), -1)
+	tokens := make([]token, len(matches))
+	for i, match := range matches {
+		tokens[i] = token{text: match}
+		if match[0] >= '0' && match[0] <= '9' {
+			value := match
+			if strings.Count(match, ",") == 1 && !strings.Contains(match, ".") && len(match)-strings.Index(match, ",") != 4 {
+				// A single comma followed by other than three digits is a decimal comma
+				value = strings.Replace(match, ",", ".", 1)
+			}
+			number, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", ""), 64)
+			if err == nil {
+				tokens[i].number, tokens[i].isNumber = number, true
+			}
+		} else if number, exists := numberWords[match]; exists {
+			tokens[i].number, tokens[i].isNumber = number, true
+		}
+	}
+	return tokens
+}
+
+// Words of the grammar
+var (
+	bedroomWords = wordSet("bedroom", "bedrooms", "bedrm", "bedrms", "bdrm", "bdrms", "bdr")
+	bedWords     = wordSet("bed", "beds")
+	sofaWords    = wordSet("sofa", "sofabed", "sofabeds")
+	sizeWords    = map[string]string{
+		"king": King, "kingsize": King, "queen": Queen, "queensize": Queen,
+		"double": Double, "french": Double, "full": Double, "single": Single,
+		"twin": Twin, "twins": Twin, "bunk": Bunk, "sofa": Sofa, "sofabed": Sofa, "sofabeds": Sofa,
+	}
+	personWords     = wordSet("adult", "adults", "person", "persons", "people", "guest", "guests", "pax", "pers", "ppl", "personnes", "personas", "personen")
+	childWords      = wordSet("child", "children", "childs", "kid", "kids", "infant", "infants")
+	floorWords      = wordSet("floor", "fl")
+	ordinalSuffixes = wordSet("st", "nd", "rd", "th")
+)
+
+func wordSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
+
+// parser matches the grammar rules at every token
+type parser struct {
+	tokens     []token
+	attributes Attributes
+	beds       map[string]int
+}
+
+// Parse returns the attributes stated in a rate name. Repeated mentions of
+// a bed type keep the largest count, occupancy keeps the largest value, and
+// the area, bedrooms and floor keep the first one.
+func Parse(input string) Attributes {
+	p := &parser{tokens: tokenize(input), beds: make(map[string]int)}
+	for i := 0; i < len(p.tokens); {
+		consumed := 0
+		for _, rule := range []func(int) int{p.area, p.bedrooms, p.occupancy, p.floor, p.bed} {
+			if consumed = rule(i); consumed > 0 {
+				break
+			}
+		}
+		i += max(consumed, 1)
+	}
+
+	types := make([]string, 0, len(p.beds))
+	for bedType := range p.beds {
+		types = append(types, bedType)
+	}
+	sort.Strings(types)
+	for _, bedType := range types {
+		p.attributes.Beds = append(p.attributes.Beds, Bed{Count: p.beds[bedType], Type: bedType})
+	}
+	return p.attributes
+}
+
+func (p *parser) text(i int) string {
+	if i >= 0 && i < len(p.tokens) {
+		return p.tokens[i].text
+	}
+	return ""
+}
+
+// integer returns the integer value of the token at i, false if it is not an
+// integer in [low, high]
+func (p *parser) integer(i, low, high int) (int, bool) {
+	if i >= len(p.tokens) || !p.tokens[i].isNumber {
+		return 0, false
+	}
+	number := p.tokens[i].number
+	if number != math.Trunc(number) || number < float64(low) || number > float64(high) {
+		return 0, false
+	}
+	return int(number), true
+}
+
+func (p *parser) area(i int) int {
+	if i >= len(p.tokens) || !p.tokens[i].isNumber {
+		return 0
+	}
+	number := p.tokens[i].number
+
+	var factor float64
+	consumed := 0
+	switch unit, next := p.text(i+1), p.text(i+2); {
+	case unit == "sqm" || unit == "sqmt" || unit == "sqmtr":
+		factor, consumed = 1, 2
+	case (unit == "m" || unit == "mt") && next == "2":
+		factor, consumed = 1, 3
+	case (unit == "sq" || unit == "square") && (next == "m" || next == "mt" || next == "meters" || next == "metres" || next == "meter" || next == "metre"):
+		factor, consumed = 1, 3
+	case unit == "sqft":
+		factor, consumed = SquareFoot, 2
+	case unit == "ft" && next == "2":
+		factor, consumed = SquareFoot, 3
+	case (unit == "sq" || unit == "square") && (next == "ft" || next == "feet" || next == "foot"):
+		factor, consumed = SquareFoot, 3
+	default:
+		return 0
+	}
+
+	area := math.Round(number*factor*10) / 10
+	if area < 3 || area > 5000 {
+		return 0
+	}
+	if p.attributes.AreaM2 == 0 {
+		p.attributes.AreaM2 = area
+	}
+	return consumed
+}
+
+func (p *parser) bedrooms(i int) int {
+	count, ok := p.integer(i, 1, 10)
+	if !ok || !bedroomWords[p.text(i+1)] {
+		return 0
+	}
+	if p.attributes.Bedrooms == 0 {
+		p.attributes.Bedrooms = count
+	}
+	return 2
+}
+
+func (p *parser) occupancy(i int) int {
+	var occupancy, consumed int
+	switch word := p.text(i); {
+	case word == "max" || word == "maximum":
+		j := i + 1
+		if next := p.text(j); next == "occupancy" || next == "occ" || next == "of" {
+			j++
+		}
+		count, ok := p.integer(j, 1, 30)
+		if !ok {
+			return 0
+		}
+		occupancy, consumed = count, j+1-i
+		if personWords[p.text(j+1)] {
+			consumed++
+		}
+	case word == "sleeps":
+		count, ok := p.integer(i+1, 1, 30)
+		if !ok {
+			return 0
+		}
+		occupancy, consumed = count, 2
+	case word == "up" && p.text(i+1) == "to":
+		count, ok := p.integer(i+2, 1, 30)
+		if !ok {
+			return 0
+		}
+		occupancy, consumed = count, 3
+	case word == "for":
+		count, ok := p.integer(i+1, 1, 30)
+		if !ok {
+			return 0
+		}
+		// "for 2" ends the rate name or counts persons, "for 4 nights" does not
+		if i+2 < len(p.tokens) && !personWords[p.text(i+2)] {
+			return 0
+		}
+		if personWords[p.text(i+2)] {
+			return 1
+		}
+		occupancy, consumed = count, 2
+	default:
+		count, ok := p.integer(i, 0, 30)
+		if !ok || !personWords[p.text(i+1)] {
+			return 0
+		}
+		occupancy, consumed = count, 2
+		// Children add to the adults
+		for j := i + 2; ; {
+			if next := p.text(j); next == "+" || next == "and" {
+				j++
+			}
+			children, ok := p.integer(j, 0, 30)
+			if !ok || !childWords[p.text(j+1)] {
+				break
+			}
+			occupancy += children
+			j += 2
+			consumed = j - i
+		}
+	}
+
+	if occupancy > 0 {
+		p.attributes.Occupancy = max(p.attributes.Occupancy, occupancy)
+	}
+	return consumed
+}
+
+func (p *parser) floor(i int) int {
+	floor, consumed := 0, 0
+	word := p.text(i)
+	switch {
+	case ordinalSuffixes[p.text(i+1)] && floorWords[p.text(i+2)]:
+		number, ok := p.integer(i, 1, 200)
+		if !ok {
+			return 0
+		}
+		floor, consumed = number, 3
+	case floorWords[p.text(i+1)]:
+		if number, exists := ordinalWords[word]; exists {
+			floor, consumed = number, 2
+		} else if number, ok := p.integer(i, 0, 200); ok && !p.tokens[i].isWord() {
+			floor, consumed = number, 2
+		} else {
+			return 0
+		}
+	case floorWords[word] || word == "level":
+		number, ok := p.integer(i+1, 0, 200)
+		// "high floor, 2 adults" states the occupancy, not the floor
+		if !ok || p.isUnit(i+2) {
+			return 0
+		}
+		floor, consumed = number, 2
+	default:
+		return 0
+	}
+
+	if p.attributes.Floor == nil {
+		p.attributes.Floor = &floor
+	}
+	return consumed
+}
+
+func (p *parser) bed(i int) int {
+	j := i
+	count, hasCount := p.integer(j, 1, 20)
+	if hasCount {
+		j++
+	}
+
+	bedType, hasSize := sizeWords[p.text(j)]
+	if hasSize {
+		j++
+		if next := p.text(j); next == "size" || next == "sized" {
+			j++
+		}
+		if sofaWords[p.text(j)] {
+			bedType = Sofa
+			j++
+		}
+	}
+	hasBed := bedWords[p.text(j)]
+	if hasBed {
+		j++
+	}
+
+	switch {
+	case hasCount && (hasSize || hasBed):
+	case !hasCount && hasSize && (hasBed || bedType == Sofa):
+		// "twin beds" are two beds
+		count = 1
+		if p.text(j-1) == "beds" {
+			count = 2
+		}
+	default:
+		return 0
+	}
+
+	// "1 double or 1 twin" offers alternatives, the first one is kept
+	if p.text(i-1) != "or" {
+		p.beds[bedType] = max(p.beds[bedType], count)
+	}
+	return j - i
+}
+
+// isUnit reports whether the token at i is a word counted by a number
+func (p *parser) isUnit(i int) bool {
+	word := p.text(i)
+	_, isSize := sizeWords[word]
+	return isSize || personWords[word] || childWords[word] || bedWords[word] || bedroomWords[word]
+}
+
+func (t token) isWord() bool {
+	_, exists := numberWords[t.text]
+	return exists
+}
+
+// BedCount returns the number of beds, sofa beds included
+func (a Attributes) BedCount() int {
+	count := 0
+	for _, bed := range a.Beds {
+		count += bed.Count
+	}
+	return count
+}
+
+// Empty reports whether no attribute was found
+func (a Attributes) Empty() bool {
+	return len(a.Beds) == 0 && a.AreaM2 == 0 && a.Occupancy == 0 && a.Bedrooms == 0 && a.Floor == nil
+}
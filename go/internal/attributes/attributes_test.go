@@ -0,0 +1,106 @@
+package attributes
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func floor(n int) *int {
+	return &n
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Attributes
+	}{
+		{"Standard Room", Attributes{}},
+		// Beds
+		{"Deluxe Room 2 Queen Beds", Attributes{Beds: []Bed{{2, Queen}}}},
+		{"Superior Room, 1 King Bed", Attributes{Beds: []Bed{{1, King}}}},
+		{"Double Room Two Double Beds", Attributes{Beds: []Bed{{2, Double}}}},
+		{"Standard Twin Beds", Attributes{Beds: []Bed{{2, Twin}}}},
+		{"King Size Bed", Attributes{Beds: []Bed{{1, King}}}},
+		{"Studio with Queen Sofa Bed", Attributes{Beds: []Bed{{1, Sofa}}}},
+		{"Family Room 1 King and 2 Single Beds", Attributes{Beds: []Bed{{1, King}, {2, Single}}}},
+		{"Room 1 Double or 2 Twin Beds", Attributes{Beds: []Bed{{1, Double}}}},
+		{"Dorm 4 Beds", Attributes{Beds: []Bed{{4, ""}}}},
+		{"Double Room", Attributes{}},
+		// Area
+		{"Superior Room 35 sqm", Attributes{AreaM2: 35}},
+		{"Studio 25,5 m2", Attributes{AreaM2: 25.5}},
+		{"Studio 28 m²", Attributes{AreaM2: 28}},
+		{"Suite 1,200 sq ft", Attributes{AreaM2: 111.5}},
+		{"Loft 450 sqft", Attributes{AreaM2: 41.8}},
+		{"Room 40 square meters, 30 sqm terrace", Attributes{AreaM2: 40}},
+		{"Room 2 m2", Attributes{}},
+		// Occupancy
+		{"Double Room 2 Adults", Attributes{Occupancy: 2}},
+		{"Family Room 2 Adults + 2 Children", Attributes{Occupancy: 4}},
+		{"Family Room 2 adults and 1 child", Attributes{Occupancy: 3}},
+		{"Villa Sleeps 6", Attributes{Occupancy: 6}},
+		{"Apartment Max 4 Persons", Attributes{Occupancy: 4}},
+		{"Apartment up to 5", Attributes{Occupancy: 5}},
+		{"Studio for 2", Attributes{Occupancy: 2}},
+		{"Package for 4 nights", Attributes{}},
+		// Bedrooms
+		{"Apartment 2 Bedrooms", Attributes{Bedrooms: 2}},
+		{"Three Bedroom Villa", Attributes{Bedrooms: 3}},
+		{"Penthouse 4 bdrm", Attributes{Bedrooms: 4}},
+		// Floor
+		{"Double Room 3rd Floor", Attributes{Floor: floor(3)}},
+		{"Suite 21st floor", Attributes{Floor: floor(21)}},
+		{"Room Ground Floor", Attributes{Floor: floor(0)}},
+		{"Room Second Floor", Attributes{Floor: floor(2)}},
+		{"Room Floor 12", Attributes{Floor: floor(12)}},
+		{"Room Level 5", Attributes{Floor: floor(5)}},
+		{"High floor, 2 adults", Attributes{Occupancy: 2}},
+		// Combined
+		{"Deluxe King Room, 1 King Bed, 32 sqm, 7th floor, 2 Adults", Attributes{Beds: []Bed{{1, King}}, AreaM2: 32, Occupancy: 2, Floor: floor(7)}},
+	}
+
+	for _, tt := range tests {
+		if got := Parse(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %s, want %s", tt.input, format(got), format(tt.want))
+		}
+	}
+}
+
+// format prints attributes with the floor dereferenced
+func format(a Attributes) string {
+	floor := "nil"
+	if a.Floor != nil {
+		floor = fmt.Sprint(*a.Floor)
+	}
+	return fmt.Sprintf("{Beds:%v AreaM2:%v Occupancy:%d Bedrooms:%d Floor:%s}", a.Beds, a.AreaM2, a.Occupancy, a.Bedrooms, floor)
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input string
+		tags  map[string]string
+		// want lists the categories in conflict
+		want []string
+	}{
+		{"Double Room 2 Adults", map[string]string{"capacity": "double"}, nil},
+		{"Double Room 3 Adults", map[string]string{"capacity": "double"}, []string{"capacity"}},
+		{"Double Room 3 Adults", map[string]string{"capacity": "undefined"}, nil},
+		{"Apartment 2 Bedrooms", map[string]string{"bedrooms": "1 bedroom"}, []string{"bedrooms"}},
+		{"Room 2 Queen Beds", map[string]string{"bedding": "twin/twin-or-double"}, nil},
+		{"Room 2 Queen Beds", map[string]string{"bedding": "double/double-or-twin"}, []string{"bedding"}},
+		{"Room 1 King Bed and Sofa Bed", map[string]string{"bedding": "double/double-or-twin"}, nil},
+		{"Room 1 Twin Bed", map[string]string{"bedding": "single bed"}, nil},
+		{"Triple Room 3 Adults, 1 King Bed", map[string]string{"capacity": "double", "bedding": "single bed"}, []string{"capacity", "bedding"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, conflict := range Parse(tt.input).Check(tt.tags) {
+			got = append(got, conflict.Category)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("conflicts of %q with %v = %v, want %v", tt.input, tt.tags, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package attributes
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Conflict is a predicted label contradicted by an attribute
+type Conflict struct {
+	Category string `json:"category"`
+	Label    string `json:"label"`
+	// Attribute is the JSON name of the contradicting attribute
+	Attribute string `json:"attribute"`
+	// Expected lists the labels consistent with the attribute
+	Expected []string `json:"expected"`
+}
+
+// capacityLabels are the capacity labels by occupancy
+var capacityLabels = map[int]string{1: "single", 2: "double", 3: "triple", 4: "quadruple", 6: "sextuple"}
+
+// Bedding labels
+const (
+	beddingBunk   = "bunk bed"
+	beddingDouble = "double/double-or-twin"
+	beddingSingle = "single bed"
+	beddingTwin   = "twin/twin-or-double"
+	beddingMulti  = "multiple"
+)
+
+// Check returns the conflicts of the attributes with the predicted labels of
+// the capacity, bedrooms and bedding categories. Empty and "undefined" labels,
+// and attributes without a corresponding label, are not checked.
+func (a Attributes) Check(tags map[string]string) []Conflict {
+	var conflicts []Conflict
+	check := func(category, attribute string, expected []string) {
+		label := tags[category]
+		if len(expected) == 0 || label == "" || label == "undefined" || slices.Contains(expected, label) {
+			return
+		}
+		conflicts = append(conflicts, Conflict{Category: category, Label: label, Attribute: attribute, Expected: expected})
+	}
+
+	if label, exists := capacityLabels[a.Occupancy]; exists {
+		check("capacity", "occupancy", []string{label})
+	}
+	if a.Bedrooms == 1 {
+		check("bedrooms", "bedrooms", []string{"1 bedroom"})
+	} else if a.Bedrooms >= 2 && a.Bedrooms <= 5 {
+		check("bedrooms", "bedrooms", []string{fmt.Sprintf("%d bedrooms", a.Bedrooms)})
+	}
+	check("bedding", "beds", a.bedding())
+	return conflicts
+}
+
+// bedding returns the bedding labels consistent with the beds, sofa beds
+// aside, none if the beds do not settle it
+func (a Attributes) bedding() []string {
+	count := 0
+	var types []string
+	for _, bed := range a.Beds {
+		if bed.Type == Sofa {
+			continue
+		}
+		if bed.Type == "" {
+			return nil
+		}
+		count += bed.Count
+		types = append(types, bed.Type)
+	}
+
+	switch {
+	case count == 0:
+		return nil
+	case slices.Contains(types, Bunk):
+		return []string{beddingBunk, beddingMulti}
+	case len(types) > 1 || count > 2:
+		return []string{beddingMulti}
+	case count == 2:
+		return []string{beddingTwin, beddingMulti}
+	case types[0] == Single:
+		return []string{beddingSingle}
+	case types[0] == Twin:
+		// A single twin bed is written for twin rooms as often as for single beds
+		return nil
+	}
+	return []string{beddingDouble}
+}
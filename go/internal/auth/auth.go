@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/go-goal/tagger/internal/config"
+	"github.com/go-goal/tagger/internal/metrics"
+)
+
+// HeaderAPIKey is the request header carrying the API key.
+// An `Authorization: Bearer <key>` header is accepted as well.
+const HeaderAPIKey = "X-API-Key"
+
+// LocalsClient is the fiber.Ctx locals key holding the authenticated client name
+const LocalsClient = "client"
+
+// localsQuota is the fiber.Ctx locals key holding the quotas of the authenticated client
+const localsQuota = "auth.quota"
+
+// Limit names used in responses and metrics
+const (
+	LimitRequests = "requests"
+	LimitRows     = "rows"
+)
+
+var (
+	rejectedRequests = metrics.NewCounter("tagger_auth_rejected_total", "Requests rejected for a missing or invalid API key.")
+	limitedRequests  = metrics.NewCounter("tagger_rate_limited_total", "Requests rejected by a per-client quota.", "client", "limit")
+	clientRows       = metrics.NewCounter("tagger_client_rows_total", "Rows accepted for prediction per client.", "client")
+)
+
+// Authenticator checks API keys and enforces per-key quotas
+type Authenticator struct {
+	clients map[string]*client
+}
+
+type client struct {
+	name             string
+	requests         *bucket
+	rows             *bucket
+	writeCorrections bool
+}
+
+// New creates an Authenticator from the configured keys
+func New(cfg config.AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{clients: make(map[string]*client, len(cfg.Keys))}
+	for i, key := range cfg.Keys {
+		if key.Name == "" {
+			return nil, fmt.Errorf("auth key %d has no name", i)
+		}
+		hash, err := hex.DecodeString(key.KeySHA256)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("auth key %s: key_sha256 must be a hex encoded SHA-256 hash", key.Name)
+		}
+		if _, exists := a.clients[string(hash)]; exists {
+			return nil, fmt.Errorf("auth key %s: duplicate key", key.Name)
+		}
+		a.clients[string(hash)] = &client{
+			name:             key.Name,
+			requests:         newBucket(key.RequestsPerMinute),
+			rows:             newBucket(key.RowsPerMinute),
+			writeCorrections: key.WriteCorrections,
+		}
+	}
+	return a, nil
+}
+
+// HashKey returns the hex SHA-256 hash of a key as expected in the config
+func HashKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// Middleware authenticates the request and applies the request quota of its key
+func (a *Authenticator) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(HeaderAPIKey)
+		if key == "" {
+			if bearer, found := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer "); found {
+				key = strings.TrimSpace(bearer)
+			}
+		}
+		if key == "" {
+			rejectedRequests.Inc()
+			return sendUnauthorized(c, "missing API key, provide it in the "+HeaderAPIKey+" header")
+		}
+
+		hash := sha256.Sum256([]byte(key))
+		cl, exists := a.clients[string(hash[:])]
+		if !exists {
+			rejectedRequests.Inc()
+			return sendUnauthorized(c, "invalid API key")
+		}
+
+		c.Locals(LocalsClient, cl.name)
+		c.Locals(localsQuota, cl)
+		if err := cl.take(LimitRequests, cl.requests, 1); err != nil {
+			return err.Send(c)
+		}
+		return c.Next()
+	}
+}
+
+// Client returns the name of the authenticated client or "" if the request is anonymous
+func Client(c *fiber.Ctx) string {
+	name, _ := c.Locals(LocalsClient).(string)
+	return name
+}
+
+// WriteCorrections rejects with 403 the requests whose key is not allowed to
+// change the correction store, to be registered after Middleware
+func WriteCorrections(c *fiber.Ctx) error {
+	cl, ok := c.Locals(localsQuota).(*client)
+	if !ok || !cl.writeCorrections {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "the API key is not allowed to change corrections, set write_corrections in its config",
+			"code":  "forbidden",
+		})
+	}
+	return c.Next()
+}
+
+// ConsumeRows charges rows to the row quota of the authenticated client, to
+// be called once the request is validated so that rejected requests cost
+// nothing. It returns a *QuotaError if the quota is exhausted and is a no-op
+// for anonymous requests.
+func ConsumeRows(c *fiber.Ctx, rows int) error {
+	cl, ok := c.Locals(localsQuota).(*client)
+	if !ok {
+		return nil
+	}
+	if err := cl.take(LimitRows, cl.rows, rows); err != nil {
+		return err
+	}
+	clientRows.Add(float64(rows), cl.name)
+	return nil
+}
+
+func (cl *client) take(limit string, b *bucket, n int) *QuotaError {
+	retryAfter, ok := b.take(float64(n), time.Now())
+	if ok {
+		return nil
+	}
+	limitedRequests.Inc(cl.name, limit)
+	return &QuotaError{Client: cl.name, Limit: limit, PerMinute: b.perMinute, RetryAfter: retryAfter, Requested: n}
+}
+
+// QuotaError reports an exhausted per-client quota
+type QuotaError struct {
+	Client     string
+	Limit      string
+	PerMinute  int
+	RetryAfter time.Duration
+	// Requested is the amount the request needed of the quota
+	Requested int
+}
+
+// TooLarge reports whether the request alone exceeds the quota, so that it
+// can never succeed however long the client waits
+func (e *QuotaError) TooLarge() bool {
+	return e.Requested > e.PerMinute
+}
+
+func (e *QuotaError) Error() string {
+	if e.TooLarge() {
+		return fmt.Sprintf("batch of %d %s exceeds the %s quota of %d per minute of client %s, split it into smaller requests", e.Requested, e.Limit, e.Limit, e.PerMinute, e.Client)
+	}
+	return fmt.Sprintf("%s quota of %d per minute exceeded for client %s", e.Limit, e.PerMinute, e.Client)
+}
+
+// Send writes the response for the error: 413 without Retry-After for a
+// request larger than the quota, 429 otherwise
+func (e *QuotaError) Send(c *fiber.Ctx) error {
+	if e.TooLarge() {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error":      e.Error(),
+			"code":       "batch_exceeds_quota",
+			"limit":      e.Limit,
+			"per_minute": e.PerMinute,
+			"requested":  e.Requested,
+		})
+	}
+
+	retryAfter := int(math.Ceil(e.RetryAfter.Seconds()))
+	if retryAfter > 0 {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+	}
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"error":       e.Error(),
+		"code":        "rate_limited",
+		"limit":       e.Limit,
+		"per_minute":  e.PerMinute,
+		"retry_after": retryAfter,
+	})
+}
+
+func sendUnauthorized(c *fiber.Ctx, message string) error {
+	c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		"error": message,
+		"code":  "unauthorized",
+	})
+}
+
+// bucket is a token bucket refilled continuously at perMinute tokens per minute
+type bucket struct {
+	mu        sync.Mutex
+	perMinute int
+	tokens    float64
+	last      time.Time
+}
+
+func newBucket(perMinute int) *bucket {
+	return &bucket{perMinute: perMinute, tokens: float64(perMinute)}
+}
+
+// take removes n tokens and returns true, or returns the time until n tokens are available.
+// Requests larger than the whole bucket are never allowed and wait for nothing.
+func (b *bucket) take(n float64, now time.Time) (time.Duration, bool) {
+	if b.perMinute <= 0 {
+		return 0, true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	capacity := float64(b.perMinute)
+	if !b.last.IsZero() {
+		b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Minutes()*capacity)
+	}
+	b.last = now
+
+	if n <= b.tokens {
+		b.tokens -= n
+		return 0, true
+	}
+	if n > capacity {
+		return 0, false
+	}
+	return time.Duration((n - b.tokens) / capacity * float64(time.Minute)), false
+}
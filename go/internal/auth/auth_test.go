@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucketTake(t *testing.T) {
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	type take struct {
+		after     time.Duration
+		n         float64
+		wantOK    bool
+		wantRetry time.Duration
+	}
+	tests := []struct {
+		name      string
+		perMinute int
+		takes     []take
+	}{
+		{
+			name:      "unlimited",
+			perMinute: 0,
+			takes: []take{
+				{0, 1000, true, 0},
+				{0, 1000, true, 0},
+			},
+		},
+		{
+			name:      "starts full",
+			perMinute: 60,
+			takes: []take{
+				{0, 60, true, 0},
+				{0, 1, false, time.Second},
+			},
+		},
+		{
+			name:      "refills continuously",
+			perMinute: 60,
+			takes: []take{
+				{0, 60, true, 0},
+				{10 * time.Second, 10, true, 0},
+				{10 * time.Second, 11, false, 11 * time.Second},
+			},
+		},
+		{
+			name:      "refills up to its capacity",
+			perMinute: 60,
+			takes: []take{
+				{0, 30, true, 0},
+				{time.Hour, 60, true, 0},
+				{time.Hour, 61, false, 0},
+			},
+		},
+		{
+			name:      "never allows more than its capacity",
+			perMinute: 10,
+			takes: []take{
+				{0, 11, false, 0},
+				{time.Hour, 11, false, 0},
+				{time.Hour, 10, true, 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBucket(tt.perMinute)
+			for i, step := range tt.takes {
+				retry, ok := b.take(step.n, start.Add(step.after))
+				if ok != step.wantOK || retry != step.wantRetry {
+					t.Errorf("take %d of %v after %v = (%v, %v), want (%v, %v)", i, step.n, step.after, retry, ok, step.wantRetry, step.wantOK)
+				}
+			}
+		})
+	}
+}
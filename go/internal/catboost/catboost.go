@@ -73,7 +73,6 @@ func initialization() error {
 
 	handle := C.dlopen(cName, C.RTLD_LAZY)
 	if handle == nil {
-		C.dlclose(handle)
 		msg := C.GoString(C.dlerror())
 		return fmt.Errorf("%w `%s`: %s", ErrLoadLibrary, catboostSharedLibraryPath, msg)
 	}
@@ -89,6 +88,7 @@ func initialization() error {
 	l.RegisterFn("GetFloatFeaturesCount")
 	l.RegisterFn("GetCatFeaturesCount")
 	l.RegisterFn("GetDimensionsCount")
+	l.RegisterFn("GetTreeCount")
 	l.RegisterFn("SetPredictionTypeString")
 	l.RegisterFn("GetModelUsedFeaturesNames")
 	l.RegisterFn("GetModelInfoValue")
@@ -124,6 +124,8 @@ func (l *library) RegisterFn(fnName string) {
 		C.SetSetPredictionTypeStringFn(fnC)
 	case "GetDimensionsCount":
 		C.SetGetDimensionsCountFn(fnC)
+	case "GetTreeCount":
+		C.SetGetTreeCountFn(fnC)
 	case "GetModelUsedFeaturesNames":
 		C.SetGetModelUsedFeaturesNamesFn(fnC)
 	case "GetModelInfoValue":
@@ -179,6 +181,10 @@ func LoadFullModelFromBuffer(buffer []byte) (*Model, error) {
 		return nil, err
 	}
 
+	if len(buffer) == 0 {
+		return nil, fmt.Errorf(formatErrorMessage, ErrLoadFullModelFromBuffer, "empty buffer")
+	}
+
 	handler := C.WrapModelCalcerCreate()
 
 	if !C.WrapLoadFullModelFromBuffer(handler, unsafe.Pointer(&buffer[0]), C.size_t(len(buffer))) {
@@ -222,16 +228,20 @@ func (m *Model) SetPredictionType(p PredictionType) error {
 
 // GetModelUsedFeaturesNames returns names of features used in the model.
 func (m *Model) GetModelUsedFeaturesNames() ([]string, error) {
-	featuresCount := m.GetFeaturesCount()
-
-	featuresC := C.makeCharArray1D(C.int(featuresCount))
-	defer C.freeCharArray1D(featuresC, C.int(featuresCount))
-
-	featuresCountC := C.size_t(featuresCount)
+	// The names and the array are allocated by CatBoost
+	var featuresC **C.char
+	var featuresCountC C.size_t
 	if !C.WrapGetModelUsedFeaturesNames(m.handler, &featuresC, &featuresCountC) {
 		return nil, fmt.Errorf(formatErrorMessage, ErrGetModelUsedFeaturesNames, GetError())
 	}
 
+	featuresCount := int(featuresCountC)
+	if featuresCount == 0 {
+		C.free(unsafe.Pointer(featuresC))
+		return []string{}, nil
+	}
+	defer C.freeCharArray1D(featuresC, C.int(featuresCount))
+
 	features := make([]string, 0, featuresCount)
 
 	// https://go.dev/wiki/cgo#turning-c-arrays-into-go-slices
@@ -264,6 +274,11 @@ func (m *Model) GetDimensionsCount() int {
 	return int(C.WrapGetDimensionsCount(m.handler))
 }
 
+// GetTreeCount returns number of trees in model.
+func (m *Model) GetTreeCount() int {
+	return int(C.WrapGetTreeCount(m.handler))
+}
+
 // GetRowResultSize return size row result.
 func (m *Model) GetRowResultSize() int {
 	if m.predictionType == Class {
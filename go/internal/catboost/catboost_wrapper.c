@@ -8,6 +8,7 @@ static TypeCalcModelPrediction CalcModelPredictionFn = NULL;
 static TypeGetFloatFeaturesCount GetFloatFeaturesCountFn = NULL;
 static TypeGetCatFeaturesCount GetCatFeaturesCountFn = NULL;
 static TypeGetDimensionsCount GetDimensionsCountFn = NULL;
+static TypeGetTreeCount GetTreeCountFn = NULL;
 static TypeSetPredictionTypeString SetPredictionTypeStringFn = NULL;
 static TypeGetModelUsedFeaturesNames GetModelUsedFeaturesNamesFn = NULL;
 static TypeGetModelInfoValue GetModelInfoValueFn = NULL;
@@ -72,6 +73,10 @@ size_t WrapGetDimensionsCount(ModelCalcerHandle *modelHandle) {
   return GetDimensionsCountFn(modelHandle);
 }
 
+size_t WrapGetTreeCount(ModelCalcerHandle *modelHandle) {
+  return GetTreeCountFn(modelHandle);
+}
+
 bool WrapSetPredictionTypeString(ModelCalcerHandle *modelHandle,
                                  const char *predictionTypeStr) {
   return SetPredictionTypeStringFn(modelHandle, predictionTypeStr);
@@ -122,6 +127,10 @@ void SetGetDimensionsCountFn(void *fn) {
   GetDimensionsCountFn = ((TypeGetDimensionsCount)fn);
 }
 
+void SetGetTreeCountFn(void *fn) {
+  GetTreeCountFn = ((TypeGetTreeCount)fn);
+}
+
 void SetSetPredictionTypeStringFn(void *fn) {
   SetPredictionTypeStringFn = ((TypeSetPredictionTypeString)fn);
 }
@@ -21,6 +21,7 @@ typedef bool (*TypeCalcModelPrediction)(ModelCalcerHandle *modelHandle,
 typedef size_t (*TypeGetFloatFeaturesCount)(ModelCalcerHandle *modelHandle);
 typedef size_t (*TypeGetCatFeaturesCount)(ModelCalcerHandle *modelHandle);
 typedef size_t (*TypeGetDimensionsCount)(ModelCalcerHandle *modelHandle);
+typedef size_t (*TypeGetTreeCount)(ModelCalcerHandle *modelHandle);
 typedef bool (*TypeSetPredictionTypeString)(ModelCalcerHandle *modelHandle,
                                             const char *predictionTypeStr);
 typedef bool (*TypeGetModelUsedFeaturesNames)(ModelCalcerHandle *modelHandle,
@@ -42,6 +43,7 @@ void SetCalcModelPredictionFn(void *fn);
 void SetGetFloatFeaturesCountFn(void *fn);
 void SetGetCatFeaturesCountFn(void *fn);
 void SetGetDimensionsCountFn(void *fn);
+void SetGetTreeCountFn(void *fn);
 void SetSetPredictionTypeStringFn(void *fn);
 void SetGetModelUsedFeaturesNamesFn(void *fn);
 void SetGetModelInfoValueFn(void *fn);
@@ -67,6 +69,7 @@ bool WrapCalcModelPrediction(ModelCalcerHandle *modelHandle, size_t docCount,
 size_t WrapGetFloatFeaturesCount(ModelCalcerHandle *modelHandle);
 size_t WrapGetCatFeaturesCount(ModelCalcerHandle *modelHandle);
 size_t WrapGetDimensionsCount(ModelCalcerHandle *modelHandle);
+size_t WrapGetTreeCount(ModelCalcerHandle *modelHandle);
 bool WrapSetPredictionTypeString(ModelCalcerHandle *modelHandle,
                                  const char *predictionTypeStr);
 bool WrapGetModelUsedFeaturesNames(ModelCalcerHandle *modelHandle,
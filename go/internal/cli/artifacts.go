@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/go-goal/tagger/internal/artifacts"
+	"github.com/go-goal/tagger/pkg/bundle"
+	"github.com/go-goal/tagger/pkg/utils"
+)
+
+var artifactsCmd = &cobra.Command{
+	Use:   "artifacts",
+	Short: "Manage model artifacts",
+}
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Pack the artifacts of a model release into a bundle archive",
+	Long: `Pack the TF-IDF data, models and labels of the models directory into a
+zip, tar or tar.gz archive along with a manifest of the categories, SHA-256
+checksums and vectorizer parameters. The archive can be set as "bundle" in the
+config or embedded into the binaries by copying it to
+internal/artifacts/artifacts.bundle and building with -tags embed.`,
+	Args: cobra.NoArgs,
+	Run:  runBundle,
+}
+
+func init() {
+	bundleCmd.Flags().StringP("output", "o", "", "Bundle file, the format is taken from the extension (.zip, .tar, .tar.gz, .tgz)")
+	bundleCmd.Flags().String("version", "", "Version of the model release")
+	bundleCmd.Flags().String("models-dir", "", "Artifacts directory (default is models_dir of the config)")
+	bundleCmd.Flags().StringSliceP("category", "c", []string{}, "Categories to include (default is the categories of the config)")
+	bundleCmd.MarkFlagRequired("output")
+	bundleCmd.MarkFlagRequired("version")
+
+	artifactsCmd.AddCommand(bundleCmd)
+}
+
+func runBundle(cmd *cobra.Command, args []string) {
+	outputFile, _ := cmd.Flags().GetString("output")
+	version, _ := cmd.Flags().GetString("version")
+	modelsDir, _ := cmd.Flags().GetString("models-dir")
+	bundleCategories, _ := cmd.Flags().GetStringSlice("category")
+
+	if modelsDir == "" {
+		modelsDir = cfg.ModelsDir
+	}
+	if len(bundleCategories) == 0 {
+		bundleCategories = cfg.Categories
+	}
+
+	format, err := bundle.FormatFromFilename(outputFile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	fsys := os.DirFS(modelsDir)
+	manifest, err := bundle.NewManifest(fsys, version, bundleCategories)
+	if err != nil {
+		fmt.Printf("Error building manifest: %v\n", err)
+		return
+	}
+
+	file, err := os.Create(outputFile)
+	if err != nil {
+		fmt.Printf("Error creating bundle: %v\n", err)
+		return
+	}
+
+	err = bundle.Write(file, format, fsys, manifest)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Printf("Error writing bundle: %v\n", err)
+		return
+	}
+
+	fmt.Printf("Wrote bundle %s version %s with categories %v\n", outputFile, manifest.Version, manifest.Categories)
+}
+
+var validateCmd = &cobra.Command{
+	Use:   "validate [models-dir-or-bundle]",
+	Short: "Check that the artifacts of the configured categories are consistent",
+	Long: `Check the models directory or bundle (default is the bundle or models_dir of
+the config) before deploying it: every category has a model and a labels
+file, the label count matches the model dimensions (one for binary models),
+the model float features match the TF-IDF vocabulary and IDF values, the
+vocabulary indices are in range, the catboost metadata is readable and an
+existing manifest matches the checksums.
+
+If the artifacts are valid and --manifest is given, a manifest with their
+SHA-256 checksums is written to it ("-" for stdout). Writing it to
+<models-dir>/manifest.json makes every later load verify the checksums.
+Exits with status 1 if a check fails.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runValidate,
+}
+
+func init() {
+	validateCmd.Flags().StringSliceP("category", "c", []string{}, "Categories to check (default is the categories of the bundle manifest or the config, or all with a model)")
+	validateCmd.Flags().String("manifest", "", "Write a manifest of the valid artifacts to this file, - for stdout")
+	validateCmd.Flags().String("version", "", "Version of the model release recorded in the manifest")
+
+	artifactsCmd.AddCommand(validateCmd)
+}
+
+func runValidate(cmd *cobra.Command, args []string) {
+	validateCategories, _ := cmd.Flags().GetStringSlice("category")
+	manifestFile, _ := cmd.Flags().GetString("manifest")
+	version, _ := cmd.Flags().GetString("version")
+
+	source := cfg.ModelsDir
+	if cfg.Bundle != "" {
+		source = cfg.Bundle
+	}
+	if len(args) == 1 {
+		source = args[0]
+	}
+
+	var fsys fs.FS
+	if utils.IsFile(source) {
+		b, err := bundle.Open(source)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fsys = b
+		if len(validateCategories) == 0 {
+			validateCategories = b.Manifest.Categories
+		}
+	} else {
+		fsys = os.DirFS(source)
+	}
+
+	if len(validateCategories) == 0 {
+		validateCategories = cfg.Categories
+	}
+	if len(validateCategories) == 0 {
+		var err error
+		validateCategories, err = bundle.Categories(fsys)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	report := artifacts.Validate(fsys, validateCategories)
+	for _, issue := range report.Issues {
+		fmt.Fprintf(os.Stderr, "FAIL %s\n", issue)
+	}
+	if !report.Valid() {
+		fmt.Fprintf(os.Stderr, "%s: %d problems in %d categories\n", source, len(report.Issues), len(report.Categories))
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "%s: %d categories OK\n", source, len(report.Categories))
+
+	if manifestFile == "" {
+		return
+	}
+
+	manifest, err := bundle.NewManifest(fsys, version, validateCategories)
+	if err != nil {
+		fmt.Printf("Error building manifest: %v\n", err)
+		os.Exit(1)
+	}
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		fmt.Printf("Error building manifest: %v\n", err)
+		os.Exit(1)
+	}
+	content = append(content, '\n')
+
+	if manifestFile == "-" {
+		os.Stdout.Write(content)
+		return
+	}
+	if err := os.WriteFile(manifestFile, content, 0o644); err != nil {
+		fmt.Printf("Error writing manifest: %v\n", err)
+		os.Exit(1)
+	}
+}
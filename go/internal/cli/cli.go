@@ -1,24 +1,37 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/go-goal/tagger/internal/artifacts"
+	"github.com/go-goal/tagger/internal/auth"
 	"github.com/go-goal/tagger/internal/config"
-	"github.com/go-goal/tagger/internal/model"
-	"github.com/go-goal/tagger/internal/tfidf"
+	"github.com/go-goal/tagger/pkg/corrections"
+	"github.com/go-goal/tagger/pkg/tagger"
 	"github.com/go-goal/tagger/pkg/utils"
 )
 
 var (
-	cfgFile    string
-	cfg        *config.Config
-	categories []string
+	cfgFile         string
+	cfg             *config.Config
+	categories      []string
+	bestEffort      bool
+	rulesFile       string
+	resolve         bool
+	overridesFile   string
+	correctionsFile string
+	withAttributes  bool
+	namesFile       string
+	locale          string
+	cacheFile       string
 )
 
 var rootCmd = &cobra.Command{
@@ -35,14 +48,30 @@ func Execute() error {
 func init() {
 	cobra.OnInitialize(initConfig)
 
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is ./config.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $TAGGER_CONFIG or ./config.yaml)")
 	rootCmd.Flags().StringP("input", "i", "", "Input CSV file containing strings to classify or a single string to classify")
 	rootCmd.Flags().StringP("output", "o", "", "Output CSV file for predictions")
 	rootCmd.Flags().StringSliceVarP(&categories, "category", "c", []string{}, "Categories to predict (can be specified multiple times)")
-	rootCmd.Flags().StringP("format", "f", "csv", "Output format (csv, json, tsv, yaml)")
+	rootCmd.Flags().StringP("format", "f", "csv", "Output format ("+strings.Join(utils.Formats, ", ")+")")
+	rootCmd.Flags().BoolVar(&bestEffort, "best-effort", false, "Output the categories that succeeded instead of failing when some categories fail")
+	rootCmd.Flags().StringVar(&rulesFile, "rules", "", "Consistency rules file (default is rules.file of the config)")
+	rootCmd.Flags().StringVar(&correctionsFile, "corrections", "", "Correction store file (default is corrections of the config)")
+	rootCmd.Flags().StringVar(&overridesFile, "overrides", "", "Override rules file (default is overrides.file of the config)")
+	rootCmd.Flags().BoolVar(&resolve, "resolve", false, "Resolve rule violations with the most likely consistent labels instead of only reporting them")
+	rootCmd.Flags().StringVar(&namesFile, "names", "", "Room name templates file (default is names.file of the config)")
+	rootCmd.Flags().StringVar(&cacheFile, "cache", "", "Prediction cache file loaded before and saved after predicting (default is cache.file of the config)")
+	rootCmd.Flags().StringVar(&locale, "locale", "", "Locale of the room names (default is names.locale of the config)")
+	rootCmd.Flags().BoolVar(&withAttributes, "attributes", false, "Add the bed counts, area, occupancy, bedroom count and floor stated in the rate names and the labels they contradict")
+
+	authCmd.AddCommand(hashKeyCmd)
+	rootCmd.AddCommand(authCmd)
+	rootCmd.AddCommand(artifactsCmd)
 }
 
 func initConfig() {
+	if cfgFile == "" {
+		cfgFile = os.Getenv("TAGGER_CONFIG")
+	}
 	if cfgFile != "" {
 		viper.SetConfigFile(cfgFile)
 	} else {
@@ -52,12 +81,22 @@ func initConfig() {
 
 	viper.AutomaticEnv()
 
-	if err := viper.ReadInConfig(); err != nil {
+	var err error
+	if err = viper.ReadInConfig(); err != nil {
+		// Binaries with embedded artifacts run without a config file
+		var notFound viper.ConfigFileNotFoundError
+		if errors.As(err, &notFound) && artifacts.Embedded() {
+			cfg, err = config.Default()
+			if err != nil {
+				fmt.Printf("Error loading config: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
 		fmt.Printf("Error reading config: %v\n", err)
 		os.Exit(1)
 	}
 
-	var err error
 	cfg, err = config.LoadConfig(viper.ConfigFileUsed())
 	if err != nil {
 		fmt.Printf("Error loading config: %v\n", err)
@@ -75,20 +114,23 @@ func runTagger(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	tfidfFile := filepath.Join(cfg.ModelsDir, "tfidf", "tfidf_data.json")
-	tfidfData, err := tfidf.LoadTfIdfData(tfidfFile)
+	outputFormat, err := utils.ParseFormat(outputFormat)
 	if err != nil {
-		fmt.Printf("Error loading TF-IDF data: %v\n", err)
+		fmt.Printf("Error: %v\n", err)
 		return
 	}
 
+	// Stop loading and predicting on interrupt
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Determine mode based on input
 	isFileMode := utils.IsFile(inputFile)
 
 	var inputStrings []string
 	if isFileMode {
 		// File mode: Read rate names from file
-		inputStrings, err = utils.ReadFirstCSVColumn(inputFile, cfg.InputCol)
+		inputStrings, err = utils.ReadColumn(inputFile, cfg.InputCol)
 		if err != nil {
 			fmt.Printf("Error reading rate names: %v\n", err)
 			return
@@ -103,45 +145,162 @@ func runTagger(cmd *cobra.Command, args []string) {
 		categories = cfg.Categories
 	}
 
-	// Load models and make predictions
-	cbmDir := filepath.Join(cfg.ModelsDir, "cbm")
-	labelsDir := filepath.Join(cfg.ModelsDir, "labels/json")
-	predictor := model.NewPredictor(&tfidfData, cbmDir, labelsDir, categories)
-	err = predictor.LoadModels()
+	fsys, err := artifacts.FS(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	if rulesFile == "" {
+		rulesFile = cfg.Rules.File
+	}
+	ruleSet, err := artifacts.Rules(fsys, rulesFile)
+	if err != nil {
+		fmt.Printf("Error loading rules: %v\n", err)
+		return
+	}
+
+	if overridesFile == "" {
+		overridesFile = cfg.Overrides.File
+	}
+	watcher, err := artifacts.Overrides(fsys, overridesFile)
 	if err != nil {
-		fmt.Printf("Error loading models: %v\n", err)
+		fmt.Printf("Error loading overrides: %v\n", err)
 		return
 	}
 
-	results, err := predictor.PredictAll(inputStrings)
+	if namesFile == "" {
+		namesFile = cfg.Names.File
+	}
+	if locale == "" {
+		locale = cfg.Names.Locale
+	}
+	templates, err := artifacts.Names(fsys, namesFile)
 	if err != nil {
+		fmt.Printf("Error loading names: %v\n", err)
+		return
+	}
+	if templates != nil {
+		if _, err := templates.Resolve(locale); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if correctionsFile == "" {
+		correctionsFile = cfg.Corrections
+	}
+	var store *corrections.Store
+	if correctionsFile != "" {
+		store, err = corrections.Open(correctionsFile)
+		if err != nil {
+			fmt.Printf("Error loading corrections: %v\n", err)
+			return
+		}
+		defer store.Close()
+	}
+
+	if cacheFile == "" {
+		cacheFile = cfg.Cache.File
+	}
+	// Within a run duplicates are predicted once anyway, the cache only pays
+	// off across runs
+	var cache *tagger.Cache
+	if cacheFile != "" && cfg.Cache.Size > 0 {
+		cache = tagger.NewCache(cfg.Cache.Size, cfg.Cache.TTL)
+		if err := cache.Load(cacheFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: starting with an empty cache: %v\n", err)
+		}
+	}
+
+	// Load models and make predictions
+	t, err := tagger.NewContext(ctx, fsys, &tagger.Options{Categories: categories, BestEffort: bestEffort, Cache: cache})
+	if t == nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if len(categories) == 0 {
+		categories = t.Categories()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: skipping categories %s:\n%v\n", strings.Join(tagger.FailedCategories(err), ", "), err)
+	}
+
+	opts := &tagger.PredictOptions{
+		Categories:  t.Categories(),
+		BestEffort:  bestEffort,
+		Rules:       ruleSet,
+		Resolve:     resolve || cfg.Rules.Resolve,
+		Corrections: store,
+		Attributes:  withAttributes || cfg.Attributes,
+		Names:       templates,
+		Locale:      locale,
+	}
+	if watcher != nil {
+		opts.Overrides = watcher.Current()
+	}
+	results, err := t.PredictBatch(ctx, inputStrings, opts)
+	if err != nil && (!bestEffort || results == nil) {
 		fmt.Printf("Error making predictions: %v\n", err)
 		return
 	}
+	if err != nil {
+		// Best effort: failed categories are left empty in the output
+		fmt.Fprintf(os.Stderr, "Warning: predictions failed for categories %s:\n%v\n", strings.Join(tagger.FailedCategories(err), ", "), err)
+	}
+	if isFileMode {
+		stats := t.DedupStats()
+		fmt.Fprintf(os.Stderr, "Predicted %d unique of %d rate names (%.1f%%)\n", stats.Unique, stats.Inputs, 100*stats.Ratio())
+	}
+	if cache != nil {
+		if err := cache.Save(cacheFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		stats := cache.Stats()
+		fmt.Fprintf(os.Stderr, "Prediction cache: %d hits, %d misses, %d entries in %s\n", stats.Hits, stats.Misses, stats.Entries, cacheFile)
+	}
 
-	headers := append([]string{cfg.InputCol}, categories...)
+	headers, rows := tagger.Table(cfg.InputCol, categories, results)
+	if store != nil {
+		headers, rows = tagger.AppendSources(headers, rows, results)
+	}
+	if watcher != nil {
+		headers, rows = tagger.AppendOverrides(headers, rows, results)
+	}
+	if ruleSet != nil {
+		headers, rows = tagger.AppendViolations(headers, rows, results)
+	}
+	if templates != nil {
+		headers, rows = tagger.AppendRoomNames(headers, rows, results)
+	}
+	if opts.Attributes {
+		headers, rows = tagger.AppendAttributes(headers, rows, results)
+	}
 	if outputFile != "" {
-		err := utils.WriteOutput(outputFile, outputFormat, headers, inputStrings, results)
+		err := utils.WriteRows(outputFile, outputFormat, headers, rows)
 		if err != nil {
 			fmt.Printf("Error writing output: %v\n", err)
 			return
 		}
 	} else {
-		printResult(outputFormat, headers, inputStrings, results)
+		err := utils.PrintRows(os.Stdout, outputFormat, headers, rows)
+		if err != nil {
+			fmt.Printf("Error writing output: %v\n", err)
+			return
+		}
 	}
 }
 
-func printResult(format string, headers []string, firstColData []string, rowData map[string]map[string]string) {
-	switch strings.ToLower(format) {
-	case "csv":
-		utils.PrintCSV(os.Stdout, headers, firstColData, rowData)
-	case "json":
-		utils.PrintJSON(os.Stdout, headers, firstColData, rowData)
-	case "tsv":
-		utils.PrintTSV(os.Stdout, headers, firstColData, rowData)
-	case "yaml":
-		utils.PrintYAML(os.Stdout, headers, firstColData, rowData)
-	default:
-		fmt.Printf("Unsupported format: %s\n", format)
-	}
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Manage API keys",
+}
+
+var hashKeyCmd = &cobra.Command{
+	Use:   "hash-key <key>",
+	Short: "Print the SHA-256 hash of an API key for the auth section of the config",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println(auth.HashKey(args[0]))
+	},
 }
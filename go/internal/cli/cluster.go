@@ -0,0 +1,238 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/go-goal/tagger/internal/artifacts"
+	"github.com/go-goal/tagger/internal/cluster"
+	"github.com/go-goal/tagger/internal/index"
+	"github.com/go-goal/tagger/pkg/corrections"
+	"github.com/go-goal/tagger/pkg/tagger"
+	"github.com/go-goal/tagger/pkg/utils"
+)
+
+// Columns added by tagger cluster
+const (
+	clusterColumn        = "cluster"
+	clusterSizeColumn    = "cluster_size"
+	representativeColumn = "representative"
+	variantsColumn       = "variants"
+)
+
+var clusterCmd = &cobra.Command{
+	Use:   "cluster",
+	Short: "Group near-duplicate rate names and pick a representative per group",
+	Long: `Group the rate names of a file into clusters of near-duplicates, such as
+"Deluxe Double Room" and "DELUXE DOUBLE ROOM - non smoking". Rate names are
+linked when the cosine similarity of their TF-IDF vectors reaches --threshold
+and their predicted labels of the --category categories agree. Clusters form
+around the rate names linked to the most rows, their representatives, so every
+member of a cluster is linked to its representative. Repetitions of a rate
+name, compared by their normalized form, always share a cluster.
+
+Every row of the file is written with the cluster id, numbered from 1 by
+decreasing size, the cluster size in rows and the representative rate name.
+With --summary a single row
+per cluster is written instead, with the number of distinct variants and the
+predicted labels of the representative, for review.`,
+	Args: cobra.NoArgs,
+	Run:  runCluster,
+}
+
+func init() {
+	defaults := cluster.DefaultOptions()
+	clusterCmd.Flags().StringP("input", "i", "", "Input file of rate names (format detected by extension, CSV by default)")
+	clusterCmd.Flags().StringP("output", "o", "", "Output file (default is stdout)")
+	clusterCmd.Flags().StringP("format", "f", "csv", "Output format ("+strings.Join(utils.Formats, ", ")+")")
+	clusterCmd.Flags().Float64("threshold", defaults.Threshold, "Cosine similarity from which rate names are near-duplicates")
+	clusterCmd.Flags().StringSliceP("category", "c", []string{}, "Categories whose predicted labels must agree (default is the categories of the config)")
+	clusterCmd.Flags().Bool("ignore-tags", false, "Cluster by similarity only, without loading the models")
+	clusterCmd.Flags().Bool("summary", false, "Write one row per cluster instead of every input row")
+	clusterCmd.Flags().String("method", index.MethodAuto, "Index method ("+strings.Join(index.Methods, ", ")+")")
+	clusterCmd.MarkFlagRequired("input")
+
+	rootCmd.AddCommand(clusterCmd)
+}
+
+func runCluster(cmd *cobra.Command, args []string) {
+	inputFile, _ := cmd.Flags().GetString("input")
+	outputFile, _ := cmd.Flags().GetString("output")
+	format, _ := cmd.Flags().GetString("format")
+	threshold, _ := cmd.Flags().GetFloat64("threshold")
+	clusterCategories, _ := cmd.Flags().GetStringSlice("category")
+	ignoreTags, _ := cmd.Flags().GetBool("ignore-tags")
+	summary, _ := cmd.Flags().GetBool("summary")
+	indexOpts := index.DefaultOptions()
+	indexOpts.Method, _ = cmd.Flags().GetString("method")
+	clusterOpts := cluster.DefaultOptions()
+	clusterOpts.Threshold = threshold
+
+	outputFormat, err := utils.ParseFormat(format)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if threshold <= 0 || threshold > 1 {
+		fmt.Printf("Error: --threshold %v is out of range (0, 1]\n", threshold)
+		os.Exit(1)
+	}
+	if len(clusterCategories) == 0 && !ignoreTags {
+		clusterCategories = cfg.Categories
+	}
+
+	fileFormat := utils.FormatFromFilename(inputFile)
+	if fileFormat == "" {
+		fileFormat = utils.FormatCSV
+	}
+	file, err := os.Open(inputFile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	headers, rows, err := utils.ReadRows(file, fileFormat)
+	file.Close()
+	if err != nil {
+		fmt.Printf("Error reading rate names: %v\n", err)
+		return
+	}
+	inputIndex := utils.IndexOf(headers, cfg.InputCol)
+	if inputIndex == -1 {
+		fmt.Printf("Error: column %s not found in %s\n", cfg.InputCol, inputFile)
+		os.Exit(1)
+	}
+
+	// Repetitions are clustered once, weighted by their number of rows
+	entries := make([]int, len(rows))
+	entryOf := make(map[string]int)
+	var items []index.Item
+	var weights []int
+	for i, row := range rows {
+		entries[i] = -1
+		if inputIndex >= len(row) {
+			continue
+		}
+		key := corrections.Key(row[inputIndex])
+		if key == "" {
+			continue
+		}
+		entry, exists := entryOf[key]
+		if !exists {
+			entry = len(items)
+			entryOf[key] = entry
+			items = append(items, index.Item{ID: strconv.Itoa(entry), Name: row[inputIndex]})
+			weights = append(weights, 0)
+		}
+		weights[entry]++
+		entries[i] = entry
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fsys, err := artifacts.FS(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	tfidfData, err := artifacts.TfIdf(fsys)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	x, err := index.New(ctx, tfidfData, items, &indexOpts)
+	if err != nil {
+		fmt.Printf("Error indexing rate names: %v\n", err)
+		return
+	}
+
+	var labels []map[string]string
+	var agree func(a, b int) bool
+	if !ignoreTags {
+		names := make([]string, len(items))
+		for i, item := range items {
+			names[i] = item.Name
+		}
+		t, err := tagger.NewContext(ctx, fsys, &tagger.Options{Categories: clusterCategories})
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		results, err := t.PredictBatch(ctx, names, nil)
+		if err != nil {
+			fmt.Printf("Error making predictions: %v\n", err)
+			return
+		}
+		labels = make([]map[string]string, len(results))
+		for i, result := range results {
+			labels[i] = result.Labels()
+		}
+		agree = func(a, b int) bool {
+			for _, category := range clusterCategories {
+				if labels[a][category] != labels[b][category] {
+					return false
+				}
+			}
+			return true
+		}
+	}
+
+	clusters, err := cluster.Group(ctx, x, weights, agree, &clusterOpts)
+	if err != nil {
+		fmt.Printf("Error clustering rate names: %v\n", err)
+		return
+	}
+	clusterOf := make([]*cluster.Cluster, len(items))
+	for i := range clusters {
+		for _, member := range clusters[i].Members {
+			clusterOf[member] = &clusters[i]
+		}
+	}
+
+	var outputHeaders []string
+	var outputRows [][]string
+	if summary {
+		outputHeaders = []string{clusterColumn, clusterSizeColumn, variantsColumn, representativeColumn}
+		if labels != nil {
+			outputHeaders = append(outputHeaders, clusterCategories...)
+		}
+		for _, c := range clusters {
+			row := []string{strconv.Itoa(c.ID), strconv.Itoa(c.Size), strconv.Itoa(len(c.Members)), items[c.Representative].Name}
+			if labels != nil {
+				for _, category := range clusterCategories {
+					row = append(row, labels[c.Representative][category])
+				}
+			}
+			outputRows = append(outputRows, row)
+		}
+	} else {
+		outputHeaders = append(headers, clusterColumn, clusterSizeColumn, representativeColumn)
+		outputRows = make([][]string, len(rows))
+		for i, row := range rows {
+			row = append(row[:len(row):len(row)], make([]string, max(len(headers)-len(row), 0))...)
+			if entries[i] == -1 {
+				outputRows[i] = append(row, "", "", "")
+				continue
+			}
+			c := clusterOf[entries[i]]
+			outputRows[i] = append(row, strconv.Itoa(c.ID), strconv.Itoa(c.Size), items[c.Representative].Name)
+		}
+	}
+
+	if outputFile != "" {
+		err = utils.WriteRows(outputFile, outputFormat, outputHeaders, outputRows)
+	} else {
+		err = utils.PrintRows(os.Stdout, outputFormat, outputHeaders, outputRows)
+	}
+	if err != nil {
+		fmt.Printf("Error writing output: %v\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Grouped %d rows, %d distinct rate names, into %d clusters\n", len(rows), len(items), len(clusters))
+}
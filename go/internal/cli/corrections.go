@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/go-goal/tagger/internal/artifacts"
+	"github.com/go-goal/tagger/pkg/corrections"
+	"github.com/go-goal/tagger/pkg/utils"
+)
+
+var correctionsCmd = &cobra.Command{
+	Use:   "corrections",
+	Short: "Manage the store of human-verified tags",
+}
+
+var importCorrectionsCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import reviewed rate names from a table shaped like rates_clean.csv",
+	Long: `Import the tags of reviewed rate names into the correction store. The file
+has a column of rate names followed by one column per category, as
+inputs/rates_clean.csv. Empty cells are "undefined" for categories with that
+label and left to the model otherwise. Other columns are ignored. Nothing is
+imported if a label is unknown.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runImportCorrections,
+}
+
+var listCorrectionsCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Print the stored corrections",
+	Args:  cobra.NoArgs,
+	Run:   runListCorrections,
+}
+
+func init() {
+	correctionsCmd.PersistentFlags().String("store", "", "Correction store file (default is corrections of the config)")
+	importCorrectionsCmd.Flags().String("input-col", "", "Column of the rate names (default is input_col of the config)")
+	importCorrectionsCmd.Flags().String("author", "", "Author recorded with the corrections (default is the file name)")
+	listCorrectionsCmd.Flags().StringP("format", "f", "csv", "Output format ("+strings.Join(utils.Formats, ", ")+")")
+
+	correctionsCmd.AddCommand(importCorrectionsCmd)
+	correctionsCmd.AddCommand(listCorrectionsCmd)
+	rootCmd.AddCommand(correctionsCmd)
+}
+
+// openCorrections opens the store of the --store flag or the config
+func openCorrections(cmd *cobra.Command) (*corrections.Store, error) {
+	storeFile, _ := cmd.Flags().GetString("store")
+	if storeFile == "" {
+		storeFile = cfg.Corrections
+	}
+	if storeFile == "" {
+		return nil, fmt.Errorf("no correction store, set corrections in the config or pass --store")
+	}
+	return corrections.Open(storeFile)
+}
+
+func runImportCorrections(cmd *cobra.Command, args []string) {
+	inputCol, _ := cmd.Flags().GetString("input-col")
+	author, _ := cmd.Flags().GetString("author")
+	if inputCol == "" {
+		inputCol = cfg.InputCol
+	}
+	if author == "" {
+		author = "import:" + args[0]
+	}
+
+	format := utils.FormatFromFilename(args[0])
+	if format == "" {
+		format = utils.FormatCSV
+	}
+	file, err := os.Open(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	headers, rows, err := utils.ReadRows(file, format)
+	file.Close()
+	if err != nil {
+		fmt.Printf("Error reading corrections: %v\n", err)
+		return
+	}
+
+	fsys, err := artifacts.FS(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	imported, err := corrections.FromRows(headers, rows, inputCol, artifacts.Labels(fsys), author)
+	if err != nil {
+		fmt.Printf("Error: invalid corrections in %s:\n%v\n", args[0], err)
+		os.Exit(1)
+	}
+
+	store, err := openCorrections(cmd)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	defer store.Close()
+
+	if err := store.Put(imported...); err != nil {
+		fmt.Printf("Error storing corrections: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Imported %d corrections, %d rate names corrected in total\n", len(imported), store.Len())
+}
+
+func runListCorrections(cmd *cobra.Command, args []string) {
+	outputFormat, _ := cmd.Flags().GetString("format")
+	outputFormat, err := utils.ParseFormat(outputFormat)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	store, err := openCorrections(cmd)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	defer store.Close()
+
+	// Configured categories first, in config order, then any others
+	listCategories := append([]string(nil), cfg.Categories...)
+	stored := store.List()
+	for _, correction := range stored {
+		for category := range correction.Tags {
+			if utils.IndexOf(listCategories, category) == -1 {
+				listCategories = append(listCategories, category)
+			}
+		}
+	}
+
+	headers := append([]string{cfg.InputCol}, listCategories...)
+	headers = append(headers, "updated_at", "author")
+	rows := make([][]string, len(stored))
+	for i, correction := range stored {
+		row := []string{correction.Input}
+		for _, category := range listCategories {
+			row = append(row, correction.Tags[category])
+		}
+		rows[i] = append(row, correction.UpdatedAt.Format(time.RFC3339), correction.Author)
+	}
+
+	if err := utils.PrintRows(os.Stdout, outputFormat, headers, rows); err != nil {
+		fmt.Printf("Error writing output: %v\n", err)
+	}
+}
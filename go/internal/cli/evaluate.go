@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/go-goal/tagger/internal/artifacts"
+	"github.com/go-goal/tagger/internal/evaluation"
+	"github.com/go-goal/tagger/internal/extract"
+	"github.com/go-goal/tagger/pkg/tagger"
+	"github.com/go-goal/tagger/pkg/utils"
+)
+
+var evaluateCmd = &cobra.Command{
+	Use:   "evaluate",
+	Short: "Score the predictions against an annotated file",
+	Long: `Predict the rate names of an annotated file, such as
+inputs/rates_meal_cancellation.csv, and compare the labels to the annotated
+columns. Accuracy, macro F1 and the precision, recall and F1 score of every
+label are reported per category. Empty annotations are "undefined".`,
+	Args: cobra.NoArgs,
+	Run:  runEvaluate,
+}
+
+func init() {
+	evaluateCmd.Flags().StringP("input", "i", "", "Annotated file (format detected by extension, CSV by default)")
+	evaluateCmd.Flags().StringSliceP("category", "c", []string{}, "Categories to evaluate (default is the categories of the config annotated in the file)")
+	evaluateCmd.Flags().StringP("format", "f", "text", "Output format (text, json)")
+	evaluateCmd.Flags().Bool("errors", false, "List the misclassified rate names")
+	evaluateCmd.MarkFlagRequired("input")
+
+	rootCmd.AddCommand(evaluateCmd)
+}
+
+func runEvaluate(cmd *cobra.Command, args []string) {
+	inputFile, _ := cmd.Flags().GetString("input")
+	evaluateCategories, _ := cmd.Flags().GetStringSlice("category")
+	format, _ := cmd.Flags().GetString("format")
+	showErrors, _ := cmd.Flags().GetBool("errors")
+	if format != "text" && format != "json" {
+		fmt.Printf("Error: unsupported format %s, expected text or json\n", format)
+		return
+	}
+
+	fileFormat := utils.FormatFromFilename(inputFile)
+	if fileFormat == "" {
+		fileFormat = utils.FormatCSV
+	}
+	file, err := os.Open(inputFile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	headers, rows, err := utils.ReadRows(file, fileFormat)
+	file.Close()
+	if err != nil {
+		fmt.Printf("Error reading annotations: %v\n", err)
+		return
+	}
+
+	inputIndex := utils.IndexOf(headers, cfg.InputCol)
+	if inputIndex == -1 {
+		fmt.Printf("Error: column %s not found in %s\n", cfg.InputCol, inputFile)
+		os.Exit(1)
+	}
+	if len(evaluateCategories) == 0 {
+		for _, category := range cfg.Categories {
+			if utils.IndexOf(headers, category) != -1 {
+				evaluateCategories = append(evaluateCategories, category)
+			}
+		}
+	}
+	if len(evaluateCategories) == 0 {
+		fmt.Printf("Error: %s has no column of the configured categories\n", inputFile)
+		os.Exit(1)
+	}
+	for _, category := range evaluateCategories {
+		if utils.IndexOf(headers, category) == -1 {
+			fmt.Printf("Error: column %s not found in %s\n", category, inputFile)
+			os.Exit(1)
+		}
+	}
+
+	inputs := make([]string, len(rows))
+	for i, row := range rows {
+		inputs[i] = row[inputIndex]
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fsys, err := artifacts.FS(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	t, err := tagger.NewContext(ctx, fsys, &tagger.Options{Categories: evaluateCategories})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	results, err := t.PredictBatch(ctx, inputs, nil)
+	if err != nil {
+		fmt.Printf("Error making predictions: %v\n", err)
+		return
+	}
+
+	reports := make([]evaluation.Report, len(evaluateCategories))
+	for j, category := range evaluateCategories {
+		column := utils.IndexOf(headers, category)
+		expected := make([]string, len(rows))
+		predicted := make([]string, len(rows))
+		for i, row := range rows {
+			expected[i] = row[column]
+			if expected[i] == "" {
+				expected[i] = extract.Undefined
+			}
+			predicted[i] = results[i].Tags[category].Label
+		}
+		reports[j] = evaluation.Score(category, inputs, expected, predicted)
+		if !showErrors {
+			reports[j].Errors = nil
+		}
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(reports); err != nil {
+			fmt.Printf("Error writing output: %v\n", err)
+		}
+		return
+	}
+	if err := printReports(os.Stdout, reports); err != nil {
+		fmt.Printf("Error writing output: %v\n", err)
+	}
+}
+
+func printReports(w io.Writer, reports []evaluation.Report) error {
+	for i, report := range reports {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s: accuracy %.3f, macro F1 %.3f (%d rate names)\n", report.Category, report.Accuracy, report.MacroF1, report.Samples)
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "  label\tprecision\trecall\tf1\tsupport")
+		for _, score := range report.Labels {
+			fmt.Fprintf(tw, "  %s\t%.3f\t%.3f\t%.3f\t%d\n", score.Label, score.Precision, score.Recall, score.F1, score.Support)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+
+		for _, e := range report.Errors {
+			fmt.Fprintf(w, "  %q: expected %s, predicted %s\n", e.Input, e.Expected, e.Predicted)
+		}
+	}
+	return nil
+}
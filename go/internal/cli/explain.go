@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/go-goal/tagger/internal/artifacts"
+	"github.com/go-goal/tagger/pkg/tagger"
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain <rate name>",
+	Short: "Show which n-grams of a rate name drove its predicted tags",
+	Long: `Explain the labels predicted for a rate name. Every character n-gram of the
+rate name is left out in turn and the change in probability of the predicted
+label is reported, so a positive delta means the n-gram supports the label.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runExplain,
+}
+
+func init() {
+	explainCmd.Flags().StringSliceP("category", "c", []string{}, "Categories to explain (default is the categories of the config)")
+	explainCmd.Flags().IntP("top", "n", 10, "Number of n-grams shown per category, 0 for all")
+	explainCmd.Flags().StringP("format", "f", "text", "Output format (text, json)")
+
+	rootCmd.AddCommand(explainCmd)
+}
+
+func runExplain(cmd *cobra.Command, args []string) {
+	explainCategories, _ := cmd.Flags().GetStringSlice("category")
+	top, _ := cmd.Flags().GetInt("top")
+	format, _ := cmd.Flags().GetString("format")
+	if format != "text" && format != "json" {
+		fmt.Printf("Error: unsupported format %s, expected text or json\n", format)
+		return
+	}
+
+	if len(explainCategories) == 0 {
+		explainCategories = cfg.Categories
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fsys, err := artifacts.FS(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	t, err := tagger.NewContext(ctx, fsys, &tagger.Options{Categories: explainCategories})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	var explanations []*tagger.Explanation
+	for _, category := range t.Categories() {
+		explanation, err := t.Explain(ctx, args[0], category)
+		if err != nil {
+			fmt.Printf("Error explaining %s: %v\n", category, err)
+			return
+		}
+		if top > 0 && len(explanation.Contributions) > top {
+			explanation.Contributions = explanation.Contributions[:top]
+		}
+		explanations = append(explanations, explanation)
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(explanations)
+	} else {
+		err = printExplanations(os.Stdout, explanations)
+	}
+	if err != nil {
+		fmt.Printf("Error writing output: %v\n", err)
+	}
+}
+
+func printExplanations(w io.Writer, explanations []*tagger.Explanation) error {
+	for i, explanation := range explanations {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s = %s (p=%.3f)\n", explanation.Category, explanation.Label, explanation.Probability)
+		for _, contribution := range explanation.Contributions {
+			fmt.Fprintf(w, "  %-8q %+.4f", contribution.NGram, contribution.Delta)
+			if contribution.LabelWithout != explanation.Label {
+				fmt.Fprintf(w, "  -> %s without it", contribution.LabelWithout)
+			}
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/go-goal/tagger/internal/feedback"
+	"github.com/go-goal/tagger/pkg/utils"
+)
+
+var feedbackCmd = &cobra.Command{
+	Use:   "feedback",
+	Short: "Manage the feedback recorded by the API",
+}
+
+var exportFeedbackCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the feedback as training data shaped like rates_clean.csv",
+	Long: `Export the feedback log as a CSV file with the columns of
+inputs/rates_clean.csv. Each rate name gets the corrected labels and the
+predicted labels of the categories that were not corrected, from its latest
+feedback. "undefined" labels are left empty as in the training data. Rate
+names whose feedback misses a category of the training data are skipped.`,
+	Args: cobra.NoArgs,
+	Run:  runExportFeedback,
+}
+
+func init() {
+	exportFeedbackCmd.Flags().String("file", "", "Feedback log (default is feedback of the config)")
+	exportFeedbackCmd.Flags().StringP("output", "o", "", "Output CSV file (default is stdout)")
+	exportFeedbackCmd.Flags().String("since", "", "Only export feedback recorded since this date (YYYY-MM-DD) or RFC 3339 time")
+
+	feedbackCmd.AddCommand(exportFeedbackCmd)
+	rootCmd.AddCommand(feedbackCmd)
+}
+
+func runExportFeedback(cmd *cobra.Command, args []string) {
+	feedbackFile, _ := cmd.Flags().GetString("file")
+	outputFile, _ := cmd.Flags().GetString("output")
+	sinceFlag, _ := cmd.Flags().GetString("since")
+
+	if feedbackFile == "" {
+		feedbackFile = cfg.Feedback
+	}
+	if feedbackFile == "" {
+		fmt.Println("Error: no feedback log, set feedback in the config or pass --file")
+		return
+	}
+
+	var since time.Time
+	if sinceFlag != "" {
+		var err error
+		if since, err = time.Parse(time.DateOnly, sinceFlag); err != nil {
+			if since, err = time.Parse(time.RFC3339, sinceFlag); err != nil {
+				fmt.Printf("Error: invalid --since %q, expected YYYY-MM-DD or an RFC 3339 time\n", sinceFlag)
+				return
+			}
+		}
+	}
+
+	records, err := feedback.Read(feedbackFile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	records = feedback.Filter(records, since)
+	rows, incomplete := feedback.TrainingRows(records)
+
+	if outputFile != "" {
+		err = utils.WriteRows(outputFile, utils.FormatCSV, feedback.TrainingColumns, rows)
+	} else {
+		err = utils.PrintRows(os.Stdout, utils.FormatCSV, feedback.TrainingColumns, rows)
+	}
+	if err != nil {
+		fmt.Printf("Error writing output: %v\n", err)
+		return
+	}
+	if outputFile != "" {
+		fmt.Printf("Exported %d rate names from %d feedback records\n", len(rows), len(records))
+	}
+	if incomplete > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped %d rate names whose feedback misses categories of the training data\n", incomplete)
+	}
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/go-goal/tagger/internal/artifacts"
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <category>",
+	Short: "Print the catboost metadata or training parameters, used features and labels of a category model",
+	Args:  cobra.ExactArgs(1),
+	Run:   runInspect,
+}
+
+func init() {
+	inspectCmd.Flags().StringP("format", "f", "text", "Output format (text, json)")
+
+	rootCmd.AddCommand(inspectCmd)
+}
+
+func runInspect(cmd *cobra.Command, args []string) {
+	format, _ := cmd.Flags().GetString("format")
+	if format != "text" && format != "json" {
+		fmt.Printf("Error: unsupported format %s, expected text or json\n", format)
+		return
+	}
+
+	fsys, err := artifacts.FS(cfg)
+	if err != nil {
+		fmt.Printf("Error loading artifacts: %v\n", err)
+		return
+	}
+
+	info, err := artifacts.Inspect(fsys, args[0])
+	if err != nil {
+		fmt.Printf("Error inspecting %s: %v\n", args[0], err)
+		return
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(info); err != nil {
+			fmt.Printf("Error writing output: %v\n", err)
+		}
+		return
+	}
+
+	if err := printModelInfo(os.Stdout, info); err != nil {
+		fmt.Printf("Error writing output: %v\n", err)
+	}
+}
+
+func printModelInfo(w io.Writer, info *artifacts.ModelInfo) error {
+	var params bytes.Buffer
+	if err := json.Indent(&params, info.Params, "  ", "  "); err != nil {
+		params.Reset()
+		params.Write(info.Params)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Category:\t%s\n", info.Category)
+	fmt.Fprintf(tw, "Type:\t%s\n", info.Type)
+	if info.Type == artifacts.ModelCatboost {
+		fmt.Fprintf(tw, "CatBoost version:\t%s\n", info.CatboostVersion)
+		fmt.Fprintf(tw, "Model GUID:\t%s\n", info.ModelGUID)
+	}
+	fmt.Fprintf(tw, "Train finish time:\t%s\n", info.TrainFinishTime)
+	if info.Type == artifacts.ModelCatboost {
+		fmt.Fprintf(tw, "Trees:\t%d\n", info.TreeCount)
+	}
+	fmt.Fprintf(tw, "Dimensions:\t%d\n", info.Dimensions)
+	fmt.Fprintf(tw, "Float features:\t%d (%d used)\n", info.FloatFeatures, len(info.UsedFeatures))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nLabels (%d):\n", len(info.Labels))
+	for i, label := range info.Labels {
+		fmt.Fprintf(w, "  %d\t%s\n", i, label)
+	}
+
+	fmt.Fprintf(w, "\nUsed features (%d):\n", len(info.UsedFeatures))
+	for _, feature := range info.UsedFeatures {
+		if feature.Index == -1 {
+			fmt.Fprintf(w, "  %s\t(unknown n-gram)\n", feature.Name)
+		} else {
+			fmt.Fprintf(w, "  %d\t%q\n", feature.Index, feature.NGram)
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "\nParams:\n  %s\n", params.String())
+	return err
+}
@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/go-goal/tagger/internal/artifacts"
+	"github.com/go-goal/tagger/internal/index"
+	"github.com/go-goal/tagger/internal/mapping"
+	"github.com/go-goal/tagger/pkg/tagger"
+	"github.com/go-goal/tagger/pkg/utils"
+)
+
+var matchCmd = &cobra.Command{
+	Use:   "match a.csv b.csv",
+	Short: "Pair the rate names of two lists describing the rooms of the same hotel",
+	Long: `Pair the rate names of two lists describing the rooms of the same hotel, such
+as the exports of two suppliers, when they describe the same product. A rate
+name of a.csv may pair with a rate name of b.csv when their predicted labels
+agree in the --constraint categories, "undefined" labels agreeing with any
+label, and the cosine similarity of their TF-IDF vectors reaches --min-score.
+Every rate name of a.csv is paired with its most similar candidate, and with
+--one-to-one every rate name of b.csv is paired at most once, the most
+similar pairs first.
+
+The output has a row per pair with the id and rate name of both sides, the
+score and the labels of the constraint categories, followed by the unmatched
+rate names of a.csv and of b.csv with the fields of the other side empty.
+Ids are read from --id-col, or are the row numbers from 1.`,
+	Args: cobra.ExactArgs(2),
+	Run:  runMatch,
+}
+
+func init() {
+	matchCmd.Flags().StringP("output", "o", "", "Output file (default is stdout)")
+	matchCmd.Flags().StringP("format", "f", "csv", "Output format ("+strings.Join(utils.Formats, ", ")+")")
+	matchCmd.Flags().String("col", "", "Column of the rate names of both files (default is input_col of the config)")
+	matchCmd.Flags().String("id-col", "", "Column of the ids of both files (default is the row number)")
+	matchCmd.Flags().StringSliceP("constraint", "c", []string{}, "Categories whose labels must agree (default is matching.constraints of the config)")
+	matchCmd.Flags().Bool("no-constraints", false, "Pair by similarity only, without loading the models")
+	matchCmd.Flags().Float64("min-score", 0, "Smallest cosine similarity of a pair (default is matching.min_score of the config)")
+	matchCmd.Flags().Bool("one-to-one", false, "Pair every rate name of b.csv at most once (default is matching.one_to_one of the config)")
+
+	rootCmd.AddCommand(matchCmd)
+}
+
+func runMatch(cmd *cobra.Command, args []string) {
+	outputFile, _ := cmd.Flags().GetString("output")
+	format, _ := cmd.Flags().GetString("format")
+	col, _ := cmd.Flags().GetString("col")
+	idCol, _ := cmd.Flags().GetString("id-col")
+	constraints, _ := cmd.Flags().GetStringSlice("constraint")
+	noConstraints, _ := cmd.Flags().GetBool("no-constraints")
+	opts := mapping.DefaultOptions()
+	opts.MinScore = cfg.Matching.MinScore
+	opts.OneToOne = cfg.Matching.OneToOne
+	if cmd.Flags().Changed("min-score") {
+		opts.MinScore, _ = cmd.Flags().GetFloat64("min-score")
+	}
+	if cmd.Flags().Changed("one-to-one") {
+		opts.OneToOne, _ = cmd.Flags().GetBool("one-to-one")
+	}
+	if col == "" {
+		col = cfg.InputCol
+	}
+	switch {
+	case noConstraints:
+		constraints = nil
+	case len(constraints) == 0:
+		constraints = cfg.Matching.Constraints
+	}
+	opts.Constraints = constraints
+
+	outputFormat, err := utils.ParseFormat(format)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	a, err := artifacts.ReadCatalog(args[0], col, idCol)
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", args[0], err)
+		return
+	}
+	b, err := artifacts.ReadCatalog(args[1], col, idCol)
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", args[1], err)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fsys, err := artifacts.FS(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	labelsA := make([]map[string]string, len(a))
+	labelsB := make([]map[string]string, len(b))
+	if len(constraints) > 0 {
+		t, err := tagger.NewContext(ctx, fsys, &tagger.Options{Categories: constraints})
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		for _, side := range []struct {
+			items  []index.Item
+			labels []map[string]string
+		}{{a, labelsA}, {b, labelsB}} {
+			names := make([]string, len(side.items))
+			for i, item := range side.items {
+				names[i] = item.Name
+			}
+			results, err := t.PredictBatch(ctx, names, nil)
+			if err != nil {
+				fmt.Printf("Error making predictions: %v\n", err)
+				return
+			}
+			for i, result := range results {
+				side.labels[i] = result.Labels()
+			}
+		}
+	}
+
+	tfidfData, err := artifacts.TfIdf(fsys)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	m, err := mapping.Match(ctx, tfidfData, a, b, labelsA, labelsB, &opts)
+	if err != nil {
+		fmt.Printf("Error matching rate names: %v\n", err)
+		return
+	}
+
+	headers, rows := mapping.Table(a, b, labelsA, labelsB, constraints, m)
+	if outputFile != "" {
+		err = utils.WriteRows(outputFile, outputFormat, headers, rows)
+	} else {
+		err = utils.PrintRows(os.Stdout, outputFormat, headers, rows)
+	}
+	if err != nil {
+		fmt.Printf("Error writing output: %v\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Paired %d of %d rate names of %s, %d of %d of %s unmatched\n", len(m.Pairs), len(a), args[0], len(m.UnmatchedB), len(b), args[1])
+}
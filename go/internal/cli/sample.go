@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/go-goal/tagger/internal/artifacts"
+	"github.com/go-goal/tagger/internal/extract"
+	"github.com/go-goal/tagger/internal/feedback"
+	"github.com/go-goal/tagger/internal/sampling"
+	"github.com/go-goal/tagger/pkg/corrections"
+	"github.com/go-goal/tagger/pkg/tagger"
+	"github.com/go-goal/tagger/pkg/utils"
+)
+
+var sampleCmd = &cobra.Command{
+	Use:   "sample",
+	Short: "Select the most informative rate names of a file for labeling",
+	Long: `Predict every rate name of an unlabeled file and select the ones the models
+are least certain about, to spend the labeling budget where it helps most.
+The uncertainty of each category is measured by the chosen strategy and
+aggregated over the categories. Rate names whose TF-IDF cosine similarity
+with an already selected one reaches --max-similarity are skipped, so
+near-duplicates are labeled once. The output has the columns of
+inputs/rates_clean.csv, with empty labels unless --prefill is set.`,
+	Args: cobra.NoArgs,
+	Run:  runSample,
+}
+
+func init() {
+	sampleCmd.Flags().StringP("input", "i", "", "Input file of rate names (format detected by extension, CSV by default)")
+	sampleCmd.Flags().StringP("output", "o", "", "Output CSV file (default is stdout)")
+	sampleCmd.Flags().IntP("count", "n", 100, "Number of rate names to select")
+	sampleCmd.Flags().StringSliceP("category", "c", []string{}, "Categories to measure (default is the categories of the config)")
+	sampleCmd.Flags().String("strategy", sampling.Entropy, "Uncertainty measure ("+strings.Join(sampling.Strategies, ", ")+")")
+	sampleCmd.Flags().String("aggregate", sampling.Mean, "Aggregation of the category uncertainties (mean, max)")
+	sampleCmd.Flags().Float64("max-similarity", 0.9, "Cosine similarity from which rate names are near-duplicates")
+	sampleCmd.Flags().Bool("prefill", false, "Fill the labels with the predictions for review")
+	sampleCmd.MarkFlagRequired("input")
+
+	rootCmd.AddCommand(sampleCmd)
+}
+
+func runSample(cmd *cobra.Command, args []string) {
+	inputFile, _ := cmd.Flags().GetString("input")
+	outputFile, _ := cmd.Flags().GetString("output")
+	count, _ := cmd.Flags().GetInt("count")
+	sampleCategories, _ := cmd.Flags().GetStringSlice("category")
+	strategy, _ := cmd.Flags().GetString("strategy")
+	aggregation, _ := cmd.Flags().GetString("aggregate")
+	maxSimilarity, _ := cmd.Flags().GetFloat64("max-similarity")
+	prefill, _ := cmd.Flags().GetBool("prefill")
+
+	// Fail on invalid strategies before loading the models
+	if _, err := sampling.Uncertainty(strategy, nil); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if _, err := sampling.Aggregate(aggregation, nil); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if len(sampleCategories) == 0 {
+		sampleCategories = cfg.Categories
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	names, err := utils.ReadColumn(inputFile, cfg.InputCol)
+	if err != nil {
+		fmt.Printf("Error reading rate names: %v\n", err)
+		return
+	}
+	names = uniqueNames(names)
+
+	fsys, err := artifacts.FS(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	t, err := tagger.NewContext(ctx, fsys, &tagger.Options{Categories: sampleCategories})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	// TopK covers every label, the uncertainty measures need the whole distribution
+	results, err := t.PredictBatch(ctx, names, &tagger.PredictOptions{TopK: maxLabels(t)})
+	if err != nil {
+		fmt.Printf("Error making predictions: %v\n", err)
+		return
+	}
+	scores := make([]float64, len(results))
+	for i, result := range results {
+		uncertainties := make([]float64, 0, len(result.Tags))
+		for _, prediction := range result.Tags {
+			probabilities := make([]float64, len(prediction.TopK))
+			for j, label := range prediction.TopK {
+				probabilities[j] = label.Probability
+			}
+			uncertainty, _ := sampling.Uncertainty(strategy, probabilities)
+			uncertainties = append(uncertainties, uncertainty)
+		}
+		scores[i], _ = sampling.Aggregate(aggregation, uncertainties)
+	}
+
+	selected := sampling.Select(scores, func(i int) ([]int32, []float32) {
+		return t.SparseVector(names[i])
+	}, count, maxSimilarity)
+
+	rows := make([][]string, len(selected))
+	for i, index := range selected {
+		row := make([]string, len(feedback.TrainingColumns))
+		row[0] = names[index]
+		if prefill {
+			for j, category := range feedback.TrainingColumns[1:] {
+				if label := results[index].Tags[category].Label; label != extract.Undefined {
+					row[j+1] = label
+				}
+			}
+		}
+		rows[i] = row
+	}
+
+	if outputFile != "" {
+		err = utils.WriteRows(outputFile, utils.FormatCSV, feedback.TrainingColumns, rows)
+	} else {
+		err = utils.PrintRows(os.Stdout, utils.FormatCSV, feedback.TrainingColumns, rows)
+	}
+	if err != nil {
+		fmt.Printf("Error writing output: %v\n", err)
+		return
+	}
+	if len(selected) > 0 {
+		fmt.Fprintf(os.Stderr, "Selected %d of %d unique rate names, %s uncertainty from %.3f to %.3f\n",
+			len(selected), len(names), strategy, scores[selected[0]], scores[selected[len(selected)-1]])
+	}
+}
+
+// uniqueNames drops empty rate names and repeated ones, compared by their
+// normalized form
+func uniqueNames(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	unique := make([]string, 0, len(names))
+	for _, name := range names {
+		key := corrections.Key(name)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, name)
+	}
+	return unique
+}
+
+// maxLabels returns the largest number of labels of the loaded categories
+func maxLabels(t *tagger.Tagger) int {
+	n := 0
+	for _, category := range t.Categories() {
+		labels, _ := t.Labels(category)
+		n = max(n, len(labels))
+	}
+	return n
+}
@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/go-goal/tagger/internal/artifacts"
+	"github.com/go-goal/tagger/internal/index"
+	"github.com/go-goal/tagger/pkg/utils"
+)
+
+var similarCmd = &cobra.Command{
+	Use:   "similar",
+	Short: "Find the most similar rate names of a reference catalog",
+	Long: `Map rate names onto a reference catalog of canonical rate names by the cosine
+similarity of their TF-IDF vectors. The catalog is indexed exactly up to
+100000 rate names and with locality-sensitive hashing above, which finds
+nearly all neighbours with a similarity above 0.9 and most above 0.8, more
+--tables find more. Every input gets one row per
+match with its rank, catalog id, rate name and score, or a single row with
+empty fields if nothing reaches --min-score.`,
+	Args: cobra.NoArgs,
+	Run:  runSimilar,
+}
+
+func init() {
+	defaults := index.DefaultOptions()
+	similarCmd.Flags().StringP("input", "i", "", "Input file of rate names (format detected by extension, CSV by default) or a single rate name")
+	similarCmd.Flags().StringP("output", "o", "", "Output file (default is stdout)")
+	similarCmd.Flags().StringP("format", "f", "csv", "Output format ("+strings.Join(utils.Formats, ", ")+")")
+	similarCmd.Flags().String("catalog", "", "Catalog file of canonical rate names (default is similarity.catalog of the config)")
+	similarCmd.Flags().String("catalog-col", "", "Column of the catalog rate names (default is input_col of the config)")
+	similarCmd.Flags().String("id-col", "", "Column of the catalog ids (default is similarity.id_col of the config, or the row number)")
+	similarCmd.Flags().IntP("top", "n", 5, "Number of matches per rate name")
+	similarCmd.Flags().Float64("min-score", 0, "Smallest cosine similarity of a match")
+	similarCmd.Flags().String("method", "", "Index method ("+strings.Join(index.Methods, ", ")+") (default is similarity.method of the config)")
+	similarCmd.Flags().Int("tables", defaults.Tables, "Number of hash tables of the lsh method")
+	similarCmd.Flags().Int("bits", defaults.Bits, "Bits per hash table of the lsh method")
+	similarCmd.MarkFlagRequired("input")
+
+	rootCmd.AddCommand(similarCmd)
+}
+
+func runSimilar(cmd *cobra.Command, args []string) {
+	input, _ := cmd.Flags().GetString("input")
+	outputFile, _ := cmd.Flags().GetString("output")
+	format, _ := cmd.Flags().GetString("format")
+	catalogFile, _ := cmd.Flags().GetString("catalog")
+	catalogCol, _ := cmd.Flags().GetString("catalog-col")
+	idCol, _ := cmd.Flags().GetString("id-col")
+	top, _ := cmd.Flags().GetInt("top")
+	minScore, _ := cmd.Flags().GetFloat64("min-score")
+	opts := index.DefaultOptions()
+	opts.Method, _ = cmd.Flags().GetString("method")
+	opts.Tables, _ = cmd.Flags().GetInt("tables")
+	opts.Bits, _ = cmd.Flags().GetInt("bits")
+	if catalogFile == "" {
+		catalogFile = cfg.Similarity.Catalog
+	}
+	if catalogCol == "" {
+		catalogCol = cfg.InputCol
+	}
+	if idCol == "" {
+		idCol = cfg.Similarity.IDCol
+	}
+	if opts.Method == "" {
+		opts.Method = cfg.Similarity.Method
+	}
+
+	outputFormat, err := utils.ParseFormat(format)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if catalogFile == "" {
+		fmt.Println("Error: no catalog, set --catalog or similarity.catalog in the config")
+		os.Exit(1)
+	}
+	if top < 1 {
+		fmt.Printf("Error: --top %d must be at least 1\n", top)
+		os.Exit(1)
+	}
+
+	var queries []string
+	if utils.IsFile(input) {
+		queries, err = utils.ReadColumn(input, cfg.InputCol)
+		if err != nil {
+			fmt.Printf("Error reading rate names: %v\n", err)
+			return
+		}
+	} else {
+		queries = []string{input}
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	start := time.Now()
+	fsys, err := artifacts.FS(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	catalog, err := artifacts.Catalog(ctx, fsys, catalogFile, catalogCol, idCol, &opts)
+	if err != nil {
+		fmt.Printf("Error indexing catalog: %v\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Indexed %d catalog rate names (%s) in %s\n", catalog.Len(), catalog.Method(), time.Since(start).Round(time.Millisecond))
+
+	matches, err := catalog.Search(ctx, queries, top, minScore)
+	if err != nil {
+		fmt.Printf("Error searching: %v\n", err)
+		return
+	}
+
+	headers, rows := index.Table(cfg.InputCol, queries, matches)
+	if outputFile != "" {
+		err = utils.WriteRows(outputFile, outputFormat, headers, rows)
+	} else {
+		err = utils.PrintRows(os.Stdout, outputFormat, headers, rows)
+	}
+	if err != nil {
+		fmt.Printf("Error writing output: %v\n", err)
+	}
+}
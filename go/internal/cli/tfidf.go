@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/go-goal/tagger/internal/tfidf"
+	"github.com/go-goal/tagger/pkg/utils"
+)
+
+var tfidfCmd = &cobra.Command{
+	Use:   "tfidf",
+	Short: "Manage the TF-IDF vectorizer",
+}
+
+var fitTfidfCmd = &cobra.Command{
+	Use:   "fit",
+	Short: "Fit the TF-IDF vocabulary and IDF values on a corpus of rate names",
+	Long: `Fit the vocabulary and IDF values of the TF-IDF vectorizer on the rate names
+of a file and write them as tfidf_data.json. The vectorizer matches
+scikit-learn's TfidfVectorizer(analyzer="char_wb", ngram_range=(1, 3),
+strip_accents="unicode", sublinear_tf=True, smooth_idf=True) used by the
+training notebooks, whose defaults are min_df=2, max_df=0.95 and
+max_features=10000.
+
+--min-df and --max-df take a number of documents ("2") or, with a decimal
+point, a proportion of the documents ("0.95"), as the int and float values of
+scikit-learn. Models trained on another vocabulary do not work with the new
+file, retrain them before deploying it.`,
+	Args: cobra.NoArgs,
+	Run:  runFitTfidf,
+}
+
+func init() {
+	fitTfidfCmd.Flags().StringP("input", "i", "", "Corpus file of rate names (format detected by extension, CSV by default)")
+	fitTfidfCmd.Flags().StringP("output", "o", "tfidf_data.json", "Output TF-IDF data file")
+	fitTfidfCmd.Flags().String("min-df", "2", "Minimum document frequency of a term, a count or a proportion")
+	fitTfidfCmd.Flags().String("max-df", "0.95", "Maximum document frequency of a term, a count or a proportion")
+	fitTfidfCmd.Flags().Int("max-features", 10000, "Maximum number of terms, the most frequent in the corpus, 0 for all")
+	fitTfidfCmd.MarkFlagRequired("input")
+
+	tfidfCmd.AddCommand(fitTfidfCmd)
+	rootCmd.AddCommand(tfidfCmd)
+}
+
+func runFitTfidf(cmd *cobra.Command, args []string) {
+	inputFile, _ := cmd.Flags().GetString("input")
+	outputFile, _ := cmd.Flags().GetString("output")
+	minDFFlag, _ := cmd.Flags().GetString("min-df")
+	maxDFFlag, _ := cmd.Flags().GetString("max-df")
+	maxFeatures, _ := cmd.Flags().GetInt("max-features")
+
+	minDF, err := tfidf.ParseFrequency(minDFFlag)
+	if err != nil {
+		fmt.Printf("Error: --min-df: %v\n", err)
+		return
+	}
+	maxDF, err := tfidf.ParseFrequency(maxDFFlag)
+	if err != nil {
+		fmt.Printf("Error: --max-df: %v\n", err)
+		return
+	}
+
+	corpus, err := utils.ReadColumn(inputFile, cfg.InputCol)
+	if err != nil {
+		fmt.Printf("Error reading rate names: %v\n", err)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	data, err := tfidf.Fit(ctx, corpus, &tfidf.FitOptions{MinDF: minDF, MaxDF: maxDF, MaxFeatures: maxFeatures})
+	if err != nil {
+		fmt.Printf("Error fitting TF-IDF: %v\n", err)
+		return
+	}
+	if err := tfidf.SaveTfIdfData(outputFile, data); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Printf("Fitted %d terms on %d rate names, written to %s\n", len(data.Vocabulary), len(corpus), outputFile)
+}
@@ -0,0 +1,243 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/go-goal/tagger/internal/extract"
+	"github.com/go-goal/tagger/internal/linear"
+	"github.com/go-goal/tagger/internal/tfidf"
+	"github.com/go-goal/tagger/pkg/bundle"
+	"github.com/go-goal/tagger/pkg/corrections"
+	"github.com/go-goal/tagger/pkg/utils"
+)
+
+var trainCmd = &cobra.Command{
+	Use:   "train",
+	Short: "Train a linear model of a category on a labeled file",
+	Long: `Train a multinomial logistic regression model of a category on the TF-IDF
+vectors of a labeled file, such as inputs/rates_clean.csv, without the Python
+training pipeline. The category is a column of the file, empty cells are
+labeled "undefined".
+
+The model is written to linear/linear_model_<category>.json and its labels to
+labels/json/labels_<category>.json of the artifacts directory, the configured
+models_dir by default, whose TF-IDF data is used for the vectors. Add the
+category to the categories of the config to serve it. A share --test-size of
+the rate names is held out to report the accuracy of the model.
+
+Categories with a built-in extractor (meal_plan, cancellation_policy) keep the
+label vocabulary of their extractor. With --extract, rate names without a
+label in the file, or all of them if it has no such column, are labeled by the
+extractor, which bootstraps the model from its patterns.`,
+	Args: cobra.NoArgs,
+	Run:  runTrain,
+}
+
+func init() {
+	defaults := linear.DefaultTrainOptions()
+	trainCmd.Flags().StringP("input", "i", "", "Labeled file (format detected by extension, CSV by default)")
+	trainCmd.Flags().StringP("category", "c", "", "Category column to train on")
+	trainCmd.Flags().String("input-col", "", "Column of the rate names (default is input_col of the config)")
+	trainCmd.Flags().StringP("output-dir", "o", "", "Artifacts directory to write the model to (default is models_dir of the config)")
+	trainCmd.Flags().Float64("test-size", 0.2, "Share of the rate names held out for evaluation")
+	trainCmd.Flags().Int("epochs", defaults.Epochs, "Number of passes over the training rate names")
+	trainCmd.Flags().Float64("learning-rate", defaults.LearningRate, "Initial learning rate")
+	trainCmd.Flags().Float64("l2", defaults.L2, "L2 regularization strength")
+	trainCmd.Flags().Int("batch-size", defaults.BatchSize, "Number of rate names per gradient step")
+	trainCmd.Flags().Int64("seed", defaults.Seed, "Seed of the train/test split and shuffling")
+	trainCmd.Flags().Bool("extract", false, "Label the rate names without a label with the extractor of the category")
+	trainCmd.MarkFlagRequired("input")
+	trainCmd.MarkFlagRequired("category")
+
+	rootCmd.AddCommand(trainCmd)
+}
+
+func runTrain(cmd *cobra.Command, args []string) {
+	inputFile, _ := cmd.Flags().GetString("input")
+	category, _ := cmd.Flags().GetString("category")
+	inputCol, _ := cmd.Flags().GetString("input-col")
+	outputDir, _ := cmd.Flags().GetString("output-dir")
+	testSize, _ := cmd.Flags().GetFloat64("test-size")
+	var opts linear.TrainOptions
+	opts.Epochs, _ = cmd.Flags().GetInt("epochs")
+	opts.LearningRate, _ = cmd.Flags().GetFloat64("learning-rate")
+	opts.L2, _ = cmd.Flags().GetFloat64("l2")
+	opts.BatchSize, _ = cmd.Flags().GetInt("batch-size")
+	opts.Seed, _ = cmd.Flags().GetInt64("seed")
+	useExtractor, _ := cmd.Flags().GetBool("extract")
+	if inputCol == "" {
+		inputCol = cfg.InputCol
+	}
+	if outputDir == "" {
+		outputDir = cfg.ModelsDir
+	}
+
+	if testSize < 0 || testSize >= 1 {
+		fmt.Printf("Error: --test-size %v is out of range [0, 1)\n", testSize)
+		os.Exit(1)
+	}
+	// A catboost model takes precedence and shares the labels file
+	if _, err := os.Stat(filepath.Join(outputDir, filepath.FromSlash(bundle.ModelPath(category)))); err == nil {
+		fmt.Printf("Error: category %s has a catboost model in %s, train into another --output-dir\n", category, outputDir)
+		os.Exit(1)
+	}
+
+	extractor, hasExtractor := extract.Lookup(category)
+	if useExtractor && !hasExtractor {
+		fmt.Printf("Error: category %s has no extractor\n", category)
+		os.Exit(1)
+	}
+
+	names, err := utils.ReadColumn(inputFile, inputCol)
+	if err != nil {
+		fmt.Printf("Error reading rate names: %v\n", err)
+		return
+	}
+	cells, err := utils.ReadColumn(inputFile, category)
+	if err != nil && useExtractor {
+		cells = make([]string, len(names))
+	} else if err != nil {
+		fmt.Printf("Error reading labels: %v\n", err)
+		return
+	}
+	if useExtractor {
+		for i, name := range names {
+			if cells[i] == "" {
+				cells[i], _ = extractor.Extract(name)
+			}
+		}
+	}
+
+	tfidfData, err := tfidf.LoadTfIdfData(filepath.Join(outputDir, filepath.FromSlash(bundle.TfIdfPath)))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	var vocabulary []string
+	if hasExtractor {
+		vocabulary = extractor.Labels
+	}
+	labels, targets, vectors, err := trainingSet(names, cells, vocabulary, &tfidfData)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(labels) < 2 {
+		fmt.Printf("Error: column %s has %d distinct labels, expected at least 2\n", category, len(labels))
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	trainIndices, testIndices := linear.Split(len(vectors), testSize, opts.Seed)
+	trainVectors, trainTargets := subset(vectors, targets, trainIndices)
+	testVectors, testTargets := subset(vectors, targets, testIndices)
+
+	model, err := linear.Train(ctx, category, labels, len(tfidfData.IdfValues), trainVectors, trainTargets, opts)
+	if err != nil {
+		fmt.Printf("Error training: %v\n", err)
+		return
+	}
+	model.Metrics = &linear.Evaluation{Train: model.Evaluate(trainVectors, trainTargets)}
+	if len(testVectors) > 0 {
+		test := model.Evaluate(testVectors, testTargets)
+		model.Metrics.Test = &test
+	}
+
+	if err := writeLinearModel(outputDir, model); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	fmt.Printf("Trained %s on %d rate names with %d labels\n", category, len(trainVectors), len(labels))
+	fmt.Printf("  train: accuracy %.3f, macro F1 %.3f, log loss %.3f\n", model.Metrics.Train.Accuracy, model.Metrics.Train.MacroF1, model.Metrics.Train.LogLoss)
+	if test := model.Metrics.Test; test != nil {
+		fmt.Printf("  test:  accuracy %.3f, macro F1 %.3f, log loss %.3f (%d rate names)\n", test.Accuracy, test.MacroF1, test.LogLoss, test.Samples)
+	}
+	fmt.Printf("Wrote %s and %s in %s\n", bundle.LinearModelPath(category), bundle.LabelsPath(category), outputDir)
+}
+
+// trainingSet returns the labels, the label index of every rate name and its
+// sparse TF-IDF vector. The labels are the given vocabulary, or the sorted
+// labels of the cells if it is nil. Rate names that are empty are dropped,
+// empty cells are undefined.
+func trainingSet(names, cells, vocabulary []string, tfidfData *tfidf.TfIdfData) ([]string, []int, []linear.Vector, error) {
+	labels := slices.Clone(vocabulary)
+	indices := make(map[string]int)
+	var kept []int
+	for i, name := range names {
+		if corrections.Key(name) == "" {
+			continue
+		}
+		if cells[i] == "" {
+			cells[i] = extract.Undefined
+		}
+		if _, exists := indices[cells[i]]; !exists {
+			if vocabulary != nil && !slices.Contains(vocabulary, cells[i]) {
+				return nil, nil, nil, fmt.Errorf("unknown label '%s' of rate name %d, known labels: %s", cells[i], i+1, strings.Join(vocabulary, ", "))
+			}
+			indices[cells[i]] = 0
+			if vocabulary == nil {
+				labels = append(labels, cells[i])
+			}
+		}
+		kept = append(kept, i)
+	}
+	sort.Strings(labels)
+	for i, label := range labels {
+		indices[label] = i
+	}
+
+	targets := make([]int, len(kept))
+	vectors := make([]linear.Vector, len(kept))
+	for j, i := range kept {
+		targets[j] = indices[cells[i]]
+		vectors[j] = linear.Sparse(tfidf.CalculateTfIdfVector(names[i], tfidfData))
+	}
+	return labels, targets, vectors, nil
+}
+
+func subset(vectors []linear.Vector, targets []int, indices []int) ([]linear.Vector, []int) {
+	subsetVectors := make([]linear.Vector, len(indices))
+	subsetTargets := make([]int, len(indices))
+	for j, i := range indices {
+		subsetVectors[j] = vectors[i]
+		subsetTargets[j] = targets[i]
+	}
+	return subsetVectors, subsetTargets
+}
+
+// writeLinearModel writes a model and its labels file into an artifacts directory
+func writeLinearModel(dir string, model *linear.Model) error {
+	modelPath := filepath.Join(dir, filepath.FromSlash(bundle.LinearModelPath(model.Category)))
+	labelsPath := filepath.Join(dir, filepath.FromSlash(bundle.LabelsPath(model.Category)))
+	for _, path := range []string{modelPath, labelsPath} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("failed to create directory: %v", err)
+		}
+	}
+
+	if err := model.Save(modelPath); err != nil {
+		return err
+	}
+	labelsContent, err := json.Marshal(model.Labels)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(labelsPath, labelsContent, 0o644); err != nil {
+		return fmt.Errorf("failed to write labels: %v", err)
+	}
+	return nil
+}
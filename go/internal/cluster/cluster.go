@@ -0,0 +1,145 @@
+// Package cluster groups near-duplicate rate names, such as "Deluxe Double
+// Room" and "DELUXE DOUBLE ROOM - non smoking", so that one representative
+// per group can be reviewed.
+//
+// Two rate names are linked when the cosine similarity of their TF-IDF
+// vectors reaches the threshold and their predicted tags agree. Clusters are
+// formed around the most central rate names: in decreasing order of
+// centrality, a rate name not yet clustered becomes the representative of a
+// new cluster and takes the linked rate names not yet clustered. Every member
+// is thus linked to its representative, and clusters never chain dissimilar
+// rate names through intermediate ones.
+package cluster
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/go-goal/tagger/internal/index"
+)
+
+// Options configure Group
+type Options struct {
+	// Threshold is the cosine similarity from which rate names are near-duplicates
+	Threshold float64
+	// Neighbours bounds the links per rate name, among the rate names whose
+	// tags agree
+	Neighbours int
+}
+
+// DefaultOptions returns the options used for nil *Options
+func DefaultOptions() Options {
+	return Options{Threshold: 0.8, Neighbours: 50}
+}
+
+// Cluster is a group of near-duplicate entries of an index
+type Cluster struct {
+	// ID numbers the clusters from 1 by decreasing size
+	ID int
+	// Members are the entries of the cluster in increasing order
+	Members []int
+	// Representative is the member the cluster was formed around
+	Representative int
+	// Size is the number of rate names of the members, weights included
+	Size int
+}
+
+// Group clusters the entries of x. weights counts the rate names of every
+// entry, e.g. the repetitions of a unique rate name, nil counts one each.
+// agree reports whether two entries may be linked, nil links every pair
+// reaching the threshold. It is applied while searching the neighbours, so
+// disagreeing entries never crowd out agreeing ones, and must be safe for
+// concurrent use.
+//
+// The centrality of an entry is the weighted sum of its similarities to its
+// links, its own repetitions counting with similarity 1, ties going to the
+// first entry.
+func Group(ctx context.Context, x *index.Index, weights []int, agree func(a, b int) bool, opts *Options) ([]Cluster, error) {
+	options := DefaultOptions()
+	if opts != nil {
+		options = *opts
+	}
+	weight := func(i int) int {
+		if weights == nil {
+			return 1
+		}
+		return weights[i]
+	}
+
+	n := x.Len()
+	links := make([][]index.Match, n)
+	var wg sync.WaitGroup
+	jobs := make(chan int, n)
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				var keep func(int) bool
+				if agree != nil {
+					keep = func(j int) bool { return agree(i, j) }
+				}
+				links[i] = x.Neighbours(i, options.Neighbours, options.Threshold, keep)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	centrality := make([]float64, n)
+	for i := range links {
+		centrality[i] = float64(weight(i) - 1)
+		for _, link := range links[i] {
+			centrality[i] += link.Score * float64(weight(link.Entry))
+		}
+	}
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return centrality[order[a]] > centrality[order[b]]
+	})
+
+	assigned := make([]bool, n)
+	var clusters []Cluster
+	for _, center := range order {
+		if assigned[center] {
+			continue
+		}
+		assigned[center] = true
+		c := Cluster{Representative: center, Members: []int{center}, Size: weight(center)}
+		for _, link := range links[center] {
+			if !assigned[link.Entry] {
+				assigned[link.Entry] = true
+				c.Members = append(c.Members, link.Entry)
+				c.Size += weight(link.Entry)
+			}
+		}
+		sort.Ints(c.Members)
+		clusters = append(clusters, c)
+	}
+
+	sort.SliceStable(clusters, func(a, b int) bool {
+		if clusters[a].Size != clusters[b].Size {
+			return clusters[a].Size > clusters[b].Size
+		}
+		return clusters[a].Members[0] < clusters[b].Members[0]
+	})
+	for i := range clusters {
+		clusters[i].ID = i + 1
+	}
+	return clusters, nil
+}
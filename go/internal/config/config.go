@@ -2,19 +2,130 @@ package config
 
 import (
 	"fmt"
+	"path/filepath"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
+// Config is the configuration shared by the CLI and the API. Relative file
+// paths are resolved against the directory of the config file.
 type Config struct {
-	ModelsDir  string   `mapstructure:"models_dir"`
-	InputCol   string   `mapstructure:"input_col"`
-	Categories []string `mapstructure:"categories"`
+	ModelsDir string `mapstructure:"models_dir"`
+	// Bundle is a bundle archive loaded instead of ModelsDir if set
+	Bundle     string           `mapstructure:"bundle"`
+	InputCol   string           `mapstructure:"input_col"`
+	Categories []string         `mapstructure:"categories"`
+	Auth       AuthConfig       `mapstructure:"auth"`
+	Server     ServerConfig     `mapstructure:"server"`
+	Rules      RulesConfig      `mapstructure:"rules"`
+	Overrides  OverridesConfig  `mapstructure:"overrides"`
+	Names      NamesConfig      `mapstructure:"names"`
+	Similarity SimilarityConfig `mapstructure:"similarity"`
+	Matching   MatchingConfig   `mapstructure:"matching"`
+	Cache      CacheConfig      `mapstructure:"cache"`
+	// Corrections is the correction store file, disabled if empty
+	Corrections string `mapstructure:"corrections"`
+	// CorrectionsReloadInterval is how often the API reads the corrections
+	// other processes added to the store, zero disables reloading
+	CorrectionsReloadInterval time.Duration `mapstructure:"corrections_reload_interval"`
+	// Feedback is the append-only log of POST /feedback, disabled if empty
+	Feedback string `mapstructure:"feedback"`
+	// Attributes adds the numbers parsed from the rate names, and the labels
+	// they contradict, to the predictions
+	Attributes bool `mapstructure:"attributes"`
+}
+
+// OverridesConfig configures the override rules, disabled if File is empty
+type OverridesConfig struct {
+	File string `mapstructure:"file"`
+	// ReloadInterval is how often the API checks the file for changes, zero
+	// disables reloading
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+}
+
+// SimilarityConfig configures the reference catalog searched by /similar,
+// disabled if Catalog is empty
+type SimilarityConfig struct {
+	// Catalog is a file of canonical rate names in the input_col column
+	Catalog string `mapstructure:"catalog"`
+	// IDCol is the column of the catalog ids, the row number from 1 if empty
+	IDCol string `mapstructure:"id_col"`
+	// Method is auto, exact or lsh, see internal/index
+	Method string `mapstructure:"method"`
+}
+
+// CacheConfig configures the cache of the predictions of repeated rate names
+type CacheConfig struct {
+	// Size is the number of rate names cached, zero disables the cache
+	Size int `mapstructure:"size"`
+	// TTL is how long a prediction is cached, zero keeps it until evicted
+	TTL time.Duration `mapstructure:"ttl"`
+	// File persists the cache of the CLI between runs, which has no cache
+	// without it
+	File string `mapstructure:"file"`
+}
+
+// MatchingConfig configures the pairing of two lists of rate names by
+// tagger match and /match, see internal/mapping
+type MatchingConfig struct {
+	// Constraints are the categories whose labels must agree in a pair
+	Constraints []string `mapstructure:"constraints"`
+	// MinScore is the smallest cosine similarity of a pair
+	MinScore float64 `mapstructure:"min_score"`
+	// OneToOne pairs every rate name of the second list at most once
+	OneToOne bool `mapstructure:"one_to_one"`
+}
+
+// NamesConfig configures the room names rendered from the labels, disabled
+// if File is empty
+type NamesConfig struct {
+	File string `mapstructure:"file"`
+	// Locale of the room names, the default locale of the file if empty
+	Locale string `mapstructure:"locale"`
+}
+
+// RulesConfig configures the cross-category consistency rules, disabled if
+// File is empty
+type RulesConfig struct {
+	File string `mapstructure:"file"`
+	// Resolve replaces violating labels by the most likely consistent ones
+	// instead of only reporting violations
+	Resolve bool `mapstructure:"resolve"`
+}
+
+// ServerConfig configures the API server. Zero durations disable the timeout,
+// a zero shutdown timeout waits for in-flight requests indefinitely.
+type ServerConfig struct {
+	Port            string        `mapstructure:"port"`
+	ReadTimeout     time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout    time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout     time.Duration `mapstructure:"idle_timeout"`
+	RequestTimeout  time.Duration `mapstructure:"request_timeout"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+}
+
+// AuthConfig configures API key authentication of the API server
+type AuthConfig struct {
+	Enabled bool     `mapstructure:"enabled"`
+	Keys    []APIKey `mapstructure:"keys"`
+}
+
+// APIKey is a client key stored as the hex SHA-256 hash of the key.
+// Zero quotas mean unlimited.
+type APIKey struct {
+	Name              string `mapstructure:"name"`
+	KeySHA256         string `mapstructure:"key_sha256"`
+	RequestsPerMinute int    `mapstructure:"requests_per_minute"`
+	RowsPerMinute     int    `mapstructure:"rows_per_minute"`
+	// WriteCorrections allows the key to change the correction store
+	WriteCorrections bool `mapstructure:"write_corrections"`
 }
 
 func LoadConfig(configPath string) (*Config, error) {
 	viper.SetConfigFile(configPath)
 	viper.SetConfigType("yaml")
+	setDefaults()
 
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("error reading config file: %w", err)
@@ -25,5 +136,52 @@ func LoadConfig(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("error unmarshaling config: %w", err)
 	}
 
+	dir := filepath.Dir(configPath)
+	config.ModelsDir = resolvePath(dir, config.ModelsDir)
+	config.Bundle = resolvePath(dir, config.Bundle)
+	config.Rules.File = resolvePath(dir, config.Rules.File)
+	config.Overrides.File = resolvePath(dir, config.Overrides.File)
+	config.Names.File = resolvePath(dir, config.Names.File)
+	config.Similarity.Catalog = resolvePath(dir, config.Similarity.Catalog)
+	config.Cache.File = resolvePath(dir, config.Cache.File)
+	config.Corrections = resolvePath(dir, config.Corrections)
+	config.Feedback = resolvePath(dir, config.Feedback)
+
+	return &config, nil
+}
+
+// Default returns the configuration used without a config file, e.g. by
+// binaries with embedded artifacts. All categories of the artifacts are used.
+func Default() (*Config, error) {
+	setDefaults()
+
+	var config Config
+	if err := viper.Unmarshal(&config); err != nil {
+		return nil, fmt.Errorf("error unmarshaling config: %w", err)
+	}
 	return &config, nil
 }
+
+func setDefaults() {
+	viper.SetDefault("input_col", "rate_name")
+	viper.SetDefault("server.port", "8000")
+	viper.SetDefault("server.read_timeout", "30s")
+	viper.SetDefault("server.write_timeout", "60s")
+	viper.SetDefault("server.idle_timeout", "120s")
+	viper.SetDefault("server.request_timeout", "30s")
+	viper.SetDefault("server.shutdown_timeout", "25s")
+	viper.SetDefault("overrides.reload_interval", "10s")
+	viper.SetDefault("corrections_reload_interval", "10s")
+	viper.SetDefault("similarity.method", "auto")
+	viper.SetDefault("matching.constraints", []string{"class", "capacity", "bedrooms"})
+	viper.SetDefault("matching.min_score", 0.5)
+	viper.SetDefault("cache.size", 50000)
+	viper.SetDefault("cache.ttl", "24h")
+}
+
+func resolvePath(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,87 @@
+// Package evaluation scores predicted labels against an annotated set.
+package evaluation
+
+import "sort"
+
+// Report holds the scores of the predictions of a category
+type Report struct {
+	Category string  `json:"category"`
+	Samples  int     `json:"samples"`
+	Accuracy float64 `json:"accuracy"`
+	// MacroF1 is the unweighted mean F1 score of the labels found in the
+	// annotations or the predictions
+	MacroF1 float64      `json:"macro_f1"`
+	Labels  []LabelScore `json:"labels"`
+	// Errors lists the misclassified inputs
+	Errors []Error `json:"errors,omitempty"`
+}
+
+// LabelScore holds the scores of a single label
+type LabelScore struct {
+	Label     string  `json:"label"`
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+	// Support is the number of inputs annotated with the label
+	Support int `json:"support"`
+}
+
+// Error is an input whose predicted label differs from its annotation
+type Error struct {
+	Input     string `json:"input"`
+	Expected  string `json:"expected"`
+	Predicted string `json:"predicted"`
+}
+
+// Score compares the predicted labels of inputs to their annotations, labels
+// are reported in alphabetical order
+func Score(category string, inputs, expected, predicted []string) Report {
+	report := Report{Category: category, Samples: len(inputs)}
+	if len(inputs) == 0 {
+		return report
+	}
+
+	truePositives := make(map[string]int)
+	predictedCounts := make(map[string]int)
+	expectedCounts := make(map[string]int)
+	correct := 0
+	for i, input := range inputs {
+		predictedCounts[predicted[i]]++
+		expectedCounts[expected[i]]++
+		if predicted[i] == expected[i] {
+			correct++
+			truePositives[expected[i]]++
+		} else {
+			report.Errors = append(report.Errors, Error{Input: input, Expected: expected[i], Predicted: predicted[i]})
+		}
+	}
+
+	labels := make([]string, 0, len(expectedCounts)+len(predictedCounts))
+	for label := range expectedCounts {
+		labels = append(labels, label)
+	}
+	for label := range predictedCounts {
+		if _, exists := expectedCounts[label]; !exists {
+			labels = append(labels, label)
+		}
+	}
+	sort.Strings(labels)
+
+	var f1Sum float64
+	for _, label := range labels {
+		score := LabelScore{Label: label, Support: expectedCounts[label]}
+		if predictedCounts[label] > 0 {
+			score.Precision = float64(truePositives[label]) / float64(predictedCounts[label])
+		}
+		if expectedCounts[label] > 0 {
+			score.Recall = float64(truePositives[label]) / float64(expectedCounts[label])
+		}
+		score.F1 = 2 * float64(truePositives[label]) / float64(predictedCounts[label]+expectedCounts[label])
+		f1Sum += score.F1
+		report.Labels = append(report.Labels, score)
+	}
+
+	report.Accuracy = float64(correct) / float64(len(inputs))
+	report.MacroF1 = f1Sum / float64(len(labels))
+	return report
+}
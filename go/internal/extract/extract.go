@@ -0,0 +1,103 @@
+// Package extract finds the meal plan and cancellation policy stated in rate
+// names with patterns over their normalized text, mapping the many ways
+// suppliers write them ("BB", "bed & breakfast", "breakfast included") to a
+// fixed vocabulary of labels.
+//
+// The Predictor serves these categories with their extractor in front of the
+// category model: a pattern match decides the label, other rate names are
+// left to the model.
+package extract
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/go-goal/tagger/internal/tfidf"
+)
+
+// Undefined is the label of rate names stating nothing about a category, which
+// empty cells of imported and annotated tables stand for as in the training data
+const Undefined = "undefined"
+
+// Meal plan labels
+const (
+	AllInclusive = "all inclusive"
+	Breakfast    = "breakfast"
+	FullBoard    = "full board"
+	HalfBoard    = "half board"
+	RoomOnly     = "room only"
+)
+
+// Cancellation policy labels
+const (
+	NonRefundable = "non-refundable"
+	PayAtHotel    = "pay at hotel"
+	Refundable    = "refundable"
+)
+
+// Extractor finds the label of a category stated in a rate name
+type Extractor struct {
+	Category string
+	// Labels is the vocabulary of the category in the order of its labels file
+	Labels   []string
+	patterns []pattern
+}
+
+// pattern maps the matches of a regular expression to a label
+type pattern struct {
+	label string
+	regex *regexp.Regexp
+}
+
+// MealPlan extracts the board type. Richer boards take precedence, so "half
+// board (breakfast and dinner)" is half board, and explicit exclusions of
+// breakfast take precedence over breakfast. The AI code counts only at the
+// end of the name or before a board word, not in room names like "AI suite".
+var MealPlan = &Extractor{
+	Category: "meal_plan",
+	Labels:   []string{AllInclusive, Breakfast, FullBoard, HalfBoard, RoomOnly, Undefined},
+	patterns: []pattern{
+		{AllInclusive, regexp.MustCompile(`\b(ultra[ -]?)?all[ -]?inclusive\b|\bu?ai( (basis|board|package|plan|rate|for)\b|[,;/)]|$)`)},
+		{FullBoard, regexp.MustCompile(`\bfull[ -]?board\b|\bfb\b|\bpension complete\b|\bthree meals\b`)},
+		{HalfBoard, regexp.MustCompile(`\bhalf[ -]?board\b|\bhb\b|\bdemi[ -]?pension\b|\bbreakfast (and|&|\+) dinner\b`)},
+		{RoomOnly, regexp.MustCompile(`\b(without|w/o|no|non|excl(\.|uding)?) ?breakfast\b|\bbreakfast (is )?not included\b|\broom[ -]only\b|\bro\b|\bno meals?\b|\baccommodation only\b`)},
+		{Breakfast, regexp.MustCompile(`\bbreakfast\b|\bb ?& ?b\b|\bbb\b|\bbed (and|&) breakfast\b|\bbuffet breakfast\b`)},
+	},
+}
+
+// CancellationPolicy extracts the refundability of the rate, or that it is
+// paid at the hotel when nothing is said about refunds. "Flexible" counts only
+// for the rate or its terms, not in room names like "flexible space".
+var CancellationPolicy = &Extractor{
+	Category: "cancellation_policy",
+	Labels:   []string{NonRefundable, PayAtHotel, Refundable, Undefined},
+	patterns: []pattern{
+		{NonRefundable, regexp.MustCompile(`\bnon[ -]?ref(undable)?\b|\bnrf\b|\bnot refundable\b|\bno refunds?\b|\bnon[ -]?cancell?able\b`)},
+		{Refundable, regexp.MustCompile(`\b(fully )?refundable\b|\bfree cancell?ation\b|\bflexible (booking|cancell?ation|policy|rate|tariff|terms)\b|\bflex rate\b`)},
+		{PayAtHotel, regexp.MustCompile(`\bpay(ment)? (at|in) (the )?(hotel|property)\b|\bpay later\b|\bpay on arrival\b`)},
+	},
+}
+
+// Extractors are the built-in extractors by category
+var Extractors = map[string]*Extractor{
+	MealPlan.Category:           MealPlan,
+	CancellationPolicy.Category: CancellationPolicy,
+}
+
+// Lookup returns the extractor of a category
+func Lookup(category string) (*Extractor, bool) {
+	extractor, exists := Extractors[category]
+	return extractor, exists
+}
+
+// Extract returns the label of the first pattern matching the normalized
+// input, false if none matches
+func (e *Extractor) Extract(input string) (string, bool) {
+	text := strings.Join(strings.Fields(tfidf.Preprocess(input)), " ")
+	for _, p := range e.patterns {
+		if p.regex.MatchString(text) {
+			return p.label, true
+		}
+	}
+	return "", false
+}
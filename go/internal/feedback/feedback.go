@@ -0,0 +1,180 @@
+// Package feedback records corrections of production predictions in an
+// append-only log and exports them as training data.
+package feedback
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-goal/tagger/internal/extract"
+	"github.com/go-goal/tagger/pkg/corrections"
+)
+
+// TrainingColumns are the columns of inputs/rates_clean.csv, the training
+// data read by the Python notebooks
+var TrainingColumns = []string{"rate_name", "class", "quality", "bathroom", "bedding", "capacity", "club", "bedrooms", "balcony", "view", "floor"}
+
+// Record is the feedback on the prediction of one rate name
+type Record struct {
+	Time         time.Time `json:"time"`
+	Client       string    `json:"client,omitempty"`
+	ModelVersion string    `json:"model_version,omitempty"`
+	Input        string    `json:"input"`
+	// Predicted holds the tags returned by the API
+	Predicted map[string]string `json:"predicted"`
+	// Corrected holds the right labels of the categories that were wrong
+	Corrected map[string]string `json:"corrected"`
+}
+
+// Log is an append-only feedback file safe for concurrent use
+type Log struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Open opens a feedback log for appending, creating it if it does not exist
+func Open(filePath string) (*Log, error) {
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open feedback file: %v", err)
+	}
+	return &Log{file: file}, nil
+}
+
+// Append writes records to the log and syncs it
+func (l *Log) Append(records ...Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w := bufio.NewWriter(l.file)
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("failed to write feedback file: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write feedback file: %v", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("failed to write feedback file: %v", err)
+	}
+	return nil
+}
+
+// Close closes the log file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// Read returns the records of a feedback file in the order they were appended
+func Read(filePath string) ([]Record, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open feedback file: %v", err)
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal feedback on line %d of %s: %v", line, filePath, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read feedback file: %v", err)
+	}
+	return records, nil
+}
+
+// Tags returns the right tags of a record: the corrected labels, and the
+// predicted labels of the categories that were not corrected
+func (r Record) Tags() map[string]string {
+	tags := make(map[string]string, len(r.Predicted)+len(r.Corrected))
+	for category, label := range r.Predicted {
+		tags[category] = label
+	}
+	for category, label := range r.Corrected {
+		tags[category] = label
+	}
+	return tags
+}
+
+// TrainingRows converts records to rows of TrainingColumns. The latest record
+// of each rate name wins, rate names with the same normalized form are one
+// rate name. Labels that are "undefined" are empty as in the training data.
+// Rows are ordered by first appearance of the rate name.
+//
+// A record missing a category of TrainingColumns, e.g. from an API serving
+// part of the categories, would teach the models that the label is empty, so
+// its rate name is left out and counted in incomplete.
+func TrainingRows(records []Record) (rows [][]string, incomplete int) {
+	latest := make(map[string]int)
+	var order []string
+	for i, record := range records {
+		key := corrections.Key(record.Input)
+		if key == "" {
+			continue
+		}
+		if _, exists := latest[key]; !exists {
+			order = append(order, key)
+		}
+		latest[key] = i
+	}
+
+	rows = make([][]string, 0, len(order))
+	for _, key := range order {
+		record := records[latest[key]]
+		tags := record.Tags()
+
+		row := make([]string, len(TrainingColumns))
+		row[0] = record.Input
+		complete := true
+		for j, category := range TrainingColumns[1:] {
+			label, exists := tags[category]
+			if !exists || label == "" {
+				complete = false
+				break
+			}
+			if label != extract.Undefined {
+				row[j+1] = label
+			}
+		}
+		if !complete {
+			incomplete++
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, incomplete
+}
+
+// Filter returns the records appended at or after since, all if since is zero
+func Filter(records []Record, since time.Time) []Record {
+	if since.IsZero() {
+		return records
+	}
+	filtered := make([]Record, 0, len(records))
+	for _, record := range records {
+		if !record.Time.Before(since) {
+			filtered = append(filtered, record)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,372 @@
+// Package index answers nearest-neighbour queries over a reference catalog of
+// rate names by the cosine similarity of their TF-IDF vectors. The vectors
+// are L2-normalized, so the cosine similarity is their dot product.
+//
+// Two methods are available:
+//   - exact scores the query against every catalog entry sharing one of its
+//     n-grams through an inverted index, for catalogs up to ExactLimit entries
+//   - lsh hashes the vectors with random hyperplanes into Tables tables of
+//     Bits bits and only scores the entries sharing a bucket with the query
+//     in some table. It finds nearly all neighbours with a similarity above
+//     0.9 and most above 0.8, fewer below; more tables find more.
+//
+// The hyperplanes are centered on the mean catalog vector, since TF-IDF
+// vectors have no negative entries and would otherwise fall into few buckets.
+package index
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-goal/tagger/internal/tfidf"
+)
+
+// Methods of an index
+const (
+	MethodAuto  = "auto"
+	MethodExact = "exact"
+	MethodLSH   = "lsh"
+)
+
+// Methods lists the methods accepted by Options.Method
+var Methods = []string{MethodAuto, MethodExact, MethodLSH}
+
+// ExactLimit is the largest catalog MethodAuto indexes exactly
+const ExactLimit = 100000
+
+// chunkSize is the number of rate names vectorized between checks of the context
+const chunkSize = 4096
+
+// Options configure how an index is built
+type Options struct {
+	// Method is MethodAuto, MethodExact or MethodLSH, MethodAuto if empty
+	Method string
+	// Tables and Bits are the number of hash tables and the bits per table
+	// of MethodLSH, more tables find more neighbours, more bits scan fewer
+	// entries per table
+	Tables int
+	Bits   int
+	// Seed of the random hyperplanes
+	Seed int64
+}
+
+// DefaultOptions returns the options used for nil *Options
+func DefaultOptions() Options {
+	return Options{Method: MethodAuto, Tables: 16, Bits: 12, Seed: 42}
+}
+
+// Item is an entry of the catalog
+type Item struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Match is a catalog entry similar to a query
+type Match struct {
+	Item
+	// Score is the cosine similarity with the query
+	Score float64 `json:"score"`
+	// Entry is the position of the entry in the catalog
+	Entry int `json:"-"`
+}
+
+// vector holds the nonzero entries of a TF-IDF vector by increasing index
+type vector struct {
+	indices []int32
+	values  []float32
+}
+
+func (x *Index) vectorize(rateName string) vector {
+	indices, values := tfidf.CalculateSparseTfIdfVector(rateName, x.tfidfData)
+	return vector{indices: indices, values: values}
+}
+
+// dot returns the cosine similarity of two L2-normalized vectors
+func dot(a, b vector) float64 {
+	var sum float64
+	for i, j := 0, 0; i < len(a.indices) && j < len(b.indices); {
+		switch {
+		case a.indices[i] < b.indices[j]:
+			i++
+		case a.indices[i] > b.indices[j]:
+			j++
+		default:
+			sum += float64(a.values[i]) * float64(b.values[j])
+			i++
+			j++
+		}
+	}
+	return sum
+}
+
+// posting is an entry of the inverted index
+type posting struct {
+	item  int32
+	value float32
+}
+
+// Index is a searchable catalog, safe for concurrent use once built
+type Index struct {
+	items     []Item
+	vectors   []vector
+	tfidfData *tfidf.TfIdfData
+	method    string
+
+	// postings lists the entries of every n-gram, for MethodExact
+	postings [][]posting
+
+	// hyperplanes holds the Tables*Bits coordinates of every feature in the
+	// random hyperplanes, offsets the dot product of the hyperplanes with the
+	// mean vector, and buckets the entries by signature per table, for
+	// MethodLSH
+	bits        int
+	hyperplanes [][]float32
+	offsets     []float64
+	buckets     []map[uint64][]int32
+}
+
+// New vectorizes the catalog with tfidfData and indexes it
+func New(ctx context.Context, tfidfData *tfidf.TfIdfData, items []Item, opts *Options) (*Index, error) {
+	options := DefaultOptions()
+	if opts != nil {
+		options = *opts
+	}
+	if options.Method == "" {
+		options.Method = MethodAuto
+	}
+	if options.Method == MethodAuto {
+		options.Method = MethodExact
+		if len(items) > ExactLimit {
+			options.Method = MethodLSH
+		}
+	}
+	switch options.Method {
+	case MethodExact:
+	case MethodLSH:
+		if options.Tables < 1 || options.Bits < 1 || options.Bits > 64 {
+			return nil, fmt.Errorf("invalid lsh options: %d tables of %d bits, expected at least 1 table of 1 to 64 bits", options.Tables, options.Bits)
+		}
+	default:
+		return nil, fmt.Errorf("unknown method %s, expected %s", options.Method, strings.Join(Methods, ", "))
+	}
+
+	index := &Index{items: items, tfidfData: tfidfData, method: options.Method, vectors: make([]vector, len(items))}
+	for i, item := range items {
+		if i%chunkSize == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		index.vectors[i] = index.vectorize(item.Name)
+	}
+
+	if index.method == MethodExact {
+		index.buildPostings()
+	} else {
+		index.buildBuckets(options)
+	}
+	return index, nil
+}
+
+func (x *Index) buildPostings() {
+	x.postings = make([][]posting, len(x.tfidfData.IdfValues))
+	for i, v := range x.vectors {
+		for j, feature := range v.indices {
+			x.postings[feature] = append(x.postings[feature], posting{item: int32(i), value: v.values[j]})
+		}
+	}
+}
+
+func (x *Index) buildBuckets(opts Options) {
+	features := len(x.tfidfData.IdfValues)
+	mean := make([]float64, features)
+	for _, v := range x.vectors {
+		for j, feature := range v.indices {
+			mean[feature] += float64(v.values[j])
+		}
+	}
+	for i := range mean {
+		mean[i] /= float64(max(len(x.vectors), 1))
+	}
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+	x.bits = opts.Bits
+	x.hyperplanes = make([][]float32, features)
+	x.offsets = make([]float64, opts.Tables*opts.Bits)
+	for feature := range x.hyperplanes {
+		coordinates := make([]float32, len(x.offsets))
+		for h := range coordinates {
+			coordinates[h] = float32(rng.NormFloat64())
+			x.offsets[h] += float64(coordinates[h]) * mean[feature]
+		}
+		x.hyperplanes[feature] = coordinates
+	}
+
+	x.buckets = make([]map[uint64][]int32, opts.Tables)
+	for t := range x.buckets {
+		x.buckets[t] = make(map[uint64][]int32)
+	}
+	for i, v := range x.vectors {
+		for t, signature := range x.signatures(v) {
+			x.buckets[t][signature] = append(x.buckets[t][signature], int32(i))
+		}
+	}
+}
+
+// signatures returns the bucket of a vector in every table
+func (x *Index) signatures(v vector) []uint64 {
+	projections := make([]float64, len(x.offsets))
+	for h, offset := range x.offsets {
+		projections[h] = -offset
+	}
+	for j, feature := range v.indices {
+		value := float64(v.values[j])
+		for h, coordinate := range x.hyperplanes[feature] {
+			projections[h] += float64(coordinate) * value
+		}
+	}
+
+	signatures := make([]uint64, len(x.buckets))
+	for h, projection := range projections {
+		if projection > 0 {
+			signatures[h/x.bits] |= 1 << (h % x.bits)
+		}
+	}
+	return signatures
+}
+
+// Len returns the number of catalog entries
+func (x *Index) Len() int {
+	return len(x.items)
+}
+
+// Method returns the method of the index, MethodExact or MethodLSH
+func (x *Index) Method() string {
+	return x.method
+}
+
+// Search returns the k catalog entries most similar to every query, by
+// decreasing score and at least minScore. Queries with no n-gram of the
+// vocabulary match nothing.
+func (x *Index) Search(ctx context.Context, queries []string, k int, minScore float64) ([][]Match, error) {
+	return x.SearchFunc(ctx, queries, k, minScore, nil)
+}
+
+// SearchFunc is like Search but only matches the entries for which keep
+// reports true, given the position of the query and of the entry, before the
+// k most similar are taken. A nil keep matches every entry.
+func (x *Index) SearchFunc(ctx context.Context, queries []string, k int, minScore float64, keep func(query, entry int) bool) ([][]Match, error) {
+	matches := make([][]Match, len(queries))
+	for i, query := range queries {
+		if i%chunkSize == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		var keepEntry func(int) bool
+		if keep != nil {
+			keepEntry = func(entry int) bool { return keep(i, entry) }
+		}
+		matches[i] = x.search(x.vectorize(query), k, minScore, keepEntry)
+	}
+	return matches, nil
+}
+
+// Neighbours returns the k catalog entries most similar to the entry i, the
+// entry itself excluded, by decreasing score and at least minScore. Only the
+// entries for which keep reports true are considered, all if keep is nil.
+func (x *Index) Neighbours(i, k int, minScore float64, keep func(entry int) bool) []Match {
+	matches := x.search(x.vectors[i], k+1, minScore, keep)
+	for j, match := range matches {
+		if match.Entry == i {
+			return append(matches[:j], matches[j+1:]...)
+		}
+	}
+	return matches[:min(k, len(matches))]
+}
+
+// hit is a scored catalog entry
+type hit struct {
+	item  int32
+	score float64
+}
+
+func (x *Index) search(query vector, k int, minScore float64, keep func(entry int) bool) []Match {
+	if k <= 0 || len(query.indices) == 0 {
+		return nil
+	}
+
+	var hits []hit
+	if x.method == MethodExact {
+		scores := make([]float64, len(x.items))
+		var touched []int32
+		for j, feature := range query.indices {
+			for _, p := range x.postings[feature] {
+				if scores[p.item] == 0 {
+					touched = append(touched, p.item)
+				}
+				scores[p.item] += float64(query.values[j]) * float64(p.value)
+			}
+		}
+		hits = make([]hit, len(touched))
+		for j, item := range touched {
+			hits[j] = hit{item: item, score: scores[item]}
+		}
+	} else {
+		seen := make(map[int32]bool)
+		for t, signature := range x.signatures(query) {
+			for _, item := range x.buckets[t][signature] {
+				if !seen[item] {
+					seen[item] = true
+					hits = append(hits, hit{item: item, score: dot(query, x.vectors[item])})
+				}
+			}
+		}
+	}
+
+	kept := hits[:0]
+	for _, h := range hits {
+		// Rounding errors make identical vectors score slightly above 1
+		h.score = math.Min(h.score, 1)
+		if h.score > 0 && h.score >= minScore && (keep == nil || keep(int(h.item))) {
+			kept = append(kept, h)
+		}
+	}
+	sort.Slice(kept, func(a, b int) bool {
+		if kept[a].score != kept[b].score {
+			return kept[a].score > kept[b].score
+		}
+		return kept[a].item < kept[b].item
+	})
+
+	matches := make([]Match, min(k, len(kept)))
+	for j := range matches {
+		matches[j] = Match{Item: x.items[kept[j].item], Score: kept[j].score, Entry: int(kept[j].item)}
+	}
+	return matches
+}
+
+// Columns of the rows of Table after the input column
+var Columns = []string{"rank", "id", "name", "score"}
+
+// Table converts the matches of queries to rows of the query, the rank of the
+// match from 1, its id, name and score, as written by the CLI and the tabular
+// API formats. Queries without matches have a single row with empty fields.
+func Table(inputHeader string, queries []string, matches [][]Match) ([]string, [][]string) {
+	headers := append([]string{inputHeader}, Columns...)
+	var rows [][]string
+	for i, query := range queries {
+		if len(matches[i]) == 0 {
+			rows = append(rows, []string{query, "", "", "", ""})
+		}
+		for rank, match := range matches[i] {
+			rows = append(rows, []string{query, strconv.Itoa(rank + 1), match.ID, match.Name, strconv.FormatFloat(match.Score, 'f', 4, 64)})
+		}
+	}
+	return headers, rows
+}
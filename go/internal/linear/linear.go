@@ -0,0 +1,373 @@
+// Package linear trains and serves multinomial logistic regression models on
+// TF-IDF vectors, a lightweight alternative to catboost for prototyping new
+// categories without the Python training pipeline.
+//
+// A model is saved as a JSON document:
+//
+//	{
+//	  "format_version": 1,
+//	  "type": "multinomial_logistic_regression",
+//	  "category": "meal_plan",
+//	  "labels": ["all inclusive", "breakfast", ...],
+//	  "features": 3625,
+//	  "trained_at": "2026-10-18T12:00:00Z",
+//	  "params": {"epochs": 30, "learning_rate": 10, "l2": 1e-06, "batch_size": 32, "seed": 42},
+//	  "metrics": {"train": {...}, "test": {...}},
+//	  "weights": [[...], ...],
+//	  "bias": [...]
+//	}
+//
+// weights holds one row of features weights per label, in the order of
+// labels, and bias one value per label. The probabilities of a TF-IDF vector
+// x are softmax(weights·x + bias). features must equal the vocabulary size of
+// the TF-IDF data the model is served with.
+package linear
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// FormatVersion is the version of the model format written by Save
+const FormatVersion = 1
+
+// TypeLogisticRegression is the type of multinomial logistic regression models
+const TypeLogisticRegression = "multinomial_logistic_regression"
+
+// Model is a multinomial logistic regression model of a category
+type Model struct {
+	FormatVersion int    `json:"format_version"`
+	Type          string `json:"type"`
+	Category      string `json:"category"`
+	// Labels are the labels of the category in the order of Weights and Bias
+	Labels []string `json:"labels"`
+	// Features is the size of the TF-IDF vectors the model was trained on
+	Features  int          `json:"features"`
+	TrainedAt time.Time    `json:"trained_at"`
+	Params    TrainOptions `json:"params"`
+	Metrics   *Evaluation  `json:"metrics,omitempty"`
+	// Weights holds one row of Features weights per label
+	Weights [][]float32 `json:"weights"`
+	Bias    []float32   `json:"bias"`
+}
+
+// TrainOptions configure Train
+type TrainOptions struct {
+	// Epochs is the number of passes over the training vectors
+	Epochs int `json:"epochs"`
+	// LearningRate is the initial step size, decayed as LearningRate / sqrt(1 + epoch)
+	LearningRate float64 `json:"learning_rate"`
+	// L2 is the weight of the L2 penalty on the weights
+	L2 float64 `json:"l2"`
+	// BatchSize is the number of vectors of a gradient step
+	BatchSize int `json:"batch_size"`
+	// Seed seeds the shuffling of the vectors between epochs
+	Seed int64 `json:"seed"`
+}
+
+// DefaultTrainOptions returns the options used by `tagger train` by default
+func DefaultTrainOptions() TrainOptions {
+	return TrainOptions{Epochs: 30, LearningRate: 10, L2: 1e-6, BatchSize: 32, Seed: 42}
+}
+
+// Vector holds the nonzero entries of a TF-IDF vector by increasing index
+type Vector struct {
+	Indices []int32
+	Values  []float32
+}
+
+// Sparse returns the nonzero entries of a dense vector
+func Sparse(vector []float32) Vector {
+	var s Vector
+	for i, v := range vector {
+		if v != 0 {
+			s.Indices = append(s.Indices, int32(i))
+			s.Values = append(s.Values, v)
+		}
+	}
+	return s
+}
+
+// Train fits a model of a category on sparse TF-IDF vectors of features
+// entries, targets being indices into labels. It minimizes the mean
+// cross-entropy plus L2/2 times the squared norm of the weights by mini-batch
+// gradient descent, checking ctx between epochs.
+func Train(ctx context.Context, category string, labels []string, features int, vectors []Vector, targets []int, opts TrainOptions) (*Model, error) {
+	if len(labels) < 2 {
+		return nil, fmt.Errorf("category %s has %d labels, expected at least 2", category, len(labels))
+	}
+	if len(vectors) == 0 || len(vectors) != len(targets) {
+		return nil, fmt.Errorf("got %d vectors and %d targets, expected as many and at least one", len(vectors), len(targets))
+	}
+	if opts.Epochs <= 0 || opts.LearningRate <= 0 || opts.BatchSize <= 0 || opts.L2 < 0 {
+		return nil, fmt.Errorf("invalid training options %+v", opts)
+	}
+	for i, target := range targets {
+		if target < 0 || target >= len(labels) {
+			return nil, fmt.Errorf("target %d of vector %d is out of range [0, %d)", target, i, len(labels))
+		}
+	}
+
+	m := &Model{
+		FormatVersion: FormatVersion,
+		Type:          TypeLogisticRegression,
+		Category:      category,
+		Labels:        labels,
+		Features:      features,
+		TrainedAt:     time.Now().UTC().Truncate(time.Second),
+		Params:        opts,
+		Weights:       make([][]float32, len(labels)),
+		Bias:          make([]float32, len(labels)),
+	}
+	// Weights are trained in float64 and rounded once trained
+	weights := make([][]float64, len(labels))
+	for k := range weights {
+		weights[k] = make([]float64, features)
+	}
+	bias := make([]float64, len(labels))
+
+	order := make([]int, len(vectors))
+	for i := range order {
+		order[i] = i
+	}
+	random := rand.New(rand.NewSource(opts.Seed))
+	logits := make([]float64, len(labels))
+	biasGradient := make([]float64, len(labels))
+
+	for epoch := 0; epoch < opts.Epochs; epoch++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		random.Shuffle(len(order), func(a, b int) { order[a], order[b] = order[b], order[a] })
+		rate := opts.LearningRate / math.Sqrt(float64(1+epoch))
+
+		for start := 0; start < len(order); start += opts.BatchSize {
+			batch := order[start:min(start+opts.BatchSize, len(order))]
+			step := rate / float64(len(batch))
+
+			// The L2 penalty shrinks all weights, applied before the data term
+			if opts.L2 > 0 {
+				decay := 1 - rate*opts.L2
+				for k := range weights {
+					for j := range weights[k] {
+						weights[k][j] *= decay
+					}
+				}
+			}
+
+			clear(biasGradient)
+			for _, i := range batch {
+				vector := vectors[i]
+				scores(weights, bias, vector, logits)
+				softmaxInPlace(logits)
+				logits[targets[i]] -= 1
+				for k, gradient := range logits {
+					biasGradient[k] += gradient
+					for n, index := range vector.Indices {
+						weights[k][index] -= step * gradient * float64(vector.Values[n])
+					}
+				}
+			}
+			for k := range bias {
+				bias[k] -= step * biasGradient[k]
+			}
+		}
+	}
+
+	for k := range weights {
+		m.Weights[k] = make([]float32, features)
+		for j, w := range weights[k] {
+			m.Weights[k][j] = float32(w)
+		}
+		m.Bias[k] = float32(bias[k])
+	}
+	return m, nil
+}
+
+// scores writes the logits of a sparse vector for every label
+func scores(weights [][]float64, bias []float64, vector Vector, logits []float64) {
+	for k := range logits {
+		sum := bias[k]
+		for n, index := range vector.Indices {
+			sum += weights[k][index] * float64(vector.Values[n])
+		}
+		logits[k] = sum
+	}
+}
+
+func softmaxInPlace(logits []float64) {
+	maxLogit := math.Inf(-1)
+	for _, logit := range logits {
+		maxLogit = math.Max(maxLogit, logit)
+	}
+	var sum float64
+	for k, logit := range logits {
+		logits[k] = math.Exp(logit - maxLogit)
+		sum += logits[k]
+	}
+	for k := range logits {
+		logits[k] /= sum
+	}
+}
+
+// PredictProba returns the probabilities of the labels of a dense TF-IDF vector
+func (m *Model) PredictProba(vector []float32) []float64 {
+	logits := make([]float64, len(m.Labels))
+	for k := range logits {
+		sum := float64(m.Bias[k])
+		row := m.Weights[k]
+		for j, v := range vector {
+			if v != 0 {
+				sum += float64(row[j]) * float64(v)
+			}
+		}
+		logits[k] = sum
+	}
+	softmaxInPlace(logits)
+	return logits
+}
+
+// predictSparse is PredictProba for a sparse vector
+func (m *Model) predictSparse(vector Vector) []float64 {
+	logits := make([]float64, len(m.Labels))
+	for k := range logits {
+		sum := float64(m.Bias[k])
+		for n, index := range vector.Indices {
+			sum += float64(m.Weights[k][index]) * float64(vector.Values[n])
+		}
+		logits[k] = sum
+	}
+	softmaxInPlace(logits)
+	return logits
+}
+
+// Validate checks that the model is of a supported format and its weights
+// match its labels and features
+func (m *Model) Validate() error {
+	if m.FormatVersion < 1 || m.FormatVersion > FormatVersion {
+		return fmt.Errorf("unsupported format version %d, expected at most %d", m.FormatVersion, FormatVersion)
+	}
+	if m.Type != TypeLogisticRegression {
+		return fmt.Errorf("unsupported model type %q, expected %s", m.Type, TypeLogisticRegression)
+	}
+	if len(m.Labels) < 2 {
+		return fmt.Errorf("%d labels, expected at least 2", len(m.Labels))
+	}
+	if len(m.Weights) != len(m.Labels) || len(m.Bias) != len(m.Labels) {
+		return fmt.Errorf("%d weight rows and %d biases for %d labels", len(m.Weights), len(m.Bias), len(m.Labels))
+	}
+	for k, row := range m.Weights {
+		if len(row) != m.Features {
+			return fmt.Errorf("weight row %d has %d values, expected %d features", k, len(row), m.Features)
+		}
+	}
+	return nil
+}
+
+// Parse reads and validates a model saved by Save
+func Parse(content []byte) (*Model, error) {
+	var m Model
+	if err := json.Unmarshal(content, &m); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal linear model: %v", err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid linear model: %w", err)
+	}
+	return &m, nil
+}
+
+// Load reads a model file saved by Save
+func Load(filePath string) (*Model, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read linear model: %v", err)
+	}
+	return Parse(content)
+}
+
+// Save writes the model in the format documented in the package comment
+func (m *Model) Save(filePath string) error {
+	content, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to marshal linear model: %v", err)
+	}
+	if err := os.WriteFile(filePath, content, 0o644); err != nil {
+		return fmt.Errorf("failed to write linear model: %v", err)
+	}
+	return nil
+}
+
+// Metrics are the scores of a model on a set of labeled vectors
+type Metrics struct {
+	Samples  int     `json:"samples"`
+	Accuracy float64 `json:"accuracy"`
+	// MacroF1 is the unweighted mean F1 score of the labels found in the
+	// targets or the predictions
+	MacroF1 float64 `json:"macro_f1"`
+	LogLoss float64 `json:"log_loss"`
+}
+
+// Evaluation holds the metrics of a model on its training vectors and, if
+// some were held out, on its test vectors
+type Evaluation struct {
+	Train Metrics  `json:"train"`
+	Test  *Metrics `json:"test,omitempty"`
+}
+
+// Evaluate scores the model on sparse vectors with known targets
+func (m *Model) Evaluate(vectors []Vector, targets []int) Metrics {
+	metrics := Metrics{Samples: len(vectors)}
+	if len(vectors) == 0 {
+		return metrics
+	}
+
+	truePositives := make([]int, len(m.Labels))
+	predicted := make([]int, len(m.Labels))
+	actual := make([]int, len(m.Labels))
+	correct := 0
+	for i, vector := range vectors {
+		probabilities := m.predictSparse(vector)
+		best := 0
+		for k, p := range probabilities {
+			if p > probabilities[best] {
+				best = k
+			}
+		}
+
+		target := targets[i]
+		predicted[best]++
+		actual[target]++
+		if best == target {
+			correct++
+			truePositives[best]++
+		}
+		metrics.LogLoss -= math.Log(math.Max(probabilities[target], 1e-15))
+	}
+
+	var f1Sum float64
+	present := 0
+	for k := range m.Labels {
+		if predicted[k]+actual[k] == 0 {
+			continue
+		}
+		present++
+		f1Sum += 2 * float64(truePositives[k]) / float64(predicted[k]+actual[k])
+	}
+	metrics.Accuracy = float64(correct) / float64(len(vectors))
+	metrics.MacroF1 = f1Sum / float64(present)
+	metrics.LogLoss /= float64(len(vectors))
+	return metrics
+}
+
+// Split shuffles the indices of n samples with a seed and returns a share
+// testSize of them as the test set and the others as the training set
+func Split(n int, testSize float64, seed int64) (train, test []int) {
+	indices := rand.New(rand.NewSource(seed)).Perm(n)
+	testCount := int(math.Ceil(testSize * float64(n)))
+	return indices[testCount:], indices[:testCount]
+}
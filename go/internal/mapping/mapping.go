@@ -0,0 +1,185 @@
+// Package mapping pairs the rate names of two lists describing the rooms of
+// the same hotel, such as the exports of two suppliers, when they describe
+// the same product.
+//
+// A rate name of the first list may pair with a rate name of the second list
+// when their predicted labels agree in the constraint categories and the
+// cosine similarity of their TF-IDF vectors reaches the minimum score. Every
+// rate name of the first list is paired with its most similar candidate, and
+// with OneToOne the candidates are taken by decreasing similarity so that
+// every rate name of the second list is paired at most once.
+package mapping
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/go-goal/tagger/internal/extract"
+	"github.com/go-goal/tagger/internal/index"
+	"github.com/go-goal/tagger/internal/tfidf"
+)
+
+// DefaultConstraints are the categories describing the room itself rather
+// than how it is sold
+var DefaultConstraints = []string{"class", "capacity", "bedrooms"}
+
+// Options configure Match
+type Options struct {
+	// Constraints are the categories whose labels must agree in a pair. Empty
+	// and "undefined" labels agree with any label.
+	Constraints []string
+	// MinScore is the smallest cosine similarity of a pair
+	MinScore float64
+	// Candidates bounds the rate names of the second list satisfying the
+	// constraints considered per rate name of the first list
+	Candidates int
+	// OneToOne pairs every rate name of the second list at most once instead
+	// of with every rate name of the first list it suits best
+	OneToOne bool
+}
+
+// DefaultOptions returns the options used for nil *Options
+func DefaultOptions() Options {
+	return Options{Constraints: DefaultConstraints, MinScore: 0.5, Candidates: 20}
+}
+
+// Pair is a rate name of the first list paired with one of the second list,
+// by their positions in the lists
+type Pair struct {
+	A     int
+	B     int
+	Score float64
+}
+
+// Mapping pairs the rate names of two lists
+type Mapping struct {
+	// Pairs by increasing position in the first list
+	Pairs []Pair
+	// UnmatchedA and UnmatchedB are the positions of the rate names of each
+	// list without a pair
+	UnmatchedA []int
+	UnmatchedB []int
+}
+
+// Match pairs the rate names of a and b, whose predicted labels of the
+// constraint categories are labelsA and labelsB
+func Match(ctx context.Context, tfidfData *tfidf.TfIdfData, a, b []index.Item, labelsA, labelsB []map[string]string, opts *Options) (*Mapping, error) {
+	options := DefaultOptions()
+	if opts != nil {
+		options = *opts
+	}
+	if len(labelsA) != len(a) || len(labelsB) != len(b) {
+		return nil, fmt.Errorf("got labels for %d and %d rate names, expected %d and %d", len(labelsA), len(labelsB), len(a), len(b))
+	}
+
+	x, err := index.New(ctx, tfidfData, b, nil)
+	if err != nil {
+		return nil, err
+	}
+	queries := make([]string, len(a))
+	for i, item := range a {
+		queries[i] = item.Name
+	}
+	// The constraints filter the candidates before the most similar are
+	// taken, so rate names breaking them never crowd out the right one
+	matches, err := x.SearchFunc(ctx, queries, options.Candidates, options.MinScore, func(i, j int) bool {
+		return agree(labelsA[i], labelsB[j], options.Constraints)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Candidates are ordered by decreasing score, then by position
+	var candidates []Pair
+	for i := range matches {
+		for _, match := range matches[i] {
+			candidates = append(candidates, Pair{A: i, B: match.Entry, Score: match.Score})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+
+	pairedA := make([]bool, len(a))
+	pairedB := make([]bool, len(b))
+	mapping := &Mapping{}
+	for _, candidate := range candidates {
+		if pairedA[candidate.A] || (options.OneToOne && pairedB[candidate.B]) {
+			continue
+		}
+		pairedA[candidate.A] = true
+		pairedB[candidate.B] = true
+		mapping.Pairs = append(mapping.Pairs, candidate)
+	}
+	sort.Slice(mapping.Pairs, func(i, j int) bool {
+		return mapping.Pairs[i].A < mapping.Pairs[j].A
+	})
+	for i, paired := range pairedA {
+		if !paired {
+			mapping.UnmatchedA = append(mapping.UnmatchedA, i)
+		}
+	}
+	for i, paired := range pairedB {
+		if !paired {
+			mapping.UnmatchedB = append(mapping.UnmatchedB, i)
+		}
+	}
+	return mapping, nil
+}
+
+// agree reports whether two rate names satisfy the constraints
+func agree(a, b map[string]string, constraints []string) bool {
+	for _, category := range constraints {
+		labelA, labelB := a[category], b[category]
+		if labelA == "" || labelA == extract.Undefined || labelB == "" || labelB == extract.Undefined {
+			continue
+		}
+		if labelA != labelB {
+			return false
+		}
+	}
+	return true
+}
+
+// Columns of the rows of Table before the constraint categories
+var Columns = []string{"a_id", "a_name", "b_id", "b_name", "score"}
+
+// Table converts a mapping to rows of the pairs, then of the unmatched rate
+// names of a and of b with the fields of the other list empty, as written by
+// the CLI and the tabular API formats. The constraint categories follow with
+// the labels of a, or of b for its unmatched rate names.
+func Table(a, b []index.Item, labelsA, labelsB []map[string]string, constraints []string, mapping *Mapping) ([]string, [][]string) {
+	headers := append(append([]string{}, Columns...), constraints...)
+	row := func(i, j int, score string, labels map[string]string) []string {
+		var row []string
+		if i == -1 {
+			row = append(row, "", "")
+		} else {
+			row = append(row, a[i].ID, a[i].Name)
+		}
+		if j == -1 {
+			row = append(row, "", "")
+		} else {
+			row = append(row, b[j].ID, b[j].Name)
+		}
+		row = append(row, score)
+		for _, category := range constraints {
+			row = append(row, labels[category])
+		}
+		return row
+	}
+
+	var rows [][]string
+	for _, pair := range mapping.Pairs {
+		rows = append(rows, row(pair.A, pair.B, strconv.FormatFloat(pair.Score, 'f', 4, 64), labelsA[pair.A]))
+	}
+	for _, i := range mapping.UnmatchedA {
+		rows = append(rows, row(i, -1, "", labelsA[i]))
+	}
+	for _, j := range mapping.UnmatchedB {
+		rows = append(rows, row(-1, j, "", labelsB[j]))
+	}
+	return headers, rows
+}
@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registry holds metrics and renders them in the Prometheus text format
+type Registry struct {
+	mu      sync.Mutex
+	metrics []*metric
+}
+
+// DefaultRegistry is the registry used by the package-level constructors
+var DefaultRegistry = &Registry{}
+
+type metric struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]float64
+	labels map[string][]string
+}
+
+// Counter is a monotonically increasing metric partitioned by label values
+type Counter struct{ m *metric }
+
+// Gauge is a metric that can go up and down, partitioned by label values
+type Gauge struct{ m *metric }
+
+// NewCounter registers a counter in the default registry
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	return DefaultRegistry.NewCounter(name, help, labelNames...)
+}
+
+// NewGauge registers a gauge in the default registry
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	return DefaultRegistry.NewGauge(name, help, labelNames...)
+}
+
+// NewCounter registers a counter in the registry
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+	return &Counter{r.register(name, help, "counter", labelNames)}
+}
+
+// NewGauge registers a gauge in the registry
+func (r *Registry) NewGauge(name, help string, labelNames ...string) *Gauge {
+	return &Gauge{r.register(name, help, "gauge", labelNames)}
+}
+
+func (r *Registry) register(name, help, kind string, labelNames []string) *metric {
+	m := &metric{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		values:     make(map[string]float64),
+		labels:     make(map[string][]string),
+	}
+	r.mu.Lock()
+	r.metrics = append(r.metrics, m)
+	r.mu.Unlock()
+	return m
+}
+
+// Inc increments the counter by one
+func (c *Counter) Inc(labelValues ...string) {
+	c.m.add(1, labelValues)
+}
+
+// Add increments the counter by v
+func (c *Counter) Add(v float64, labelValues ...string) {
+	c.m.add(v, labelValues)
+}
+
+// Set sets the gauge to v
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.m.set(v, labelValues)
+}
+
+// Add changes the gauge by v
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.m.add(v, labelValues)
+}
+
+func (m *metric) key(labelValues []string) string {
+	if len(labelValues) != len(m.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", m.name, len(m.labelNames), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+func (m *metric) add(v float64, labelValues []string) {
+	key := m.key(labelValues)
+	m.mu.Lock()
+	if _, exists := m.labels[key]; !exists {
+		m.labels[key] = append([]string(nil), labelValues...)
+	}
+	m.values[key] += v
+	m.mu.Unlock()
+}
+
+func (m *metric) set(v float64, labelValues []string) {
+	key := m.key(labelValues)
+	m.mu.Lock()
+	if _, exists := m.labels[key]; !exists {
+		m.labels[key] = append([]string(nil), labelValues...)
+	}
+	m.values[key] = v
+	m.mu.Unlock()
+}
+
+// WriteText writes all metrics of the registry in the Prometheus text format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]*metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	for _, m := range metrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind); err != nil {
+			return err
+		}
+
+		m.mu.Lock()
+		keys := make([]string, 0, len(m.values))
+		for key := range m.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if _, err := fmt.Fprintf(w, "%s%s %v\n", m.name, formatLabels(m.labelNames, m.labels[key]), m.values[key]); err != nil {
+				m.mu.Unlock()
+				return err
+			}
+		}
+		m.mu.Unlock()
+	}
+	return nil
+}
+
+// WriteText writes all metrics of the default registry in the Prometheus text format
+func WriteText(w io.Writer) error {
+	return DefaultRegistry.WriteText(w)
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		value := strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(values[i])
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name, value)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,189 @@
+package model
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-goal/tagger/internal/metrics"
+)
+
+var (
+	cacheHits      = metrics.NewCounter("tagger_cache_hits_total", "Rate names answered from the prediction cache.")
+	cacheMisses    = metrics.NewCounter("tagger_cache_misses_total", "Rate names missing from the prediction cache.")
+	cacheEvictions = metrics.NewCounter("tagger_cache_evictions_total", "Prediction cache entries evicted for size or age.")
+	cacheEntries   = metrics.NewGauge("tagger_cache_entries", "Entries of the prediction cache.")
+)
+
+// Cache is a least recently used cache of the label probabilities of rate
+// names, bounded in entries and age, in front of PredictProbaContext. It is
+// safe for concurrent use.
+type Cache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// recency orders the entries from the most recently used
+	recency *list.List
+	stats   CacheStats
+}
+
+// CacheStats counts the lookups and evictions of a Cache
+type CacheStats struct {
+	Hits      int
+	Misses    int
+	Evictions int
+	Entries   int
+}
+
+// cacheEntry is an entry of a Cache and a record of its file
+type cacheEntry struct {
+	Key string
+	// Probabilities of the labels per category
+	Probabilities map[string][]float64
+	Stored        time.Time
+}
+
+// NewCache returns a cache of at most size entries, each kept at most ttl
+// or until evicted if ttl is zero
+func NewCache(size int, ttl time.Duration) *Cache {
+	return &Cache{size: size, ttl: ttl, entries: make(map[string]*list.Element), recency: list.New()}
+}
+
+// get returns the probabilities of a key
+func (c *Cache) get(key string) (map[string][]float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, exists := c.entries[key]
+	if exists && c.expired(element.Value.(*cacheEntry), time.Now()) {
+		c.remove(element)
+		exists = false
+	}
+	if !exists {
+		c.stats.Misses++
+		cacheMisses.Inc()
+		return nil, false
+	}
+	c.recency.MoveToFront(element)
+	c.stats.Hits++
+	cacheHits.Inc()
+	return element.Value.(*cacheEntry).Probabilities, true
+}
+
+// put stores the probabilities of a key, evicting the least recently used
+// entries beyond the size
+func (c *Cache) put(key string, probabilities map[string][]float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.add(&cacheEntry{Key: key, Probabilities: probabilities, Stored: time.Now()})
+}
+
+func (c *Cache) add(entry *cacheEntry) {
+	if c.size <= 0 {
+		return
+	}
+	if element, exists := c.entries[entry.Key]; exists {
+		element.Value = entry
+		c.recency.MoveToFront(element)
+		return
+	}
+	c.entries[entry.Key] = c.recency.PushFront(entry)
+	for c.recency.Len() > c.size {
+		c.remove(c.recency.Back())
+	}
+	cacheEntries.Set(float64(c.recency.Len()))
+}
+
+func (c *Cache) remove(element *list.Element) {
+	c.recency.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry).Key)
+	c.stats.Evictions++
+	cacheEvictions.Inc()
+	cacheEntries.Set(float64(c.recency.Len()))
+}
+
+func (c *Cache) expired(entry *cacheEntry, now time.Time) bool {
+	return c.ttl > 0 && now.Sub(entry.Stored) > c.ttl
+}
+
+// Stats returns the lookups and evictions since the cache was created
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.recency.Len()
+	return stats
+}
+
+// Load adds the entries of a file written by Save that have not expired. A
+// missing file leaves the cache empty.
+func (c *Cache) Load(filePath string) error {
+	file, err := os.Open(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open cache file: %v", err)
+	}
+	defer file.Close()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	decoder := gob.NewDecoder(bufio.NewReader(file))
+	for {
+		var entry cacheEntry
+		err := decoder.Decode(&entry)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read cache file %s: %v", filePath, err)
+		}
+		if !c.expired(&entry, now) {
+			c.add(&entry)
+		}
+	}
+}
+
+// Save writes the entries to a file, from the least recently used so that
+// Load restores their order, replacing the file at once
+func (c *Cache) Save(filePath string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write cache file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	encoder := gob.NewEncoder(w)
+	c.mu.Lock()
+	for element := c.recency.Back(); element != nil && err == nil; element = element.Prev() {
+		err = encoder.Encode(element.Value.(*cacheEntry))
+	}
+	c.mu.Unlock()
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return fmt.Errorf("failed to write cache file: %v", err)
+	}
+	return nil
+}
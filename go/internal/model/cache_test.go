@@ -0,0 +1,145 @@
+package model
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// keys returns the keys of a cache from the most recently used
+func keys(c *Cache) []string {
+	var keys []string
+	for element := c.recency.Front(); element != nil; element = element.Next() {
+		keys = append(keys, element.Value.(*cacheEntry).Key)
+	}
+	return keys
+}
+
+func TestCache(t *testing.T) {
+	probabilities := map[string][]float64{"class": {0.2, 0.8}}
+
+	type operation struct {
+		// get looks the key up, put stores it
+		get, put string
+		wantHit  bool
+	}
+	tests := []struct {
+		name       string
+		size       int
+		operations []operation
+		wantKeys   []string
+		wantStats  CacheStats
+	}{
+		{
+			name:       "miss then hit",
+			size:       2,
+			operations: []operation{{get: "a"}, {put: "a"}, {get: "a", wantHit: true}},
+			wantKeys:   []string{"a"},
+			wantStats:  CacheStats{Hits: 1, Misses: 1, Entries: 1},
+		},
+		{
+			name: "evicts the least recently used",
+			size: 2,
+			operations: []operation{
+				{put: "a"}, {put: "b"}, {get: "a", wantHit: true}, {put: "c"},
+				{get: "b"}, {get: "a", wantHit: true}, {get: "c", wantHit: true},
+			},
+			wantKeys:  []string{"c", "a"},
+			wantStats: CacheStats{Hits: 3, Misses: 1, Evictions: 1, Entries: 2},
+		},
+		{
+			name:       "put again refreshes",
+			size:       2,
+			operations: []operation{{put: "a"}, {put: "b"}, {put: "a"}, {put: "c"}, {get: "a", wantHit: true}},
+			wantKeys:   []string{"a", "c"},
+			wantStats:  CacheStats{Hits: 1, Evictions: 1, Entries: 2},
+		},
+		{
+			name:       "size zero disables",
+			size:       0,
+			operations: []operation{{put: "a"}, {get: "a"}},
+			wantStats:  CacheStats{Misses: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewCache(tt.size, 0)
+			for i, op := range tt.operations {
+				if op.put != "" {
+					cache.put(op.put, probabilities)
+					continue
+				}
+				if _, hit := cache.get(op.get); hit != op.wantHit {
+					t.Errorf("operation %d: get %s hit = %v, want %v", i, op.get, hit, op.wantHit)
+				}
+			}
+			if got := keys(cache); !slices.Equal(got, tt.wantKeys) {
+				t.Errorf("keys %v, want %v", got, tt.wantKeys)
+			}
+			if stats := cache.Stats(); stats != tt.wantStats {
+				t.Errorf("stats %+v, want %+v", stats, tt.wantStats)
+			}
+		})
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	cache := NewCache(10, time.Hour)
+	now := time.Now()
+	cache.add(&cacheEntry{Key: "old", Stored: now.Add(-2 * time.Hour)})
+	cache.add(&cacheEntry{Key: "new", Stored: now.Add(-time.Minute)})
+
+	if _, hit := cache.get("old"); hit {
+		t.Error("expired entry hit")
+	}
+	if _, hit := cache.get("new"); !hit {
+		t.Error("entry within the TTL missed")
+	}
+	if want := (CacheStats{Hits: 1, Misses: 1, Evictions: 1, Entries: 1}); cache.Stats() != want {
+		t.Errorf("stats %+v, want %+v", cache.Stats(), want)
+	}
+}
+
+func TestCacheSaveLoad(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "cache.gob")
+
+	cache := NewCache(10, time.Hour)
+	now := time.Now()
+	cache.add(&cacheEntry{Key: "expired", Probabilities: map[string][]float64{"class": {1, 0}}, Stored: now.Add(-2 * time.Hour)})
+	for _, key := range []string{"a", "b", "c"} {
+		cache.put(key, map[string][]float64{"class": {0.5, 0.5}})
+	}
+	cache.get("a")
+	if err := cache.Save(filePath); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		size     int
+		wantKeys []string
+	}{
+		{name: "keeps the recency order", size: 10, wantKeys: []string{"a", "c", "b"}},
+		{name: "keeps the most recently used", size: 2, wantKeys: []string{"a", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loaded := NewCache(tt.size, time.Hour)
+			if err := loaded.Load(filePath); err != nil {
+				t.Fatal(err)
+			}
+			if got := keys(loaded); !slices.Equal(got, tt.wantKeys) {
+				t.Errorf("keys %v, want %v", got, tt.wantKeys)
+			}
+			if probabilities, hit := loaded.get("a"); !hit || !slices.Equal(probabilities["class"], []float64{0.5, 0.5}) {
+				t.Errorf("get a = %v, %v, want the saved probabilities", probabilities, hit)
+			}
+		})
+	}
+
+	if err := NewCache(10, 0).Load(filepath.Join(t.TempDir(), "missing.gob")); err != nil {
+		t.Errorf("Load of a missing file: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	cb "github.com/go-goal/tagger/internal/catboost"
+	"github.com/go-goal/tagger/internal/extract"
+	"github.com/go-goal/tagger/internal/linear"
+)
+
+// classifier is the model of a category, a catboost or a linear model
+type classifier interface {
+	// predict returns the label probabilities of every input given with its
+	// TF-IDF vector
+	predict(ctx context.Context, inputs []string, floats [][]float32, labels []string) ([][]float64, error)
+	// check verifies the model against the labels file of its category and
+	// the size of the TF-IDF vectors
+	check(labels []string, features int) error
+}
+
+type catboostModel struct {
+	*cb.Model
+}
+
+func (m catboostModel) predict(ctx context.Context, inputs []string, floats [][]float32, labels []string) ([][]float64, error) {
+	return predictCategory(ctx, m.Model, floats, labels)
+}
+
+// check is a no-op, catboost models are checked by `tagger artifacts validate`
+func (m catboostModel) check(labels []string, features int) error {
+	return nil
+}
+
+type linearModel struct {
+	*linear.Model
+}
+
+func (m linearModel) predict(ctx context.Context, inputs []string, floats [][]float32, labels []string) ([][]float64, error) {
+	probabilities := make([][]float64, len(floats))
+	for i, vector := range floats {
+		if i%PredictBatchSize == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		probabilities[i] = m.PredictProba(vector)
+	}
+	return probabilities, nil
+}
+
+func (m linearModel) check(labels []string, features int) error {
+	if !slices.Equal(m.Labels, labels) {
+		return fmt.Errorf("linear model labels %v do not match labels file %v", m.Labels, labels)
+	}
+	if m.Features != features {
+		return fmt.Errorf("linear model expects %d features, TF-IDF has %d", m.Features, features)
+	}
+	return nil
+}
+
+// extractorModel serves a category of package extract: inputs matching a
+// pattern get its label with probability 1, the model predicts the others,
+// or they are undefined if the category has no model
+type extractorModel struct {
+	classifier
+	extractor *extract.Extractor
+}
+
+func (m extractorModel) predict(ctx context.Context, inputs []string, floats [][]float32, labels []string) ([][]float64, error) {
+	var probabilities [][]float64
+	if m.classifier != nil {
+		var err error
+		probabilities, err = m.classifier.predict(ctx, inputs, floats, labels)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		probabilities = make([][]float64, len(inputs))
+		for i := range probabilities {
+			probabilities[i] = make([]float64, len(labels))
+			probabilities[i][slices.Index(labels, extract.Undefined)] = 1
+		}
+	}
+	for i, input := range inputs {
+		if label, ok := m.extractor.Extract(input); ok {
+			extracted := make([]float64, len(labels))
+			extracted[slices.Index(labels, label)] = 1
+			probabilities[i] = extracted
+		}
+	}
+	return probabilities, nil
+}
+
+func (m extractorModel) check(labels []string, features int) error {
+	if !slices.Equal(m.extractor.Labels, labels) {
+		return fmt.Errorf("labels file %v does not match the vocabulary %v of the %s extractor", labels, m.extractor.Labels, m.extractor.Category)
+	}
+	if m.classifier == nil {
+		return nil
+	}
+	return m.classifier.check(labels, features)
+}
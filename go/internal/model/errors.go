@@ -0,0 +1,47 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CategoryError reports a failure to load or predict a single category
+type CategoryError struct {
+	Category string
+	Err      error
+}
+
+func (e *CategoryError) Error() string {
+	return fmt.Sprintf("category %s: %v", e.Category, e.Err)
+}
+
+func (e *CategoryError) Unwrap() error {
+	return e.Err
+}
+
+// CategoryErrors returns the category errors contained in err, which may be
+// a single *CategoryError or several of them joined with errors.Join
+func CategoryErrors(err error) []*CategoryError {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var categoryErrs []*CategoryError
+		for _, e := range joined.Unwrap() {
+			categoryErrs = append(categoryErrs, CategoryErrors(e)...)
+		}
+		return categoryErrs
+	}
+
+	var categoryErr *CategoryError
+	if errors.As(err, &categoryErr) {
+		return []*CategoryError{categoryErr}
+	}
+	return nil
+}
+
+// FailedCategories returns the names of the categories that failed in err
+func FailedCategories(err error) []string {
+	var categories []string
+	for _, categoryErr := range CategoryErrors(err) {
+		categories = append(categories, categoryErr.Category)
+	}
+	return categories
+}
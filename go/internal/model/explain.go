@@ -0,0 +1,107 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/go-goal/tagger/internal/tfidf"
+)
+
+// Explanation reports how much each n-gram of an input contributed to the
+// label predicted for a category
+type Explanation struct {
+	Input       string  `json:"input"`
+	Category    string  `json:"category"`
+	Label       string  `json:"label"`
+	Probability float64 `json:"probability"`
+	// Contributions are ordered by decreasing absolute Delta
+	Contributions []Contribution `json:"contributions"`
+}
+
+// Contribution is the effect of leaving a single n-gram out of the input
+type Contribution struct {
+	NGram string `json:"ngram"`
+	// Index is the TF-IDF vector index of the n-gram
+	Index int `json:"index"`
+	// Weight is the normalized TF-IDF weight of the n-gram in the input
+	Weight float32 `json:"weight"`
+	// Delta is the probability of the predicted label minus its probability
+	// without the n-gram, positive if the n-gram supports the label
+	Delta float64 `json:"delta"`
+	// LabelWithout is the label predicted without the n-gram
+	LabelWithout string `json:"label_without"`
+}
+
+// ExplainContext explains the prediction of input for a category by leave-one-out:
+// every n-gram present in the TF-IDF vector is removed in turn, the vector is
+// renormalized and the drop in probability of the predicted label is reported.
+// All variants are predicted in a single batch. Labels found by the extractor
+// of a category do not depend on single n-grams, all their deltas are zero.
+func (p *Predictor) ExplainContext(ctx context.Context, input string, category string) (*Explanation, error) {
+	model, labels, err := p.loaded(category)
+	if err != nil {
+		return nil, &CategoryError{Category: category, Err: err}
+	}
+
+	vector := tfidf.CalculateTfIdfVector(input, p.TfidfData)
+	var present []int
+	for i, weight := range vector {
+		if weight != 0 {
+			present = append(present, i)
+		}
+	}
+
+	floats := make([][]float32, 0, len(present)+1)
+	floats = append(floats, vector)
+	for _, index := range present {
+		ablated := append([]float32(nil), vector...)
+		ablated[index] = 0
+		tfidf.Normalize(ablated)
+		floats = append(floats, ablated)
+	}
+
+	inputs := make([]string, len(floats))
+	for i := range inputs {
+		inputs[i] = input
+	}
+	probabilities, err := model.predict(ctx, inputs, floats, labels)
+	if err != nil {
+		return nil, &CategoryError{Category: category, Err: fmt.Errorf("error predicting: %w", err)}
+	}
+
+	best := BestLabel(probabilities[0])
+	explanation := &Explanation{
+		Input:         input,
+		Category:      category,
+		Label:         labels[best],
+		Probability:   probabilities[0][best],
+		Contributions: make([]Contribution, len(present)),
+	}
+
+	terms := p.terms()
+	for i, index := range present {
+		without := probabilities[i+1]
+		explanation.Contributions[i] = Contribution{
+			NGram:        terms[index],
+			Index:        index,
+			Weight:       vector[index],
+			Delta:        probabilities[0][best] - without[best],
+			LabelWithout: labels[BestLabel(without)],
+		}
+	}
+	sort.SliceStable(explanation.Contributions, func(a, b int) bool {
+		return math.Abs(explanation.Contributions[a].Delta) > math.Abs(explanation.Contributions[b].Delta)
+	})
+
+	return explanation, nil
+}
+
+// terms returns the n-gram of every TF-IDF vector index, inverting the vocabulary once
+func (p *Predictor) terms() []string {
+	p.termsOnce.Do(func() {
+		p.termsByIndex = tfidf.Terms(p.TfidfData)
+	})
+	return p.termsByIndex
+}
@@ -1,15 +1,24 @@
 package model
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"math"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"unsafe"
 
 	cb "github.com/go-goal/tagger/internal/catboost"
+	"github.com/go-goal/tagger/internal/extract"
+	"github.com/go-goal/tagger/internal/linear"
+	"github.com/go-goal/tagger/internal/metrics"
 	"github.com/go-goal/tagger/internal/tfidf"
 )
 
@@ -18,13 +27,48 @@ import "C"
 
 const Eps float64 = 1e-8
 
+// PredictBatchSize is the number of inputs passed to a single catboost call
+const PredictBatchSize = 1024
+
+var (
+	predictedInputs = metrics.NewCounter("tagger_predict_inputs_total", "Rate names predicted, duplicates included.")
+	predictedUnique = metrics.NewCounter("tagger_predict_unique_inputs_total", "Rate names predicted once per call after normalization.")
+)
+
 type Predictor struct {
-	TfidfData    *tfidf.TfIdfData
-	ModelsDir    string
-	LabelsDir    string
-	Categories   []string
-	loadedModels map[string]*cb.Model
+	TfidfData  *tfidf.TfIdfData
+	ModelsDir  string
+	LabelsDir  string
+	Categories []string
+	// LinearModelsDir holds the linear models served for the categories
+	// without a catboost model in ModelsDir, none if empty
+	LinearModelsDir string
+	// FS is the file system ModelsDir and LabelsDir are resolved in,
+	// nil means the OS file system
+	FS fs.FS
+	// Concurrency bounds the number of categories loaded or predicted at
+	// the same time, zero means one goroutine per category
+	Concurrency int
+	// BestEffort keeps LoadModelsContext and PredictAllContext going when a
+	// category fails and returns the results of the categories that
+	// succeeded along with the errors of the others, instead of cancelling
+	// the remaining categories on the first failure
+	BestEffort bool
+	// Cache holds the probabilities of the rate names already predicted,
+	// nil disables caching
+	Cache *Cache
+	// Version identifies the loaded models in the keys of Cache
+	Version string
+
+	statsMu    sync.Mutex
+	dedupStats DedupStats
+
+	mu           sync.RWMutex
+	loadedModels map[string]classifier
 	loadedLabels map[string][]string
+
+	termsOnce    sync.Once
+	termsByIndex []string
 }
 
 func NewPredictor(tfidfData *tfidf.TfIdfData, modelsDir, labelsDir string, categories []string) *Predictor {
@@ -33,111 +77,413 @@ func NewPredictor(tfidfData *tfidf.TfIdfData, modelsDir, labelsDir string, categ
 		ModelsDir:    modelsDir,
 		LabelsDir:    labelsDir,
 		Categories:   categories,
-		loadedModels: make(map[string]*cb.Model),
+		loadedModels: make(map[string]classifier),
 		loadedLabels: make(map[string][]string),
 	}
 }
 
-func (p *Predictor) LoadModels() error {
-	var wg sync.WaitGroup
-	errChan := make(chan error, len(p.Categories))
-	var mu sync.Mutex
+// NewPredictorFS creates a Predictor loading artifacts from fsys, with
+// modelsDir and labelsDir given as slash-separated paths inside it
+func NewPredictorFS(tfidfData *tfidf.TfIdfData, fsys fs.FS, modelsDir, labelsDir string, categories []string) *Predictor {
+	p := NewPredictor(tfidfData, modelsDir, labelsDir, categories)
+	p.FS = fsys
+	return p
+}
 
-	for _, category := range p.Categories {
-		wg.Add(1)
-		go func(cat string) {
-			defer wg.Done()
-			modelPath := filepath.Join(p.ModelsDir, fmt.Sprintf("catboost_model_%s.cbm", cat))
-			model, err := cb.LoadFullModelFromFile(modelPath)
-			if err != nil {
-				errChan <- fmt.Errorf("error loading model for %s: %v", cat, err)
-				return
-			}
+func (p *Predictor) LoadModels() error {
+	return p.LoadModelsContext(context.Background())
+}
 
-			labelsPath := filepath.Join(p.LabelsDir, fmt.Sprintf("labels_%s.json", cat))
-			labels, err := loadLabels(labelsPath)
-			if err != nil {
-				errChan <- fmt.Errorf("error loading labels for %s: %v", cat, err)
-				return
-			}
+// LoadModelsContext loads the model and labels of every category. Loads that
+// have not started yet are skipped once ctx is done. The returned error joins
+// a *CategoryError for every category that failed.
+func (p *Predictor) LoadModelsContext(ctx context.Context) error {
+	return p.forEachCategory(ctx, p.Categories, p.BestEffort, func(ctx context.Context, cat string) error {
+		model, err := p.loadModel(cat)
+		extractor, extracted := extract.Lookup(cat)
+		if extracted && errors.Is(err, fs.ErrNotExist) {
+			// The patterns alone serve a category of package extract
+			model, err = nil, nil
+		}
+		if err != nil {
+			return fmt.Errorf("error loading model: %w", err)
+		}
+		if extracted {
+			model = extractorModel{classifier: model, extractor: extractor}
+		}
 
-			mu.Lock()
-			p.loadedModels[cat] = model
-			p.loadedLabels[cat] = labels
-			mu.Unlock()
-		}(category)
-	}
+		labelsContent, err := p.readFile(p.LabelsDir, fmt.Sprintf("labels_%s.json", cat))
+		if err != nil {
+			return fmt.Errorf("error loading labels: failed to read labels file: %w", err)
+		}
+		labels, err := parseLabels(labelsContent)
+		if err != nil {
+			return fmt.Errorf("error loading labels: %w", err)
+		}
+		if err := model.check(labels, len(p.TfidfData.IdfValues)); err != nil {
+			return fmt.Errorf("error loading model: %w", err)
+		}
 
-	wg.Wait()
-	close(errChan)
+		p.mu.Lock()
+		p.loadedModels[cat] = model
+		p.loadedLabels[cat] = labels
+		p.mu.Unlock()
+		return nil
+	})
+}
 
-	for err := range errChan {
+// loadModel loads the catboost model of a category, or its linear model if
+// it has no catboost model and LinearModelsDir is set
+func (p *Predictor) loadModel(category string) (classifier, error) {
+	modelContent, err := p.readFile(p.ModelsDir, fmt.Sprintf("catboost_model_%s.cbm", category))
+	if errors.Is(err, fs.ErrNotExist) && p.LinearModelsDir != "" {
+		linearContent, linearErr := p.readFile(p.LinearModelsDir, fmt.Sprintf("linear_model_%s.json", category))
+		if errors.Is(linearErr, fs.ErrNotExist) {
+			return nil, err
+		}
+		if linearErr != nil {
+			return nil, linearErr
+		}
+		model, err := linear.Parse(linearContent)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		return linearModel{model}, nil
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	model, err := cb.LoadFullModelFromBuffer(modelContent)
+	if err != nil {
+		return nil, err
+	}
+	return catboostModel{model}, nil
+}
+
+// readFile reads a file of dir from FS or from the OS file system
+func (p *Predictor) readFile(dir, name string) ([]byte, error) {
+	if p.FS != nil {
+		return fs.ReadFile(p.FS, path.Join(dir, name))
+	}
+	return os.ReadFile(filepath.Join(dir, name))
+}
+
+// Labels returns the labels of a loaded category in the order of the
+// probabilities returned by PredictProbaContext
+func (p *Predictor) Labels(category string) ([]string, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	labels, exists := p.loadedLabels[category]
+	return labels, exists
+}
+
+// Model returns the catboost model of a loaded category, false if the
+// category is not loaded or is not served by a catboost model alone
+func (p *Predictor) Model(category string) (*cb.Model, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	model, exists := p.loadedModels[category].(catboostModel)
+	return model.Model, exists
 }
 
 func (p *Predictor) PredictAll(inputStrings []string) (map[string]map[string]string, error) {
-	floats := tfidf.CalculateTfIdfVectors(inputStrings, p.TfidfData)
+	return p.PredictAllContext(context.Background(), inputStrings)
+}
+
+// PredictAllContext is like PredictAll but stops vectorization and the
+// remaining prediction batches as soon as ctx is done. The returned error
+// joins a *CategoryError for every category that failed. In BestEffort mode
+// the results of the categories that succeeded are returned along with it.
+func (p *Predictor) PredictAllContext(ctx context.Context, inputStrings []string) (map[string]map[string]string, error) {
+	probabilities, err := p.PredictProbaContext(ctx, inputStrings, p.Categories, p.BestEffort)
+	if probabilities == nil {
+		return nil, err
+	}
 
 	results := make(map[string]map[string]string)
 	for _, input := range inputStrings {
 		results[input] = make(map[string]string)
 	}
 
-	var wg sync.WaitGroup
-	errChan := make(chan error, len(p.Categories))
-	
+	for cat, probs := range probabilities {
+		labels, _ := p.Labels(cat)
+		for i, prob := range probs {
+			results[inputStrings[i]][cat] = labels[BestLabel(prob)]
+		}
+	}
+
+	return results, err
+}
+
+// PredictProbaContext returns for each of the given categories the
+// probabilities of its labels, in the order of Labels, for every input.
+// Errors behave as in PredictAllContext, bestEffort taking the place of
+// BestEffort for this call only. Inputs with the same
+// normalized form, lowercased without accents and with runs of whitespace
+// collapsed, are vectorized and predicted once and share their probabilities.
+// Inputs found in Cache are not predicted again.
+func (p *Predictor) PredictProbaContext(ctx context.Context, inputStrings []string, categories []string, bestEffort bool) (map[string][][]float64, error) {
+	// Inputs the models cannot tell apart are predicted once
+	keys := make([]string, len(inputStrings))
+	positions := make([]int, len(inputStrings))
+	uniqueIndex := make(map[string]int)
+	var unique, uniqueKeys []string
+	for i, input := range inputStrings {
+		keys[i] = strings.Join(strings.Fields(tfidf.Preprocess(input)), " ")
+		position, exists := uniqueIndex[keys[i]]
+		if !exists {
+			position = len(unique)
+			uniqueIndex[keys[i]] = position
+			unique = append(unique, input)
+			uniqueKeys = append(uniqueKeys, keys[i])
+		}
+		positions[i] = position
+	}
+	p.countInputs(len(inputStrings), len(unique))
+
+	var probabilities map[string][][]float64
+	var err error
+	if p.Cache != nil {
+		probabilities, err = p.predictCached(ctx, unique, uniqueKeys, categories, bestEffort)
+	} else {
+		probabilities, err = p.predictProba(ctx, unique, categories, bestEffort)
+	}
+	if probabilities == nil || len(unique) == len(inputStrings) {
+		return probabilities, err
+	}
+
+	// Duplicates share the probabilities of their first occurrence
+	results := make(map[string][][]float64, len(probabilities))
+	for cat, probs := range probabilities {
+		results[cat] = make([][]float64, len(inputStrings))
+		for i, position := range positions {
+			results[cat][i] = probs[position]
+		}
+	}
+	return results, err
+}
+
+// DedupStats counts the inputs predicted and the unique ones among them,
+// their normalized forms compared within each call
+type DedupStats struct {
+	Inputs int
+	Unique int
+}
+
+// Ratio returns the share of unique inputs, 1 without inputs
+func (s DedupStats) Ratio() float64 {
+	if s.Inputs == 0 {
+		return 1
+	}
+	return float64(s.Unique) / float64(s.Inputs)
+}
+
+// DedupStats returns the inputs predicted since the Predictor was created
+func (p *Predictor) DedupStats() DedupStats {
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+	return p.dedupStats
+}
+
+func (p *Predictor) countInputs(inputs, unique int) {
+	p.statsMu.Lock()
+	p.dedupStats.Inputs += inputs
+	p.dedupStats.Unique += unique
+	p.statsMu.Unlock()
+	predictedInputs.Add(float64(inputs))
+	predictedUnique.Add(float64(unique))
+}
+
+// predictCached answers the inputs found in Cache and predicts the others,
+// caching them if every category succeeded. Keys are the normalized inputs
+// with the categories and Version. Categories that failed in best-effort mode
+// are left out of the results, also for the cached inputs.
+func (p *Predictor) predictCached(ctx context.Context, inputStrings, normalized []string, categories []string, bestEffort bool) (map[string][][]float64, error) {
+	prefix := p.Version + "\x00" + strings.Join(slices.Sorted(slices.Values(categories)), ",") + "\x00"
+	keys := make([]string, len(inputStrings))
+	cached := make([]map[string][]float64, len(inputStrings))
+	var missing []string
+	var missingIndices []int
+	for i, input := range inputStrings {
+		keys[i] = prefix + normalized[i]
+		if probabilities, exists := p.Cache.get(keys[i]); exists {
+			cached[i] = probabilities
+		} else {
+			missing = append(missing, input)
+			missingIndices = append(missingIndices, i)
+		}
+	}
+
+	var predicted map[string][][]float64
+	var err error
+	if len(missing) > 0 {
+		predicted, err = p.predictProba(ctx, missing, categories, bestEffort)
+		if predicted == nil {
+			return nil, err
+		}
+	}
+
+	results := make(map[string][][]float64, len(categories))
+	for _, cat := range categories {
+		probs, exists := predicted[cat]
+		if !exists && len(missing) > 0 {
+			continue
+		}
+		results[cat] = make([][]float64, len(inputStrings))
+		for i, probabilities := range cached {
+			if probabilities != nil {
+				results[cat][i] = probabilities[cat]
+			}
+		}
+		for j, i := range missingIndices {
+			results[cat][i] = probs[j]
+		}
+	}
+
+	if err == nil {
+		for j, i := range missingIndices {
+			probabilities := make(map[string][]float64, len(categories))
+			for _, cat := range categories {
+				probabilities[cat] = predicted[cat][j]
+			}
+			p.Cache.put(keys[i], probabilities)
+		}
+	}
+	return results, err
+}
+
+// predictProba predicts the probabilities of every input with the models
+func (p *Predictor) predictProba(ctx context.Context, inputStrings []string, categories []string, bestEffort bool) (map[string][][]float64, error) {
+	floats, err := tfidf.CalculateTfIdfVectorsContext(ctx, inputStrings, p.TfidfData)
+	if err != nil {
+		return nil, fmt.Errorf("error vectorizing inputs: %w", err)
+	}
+
+	results := make(map[string][][]float64, len(categories))
+
 	// Create a mutex to protect concurrent writes to the results map
 	var resultsMutex sync.Mutex
 
-	for _, category := range p.Categories {
+	err = p.forEachCategory(ctx, categories, bestEffort, func(ctx context.Context, cat string) error {
+		model, labels, err := p.loaded(cat)
+		if err != nil {
+			return err
+		}
+
+		probabilities, err := model.predict(ctx, inputStrings, floats, labels)
+		if err != nil {
+			return fmt.Errorf("error predicting: %w", err)
+		}
+
+		// Use the mutex to safely write to the results map
+		resultsMutex.Lock()
+		results[cat] = probabilities
+		resultsMutex.Unlock()
+		return nil
+	})
+	if err != nil && !bestEffort {
+		return nil, err
+	}
+
+	return results, err
+}
+
+// loaded returns the model and labels loaded for a category
+func (p *Predictor) loaded(category string) (classifier, []string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	model, exists := p.loadedModels[category]
+	if !exists {
+		return nil, nil, errors.New("model not loaded")
+	}
+
+	labels, exists := p.loadedLabels[category]
+	if !exists {
+		return nil, nil, errors.New("labels not loaded")
+	}
+
+	return model, labels, nil
+}
+
+// forEachCategory runs fn for every category with at most Concurrency calls
+// at a time. Unless bestEffort is set, the first failure cancels the context
+// of the other calls. Errors are joined in category order.
+func (p *Predictor) forEachCategory(ctx context.Context, categories []string, bestEffort bool, fn func(ctx context.Context, category string) error) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	limit := p.Concurrency
+	if limit <= 0 || limit > len(categories) {
+		limit = len(categories)
+	}
+	slots := make(chan struct{}, limit)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := false
+	errs := make([]error, len(categories))
+
+	for i, category := range categories {
 		wg.Add(1)
-		go func(cat string) {
+		go func(i int, cat string) {
 			defer wg.Done()
-			model, exists := p.loadedModels[cat]
-			if !exists {
-				errChan <- fmt.Errorf("model not loaded for category: %s", cat)
-				return
-			}
 
-			labels, exists := p.loadedLabels[cat]
-			if !exists {
-				errChan <- fmt.Errorf("labels not loaded for category: %s", cat)
+			var err error
+			select {
+			case slots <- struct{}{}:
+				if err = runCtx.Err(); err == nil {
+					err = fn(runCtx, cat)
+				}
+				<-slots
+			case <-runCtx.Done():
+				err = runCtx.Err()
+			}
+			if err == nil {
 				return
 			}
 
-			predictions, err := predictCategory(model, floats, labels)
-			if err != nil {
-				errChan <- fmt.Errorf("error predicting for %s: %v", cat, err)
+			mu.Lock()
+			defer mu.Unlock()
+			// Cancellations caused by another category failing first are not reported
+			if failed && ctx.Err() == nil && errors.Is(err, context.Canceled) {
 				return
 			}
-
-			// Use the mutex to safely write to the results map
-			resultsMutex.Lock()
-			for i, prediction := range predictions {
-				results[inputStrings[i]][cat] = prediction
+			errs[i] = &CategoryError{Category: cat, Err: err}
+			if !bestEffort {
+				failed = true
+				cancel()
 			}
-			resultsMutex.Unlock()
-		}(category)
+		}(i, category)
 	}
 
 	wg.Wait()
-	close(errChan)
+	return errors.Join(errs...)
+}
 
-	for err := range errChan {
+// predictCategory predicts label probabilities in batches of PredictBatchSize
+// inputs, checking ctx between batches since a catboost call cannot be interrupted.
+func predictCategory(ctx context.Context, model *cb.Model, floats [][]float32, labels []string) ([][]float64, error) {
+	probabilities := make([][]float64, 0, len(floats))
+	for start := 0; start < len(floats); start += PredictBatchSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		end := min(start+PredictBatchSize, len(floats))
+		batch, err := predictBatch(model, floats[start:end], labels)
 		if err != nil {
 			return nil, err
-			}
+		}
+		probabilities = append(probabilities, batch...)
 	}
-
-	return results, nil
+	return probabilities, nil
 }
 
-func predictCategory(model *cb.Model, floats [][]float32, labels []string) ([]string, error) {
+// predictBatch returns the label probabilities of every input. Binary models
+// output one logit per input, giving [1-p, p]; multiclass models one per label.
+func predictBatch(model *cb.Model, floats [][]float32, labels []string) ([][]float64, error) {
 	floatsC := cb.MakeFloatArray2D(floats)
 	defer C.free(unsafe.Pointer(floatsC))
 
@@ -146,15 +492,14 @@ func predictCategory(model *cb.Model, floats [][]float32, labels []string) ([]st
 		return nil, fmt.Errorf("error predicting: %v", err)
 	}
 
-	predictions := make([]string, len(floats))
+	probabilities := make([][]float64, len(floats))
 	if len(labels) == 2 {
-		for i, logit := range predicted {
-			probability := 1.0 / (1.0 + math.Exp(-logit))
-			if probability >= 0.5 {
-				predictions[i] = labels[1]
-			} else {
-				predictions[i] = labels[0]
-			}
+		if len(predicted) < len(floats) {
+			return nil, fmt.Errorf("insufficient logits for %v inputs", len(floats))
+		}
+		for i := range floats {
+			probability := 1.0 / (1.0 + math.Exp(-predicted[i]))
+			probabilities[i] = []float64{1 - probability, probability}
 		}
 	} else {
 		numClasses := len(labels)
@@ -165,21 +510,28 @@ func predictCategory(model *cb.Model, floats [][]float32, labels []string) ([]st
 				return nil, fmt.Errorf("insufficient logits for input %v", i)
 			}
 			logits := predicted[start:end]
-			probs := softmax(logits)
-			predictions[i] = labels[argmax(probs)]
+			probabilities[i] = softmax(logits)
 		}
 	}
 
-	return predictions, nil
+	return probabilities, nil
 }
 
-func loadLabels(filePath string) ([]string, error) {
-	var labels []string
-	fileContent, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read labels file: %v", err)
+// BestLabel returns the index of the predicted label: the positive label of a
+// binary category when its probability is at least 0.5, the most probable otherwise
+func BestLabel(probabilities []float64) int {
+	if len(probabilities) == 2 {
+		if probabilities[1] >= 0.5 {
+			return 1
+		}
+		return 0
 	}
-	err = json.Unmarshal(fileContent, &labels)
+	return argmax(probabilities)
+}
+
+func parseLabels(fileContent []byte) ([]string, error) {
+	var labels []string
+	err := json.Unmarshal(fileContent, &labels)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal labels: %v", err)
 	}
@@ -0,0 +1,149 @@
+// Package sampling selects the rate names worth labeling by hand: those the
+// models are least certain about, skipping near-duplicates of rate names
+// already selected.
+package sampling
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Uncertainty measures
+const (
+	// LeastConfidence is one minus the probability of the most likely label
+	LeastConfidence = "least-confidence"
+	// Margin is one minus the difference between the two most likely labels
+	Margin = "margin"
+	// Entropy is the entropy of the label distribution divided by its
+	// maximum, the logarithm of the number of labels
+	Entropy = "entropy"
+)
+
+// Strategies lists the uncertainty measures
+var Strategies = []string{LeastConfidence, Margin, Entropy}
+
+// Aggregations of the uncertainty of the categories of a rate name
+const (
+	Mean = "mean"
+	Max  = "max"
+)
+
+// Uncertainty returns the uncertainty of a label distribution in [0, 1],
+// probabilities being sorted in decreasing order
+func Uncertainty(strategy string, probabilities []float64) (float64, error) {
+	if len(probabilities) == 0 {
+		return 0, nil
+	}
+
+	switch strategy {
+	case LeastConfidence:
+		return 1 - probabilities[0], nil
+	case Margin:
+		if len(probabilities) == 1 {
+			return 0, nil
+		}
+		return 1 - (probabilities[0] - probabilities[1]), nil
+	case Entropy:
+		if len(probabilities) == 1 {
+			return 0, nil
+		}
+		var entropy float64
+		for _, p := range probabilities {
+			if p > 0 {
+				entropy -= p * math.Log(p)
+			}
+		}
+		return entropy / math.Log(float64(len(probabilities))), nil
+	}
+	return 0, fmt.Errorf("unknown uncertainty strategy %s, expected one of %v", strategy, Strategies)
+}
+
+// Aggregate combines the uncertainties of the categories of a rate name
+func Aggregate(aggregation string, uncertainties []float64) (float64, error) {
+	if len(uncertainties) == 0 {
+		return 0, nil
+	}
+
+	switch aggregation {
+	case Mean:
+		var sum float64
+		for _, u := range uncertainties {
+			sum += u
+		}
+		return sum / float64(len(uncertainties)), nil
+	case Max:
+		max := uncertainties[0]
+		for _, u := range uncertainties[1:] {
+			max = math.Max(max, u)
+		}
+		return max, nil
+	}
+	return 0, fmt.Errorf("unknown aggregation %s, expected %s or %s", aggregation, Mean, Max)
+}
+
+// sparseVector holds the nonzero entries of a TF-IDF vector by increasing index
+type sparseVector struct {
+	indices []int32
+	values  []float32
+}
+
+// dot returns the cosine similarity of two L2-normalized vectors
+func dot(a, b sparseVector) float64 {
+	var sum float64
+	for i, j := 0, 0; i < len(a.indices) && j < len(b.indices); {
+		switch {
+		case a.indices[i] < b.indices[j]:
+			i++
+		case a.indices[i] > b.indices[j]:
+			j++
+		default:
+			sum += float64(a.values[i]) * float64(b.values[j])
+			i++
+			j++
+		}
+	}
+	return sum
+}
+
+// Select returns the indices of at most n rate names by decreasing score. A
+// rate name is skipped if the cosine similarity of its L2-normalized TF-IDF
+// vector with a rate name already selected reaches maxSimilarity, so every
+// group of near-duplicates is represented by its most uncertain member.
+// vector returns the nonzero entries of the vector of a rate name by
+// increasing index, and is only called for the rate names visited until n
+// are selected.
+func Select(scores []float64, vector func(i int) ([]int32, []float32), n int, maxSimilarity float64) []int {
+	order := make([]int, len(scores))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scores[order[a]] > scores[order[b]]
+	})
+
+	var selected []int
+	var selectedVectors []sparseVector
+	for _, i := range order {
+		if len(selected) >= n {
+			break
+		}
+
+		var v sparseVector
+		v.indices, v.values = vector(i)
+		duplicate := false
+		for _, other := range selectedVectors {
+			if dot(v, other) >= maxSimilarity {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+
+		selected = append(selected, i)
+		selectedVectors = append(selectedVectors, v)
+	}
+	return selected
+}
@@ -0,0 +1,165 @@
+package tfidf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Frequency bounds the document frequency of a term, as the int and float
+// min_df and max_df of scikit-learn: a number of documents, or a proportion
+// of the documents
+type Frequency struct {
+	Value      float64
+	Proportion bool
+}
+
+// Documents returns a frequency of n documents
+func Documents(n int) Frequency {
+	return Frequency{Value: float64(n)}
+}
+
+// Proportion returns a frequency of a proportion of the documents in [0, 1]
+func Proportion(p float64) Frequency {
+	return Frequency{Value: p, Proportion: true}
+}
+
+// ParseFrequency parses a number of documents ("5") or, if it has a decimal
+// point, a proportion of the documents ("0.95")
+func ParseFrequency(value string) (Frequency, error) {
+	if strings.Contains(value, ".") {
+		p, err := strconv.ParseFloat(value, 64)
+		if err != nil || p < 0 || p > 1 {
+			return Frequency{}, fmt.Errorf("invalid document proportion %q, expected a number in [0.0, 1.0]", value)
+		}
+		return Proportion(p), nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return Frequency{}, fmt.Errorf("invalid document count %q, expected a non-negative integer", value)
+	}
+	return Documents(n), nil
+}
+
+func (f Frequency) String() string {
+	if f.Proportion {
+		return strconv.FormatFloat(f.Value, 'f', -1, 64)
+	}
+	return strconv.Itoa(int(f.Value))
+}
+
+// documents returns the number of documents of the frequency in a corpus
+func (f Frequency) documents(corpus int) float64 {
+	if f.Proportion {
+		return f.Value * float64(corpus)
+	}
+	return f.Value
+}
+
+// FitOptions configure Fit, the zero value keeps every term
+type FitOptions struct {
+	// MinDF drops terms found in fewer documents, 1 document if zero
+	MinDF Frequency
+	// MaxDF drops terms found in more documents, all documents if zero
+	MaxDF Frequency
+	// MaxFeatures keeps the terms most frequent in the corpus, all if zero
+	MaxFeatures int
+}
+
+// Fit builds the vocabulary and IDF values of a corpus as scikit-learn's
+// TfidfVectorizer(analyzer="char_wb", ngram_range=(1, 3),
+// strip_accents="unicode", sublinear_tf=True, smooth_idf=True) does:
+//   - documents are preprocessed and split into n-grams as by CalculateTfIdfVector
+//   - terms outside [MinDF, MaxDF] documents are dropped
+//   - MaxFeatures keeps the terms with the highest counts over the corpus,
+//     ties broken alphabetically
+//   - terms are indexed in alphabetical order
+//   - the IDF of a term found in df of n documents is ln((1 + n) / (1 + df)) + 1
+//
+// Sublinear TF is applied when vectorizing.
+func Fit(ctx context.Context, corpus []string, opts *FitOptions) (TfIdfData, error) {
+	if opts == nil {
+		opts = &FitOptions{}
+	}
+	if len(corpus) == 0 {
+		return TfIdfData{}, fmt.Errorf("empty corpus")
+	}
+
+	documentFrequencies := make(map[string]int)
+	termCounts := make(map[string]int)
+	for i, document := range corpus {
+		if i%1024 == 0 && ctx.Err() != nil {
+			return TfIdfData{}, ctx.Err()
+		}
+
+		seen := make(map[string]bool)
+		for _, ngram := range charNGrams(Preprocess(document), NgramRange) {
+			termCounts[ngram]++
+			if !seen[ngram] {
+				seen[ngram] = true
+				documentFrequencies[ngram]++
+			}
+		}
+	}
+
+	minDF := opts.MinDF
+	if minDF.Value == 0 {
+		minDF = Documents(1)
+	}
+	maxDF := opts.MaxDF
+	if maxDF.Value == 0 {
+		maxDF = Proportion(1)
+	}
+	low, high := minDF.documents(len(corpus)), maxDF.documents(len(corpus))
+	if high < low {
+		return TfIdfData{}, fmt.Errorf("max_df %s corresponds to fewer documents than min_df %s", maxDF, minDF)
+	}
+
+	terms := make([]string, 0, len(documentFrequencies))
+	for term, df := range documentFrequencies {
+		if float64(df) >= low && float64(df) <= high {
+			terms = append(terms, term)
+		}
+	}
+	sort.Strings(terms)
+
+	if opts.MaxFeatures > 0 && len(terms) > opts.MaxFeatures {
+		sort.SliceStable(terms, func(a, b int) bool {
+			return termCounts[terms[a]] > termCounts[terms[b]]
+		})
+		terms = terms[:opts.MaxFeatures]
+		sort.Strings(terms)
+	}
+	if len(terms) == 0 {
+		return TfIdfData{}, fmt.Errorf("no terms remain after pruning, lower min_df or raise max_df")
+	}
+
+	data := TfIdfData{
+		Vocabulary: make(map[string]int32, len(terms)),
+		IdfValues:  make([]float32, len(terms)),
+	}
+	n := float64(len(corpus))
+	for i, term := range terms {
+		data.Vocabulary[term] = int32(i)
+		data.IdfValues[i] = float32(math.Log((1+n)/(1+float64(documentFrequencies[term]))) + 1)
+	}
+	return data, nil
+}
+
+// SaveTfIdfData writes TF-IDF data in the format read by LoadTfIdfData
+func SaveTfIdfData(filePath string, data TfIdfData) error {
+	content, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal TF-IDF data: %v", err)
+	}
+	if err := os.WriteFile(filePath, content, 0o644); err != nil {
+		return fmt.Errorf("failed to write TF-IDF file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package tfidf
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// The expected values are those of scikit-learn's
+// TfidfVectorizer(analyzer="char_wb", ngram_range=(1, 3),
+// strip_accents="unicode", sublinear_tf=True, smooth_idf=True) on
+// fitCorpus: ln((1 + 3) / (1 + df)) + 1 is 1 for the terms of all 3
+// documents, 1.2876821 for 2 and 1.6931472 for 1.
+var fitCorpus = []string{"ab", "b a", "Àb"}
+
+var fitIDF = map[string]float64{
+	" ":   1,
+	" a":  1,
+	" a ": 1.6931472,
+	" ab": 1.2876821,
+	" b":  1.6931472,
+	" b ": 1.6931472,
+	"a":   1,
+	"a ":  1.6931472,
+	"ab":  1.2876821,
+	"ab ": 1.2876821,
+	"b":   1,
+	"b ":  1,
+}
+
+// subset returns the IDF values of fitIDF for the given terms
+func subset(terms ...string) map[string]float64 {
+	idf := make(map[string]float64, len(terms))
+	for _, term := range terms {
+		idf[term] = fitIDF[term]
+	}
+	return idf
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *FitOptions
+		want map[string]float64
+	}{
+		{
+			name: "defaults",
+			want: fitIDF,
+		},
+		{
+			name: "min_df",
+			opts: &FitOptions{MinDF: Documents(2)},
+			want: subset(" ", " a", " ab", "a", "ab", "ab ", "b", "b "),
+		},
+		{
+			name: "max_df proportion",
+			opts: &FitOptions{MaxDF: Proportion(0.9)},
+			want: subset(" a ", " ab", " b", " b ", "a ", "ab", "ab "),
+		},
+		{
+			name: "min_df and max_df",
+			opts: &FitOptions{MinDF: Documents(2), MaxDF: Documents(2)},
+			want: subset(" ab", "ab", "ab "),
+		},
+		{
+			// " " occurs 8 times, " a", "a", "b" and "b " 3 times each and
+			// the next terms twice, so the cut is the same for any tie order
+			name: "max_features",
+			opts: &FitOptions{MaxFeatures: 5},
+			want: subset(" ", " a", "a", "b", "b "),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Fit(context.Background(), fitCorpus, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(data.Vocabulary) != len(tt.want) || len(data.IdfValues) != len(tt.want) {
+				t.Fatalf("%d terms and %d IDF values, want %d: %v", len(data.Vocabulary), len(data.IdfValues), len(tt.want), data.Vocabulary)
+			}
+
+			// Terms are indexed in alphabetical order
+			previous := ""
+			for i, term := range Terms(&data) {
+				want, exists := tt.want[term]
+				if !exists {
+					t.Errorf("unexpected term %q", term)
+					continue
+				}
+				if i > 0 && term <= previous {
+					t.Errorf("term %q at index %d after %q", term, i, previous)
+				}
+				previous = term
+				if idf := float64(data.IdfValues[i]); math.Abs(idf-want) > 1e-6 {
+					t.Errorf("IDF of %q = %v, want %v", term, idf, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFitVector(t *testing.T) {
+	data, err := Fit(context.Background(), fitCorpus, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// scikit-learn's transform(["Àb"]): sublinear TF 1 + ln(2) for the two
+	// spaces, IDF weighted and L2 normalized
+	want := map[string]float64{
+		" ":   0.49203758,
+		" a":  0.29060532,
+		" ab": 0.37420726,
+		"a":   0.29060532,
+		"ab":  0.37420726,
+		"ab ": 0.37420726,
+		"b":   0.29060532,
+		"b ":  0.29060532,
+	}
+	vector := CalculateTfIdfVector("Àb", &data)
+	for term, index := range data.Vocabulary {
+		if weight := float64(vector[index]); math.Abs(weight-want[term]) > 1e-6 {
+			t.Errorf("weight of %q = %v, want %v", term, weight, want[term])
+		}
+	}
+}
+
+func TestPreprocess(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"Suite", "suite"},
+		{"Été", "ete"},
+		{"Chambre Supérieure", "chambre superieure"},
+		// Compatibility characters are decomposed after lowercasing
+		{"Superior King №1", "superior king No1"},
+		{"Studio 35 m²", "studio 35 m2"},
+		{"ﬁve", "five"},
+	}
+
+	for _, tt := range tests {
+		if got := Preprocess(tt.input); got != tt.want {
+			t.Errorf("Preprocess(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
@@ -1,31 +1,49 @@
 package tfidf
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"math"
 	"os"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
-	"unicode"
 
+	"golang.org/x/text/runes"
 	"golang.org/x/text/transform"
 	"golang.org/x/text/unicode/norm"
-	"golang.org/x/text/runes"
 )
 
+// Parameters of the vectorizer the artifacts were fitted with, matching
+// scikit-learn's TfidfVectorizer(analyzer="char_wb", ngram_range=(1, 3),
+// strip_accents="unicode", sublinear_tf=True)
+const Analyzer = "char_wb"
+
+var NgramRange = [2]int{1, 3}
+
 type TfIdfData struct {
 	Vocabulary map[string]int32 `json:"vocabulary"`
 	IdfValues  []float32        `json:"idf_values"`
 }
 
+// stripAccents decomposes compatibility characters and removes combining
+// marks as scikit-learn's strip_accents_unicode, e.g. "é" becomes "e" and
+// "№" becomes "No"
 func stripAccents(input string) string {
-	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	t := transform.Chain(norm.NFKD, runes.Remove(runes.Predicate(isCombining)))
 	output, _, _ := transform.String(t, input)
 	return output
 }
 
+// isCombining reports whether r has a nonzero canonical combining class, as
+// Python's unicodedata.combining
+func isCombining(r rune) bool {
+	return norm.NFD.PropertiesString(string(r)).CCC() != 0
+}
+
 func charNGrams(input string, ngramRange [2]int) []string {
 	ngrams := []string{}
 	words := strings.Fields(input)
@@ -43,9 +61,15 @@ func charNGrams(input string, ngramRange [2]int) []string {
 	return ngrams
 }
 
+// Preprocess normalizes a rate name as done before vectorization: it is
+// lowercased, then accents are stripped, in scikit-learn's order
+func Preprocess(rateName string) string {
+	return stripAccents(strings.ToLower(rateName))
+}
+
 func CalculateTfIdfVector(rateName string, tfidfData *TfIdfData) []float32 {
-	preprocessed := strings.ToLower(stripAccents(rateName))
-	ngrams := charNGrams(preprocessed, [2]int{1, 3})
+	preprocessed := Preprocess(rateName)
+	ngrams := charNGrams(preprocessed, NgramRange)
 
 	termCounts := make(map[string]int, len(ngrams))
 	for _, ngram := range ngrams {
@@ -64,7 +88,12 @@ func CalculateTfIdfVector(rateName string, tfidfData *TfIdfData) []float32 {
 		}
 	}
 
-	// Normalize the vector
+	Normalize(vector)
+	return vector
+}
+
+// Normalize scales a vector to unit L2 norm in place
+func Normalize(vector []float32) {
 	var normVal float32
 	for _, v := range vector {
 		normVal += v * v
@@ -75,11 +104,16 @@ func CalculateTfIdfVector(rateName string, tfidfData *TfIdfData) []float32 {
 			vector[i] /= normVal
 		}
 	}
-
-	return vector
 }
 
 func CalculateTfIdfVectors(rateNames []string, tfidfData *TfIdfData) [][]float32 {
+	vectors, _ := CalculateTfIdfVectorsContext(context.Background(), rateNames, tfidfData)
+	return vectors
+}
+
+// CalculateTfIdfVectorsContext is like CalculateTfIdfVectors but stops the
+// workers and returns the context error as soon as ctx is done.
+func CalculateTfIdfVectorsContext(ctx context.Context, rateNames []string, tfidfData *TfIdfData) ([][]float32, error) {
 	vectors := make([][]float32, len(rateNames))
 	numWorkers := runtime.NumCPU()
 	jobs := make(chan int, len(rateNames))
@@ -90,6 +124,9 @@ func CalculateTfIdfVectors(rateNames []string, tfidfData *TfIdfData) [][]float32
 		go func() {
 			defer wg.Done()
 			for j := range jobs {
+				if ctx.Err() != nil {
+					return
+				}
 				vectors[j] = CalculateTfIdfVector(rateNames[j], tfidfData)
 			}
 		}()
@@ -101,7 +138,10 @@ func CalculateTfIdfVectors(rateNames []string, tfidfData *TfIdfData) [][]float32
 	close(jobs)
 
 	wg.Wait()
-	return vectors
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return vectors, nil
 }
 
 func LoadTfIdfData(filePath string) (TfIdfData, error) {
@@ -116,3 +156,53 @@ func LoadTfIdfData(filePath string) (TfIdfData, error) {
 	}
 	return data, nil
 }
+
+// LoadTfIdfDataFS is like LoadTfIdfData but reads the file from fsys
+func LoadTfIdfDataFS(fsys fs.FS, filePath string) (TfIdfData, error) {
+	data := TfIdfData{}
+	fileContent, err := fs.ReadFile(fsys, filePath)
+	if err != nil {
+		return data, fmt.Errorf("failed to read TF-IDF file: %v", err)
+	}
+	err = json.Unmarshal(fileContent, &data)
+	if err != nil {
+		return data, fmt.Errorf("failed to unmarshal TF-IDF data: %v", err)
+	}
+	return data, nil
+}
+
+// Terms inverts the vocabulary, returning the n-gram of every vector index
+func Terms(tfidfData *TfIdfData) []string {
+	terms := make([]string, len(tfidfData.IdfValues))
+	for term, index := range tfidfData.Vocabulary {
+		if index >= 0 && int(index) < len(terms) {
+			terms[index] = term
+		}
+	}
+	return terms
+}
+
+// CalculateSparseTfIdfVector returns the nonzero entries of the vector of
+// CalculateTfIdfVector by increasing index, without allocating the dense
+// vector or scanning the vocabulary
+func CalculateSparseTfIdfVector(rateName string, tfidfData *TfIdfData) ([]int32, []float32) {
+	termCounts := make(map[int32]int)
+	for _, ngram := range charNGrams(Preprocess(rateName), NgramRange) {
+		if index, exists := tfidfData.Vocabulary[ngram]; exists {
+			termCounts[index]++
+		}
+	}
+
+	indices := make([]int32, 0, len(termCounts))
+	for index := range termCounts {
+		indices = append(indices, index)
+	}
+	slices.Sort(indices)
+	values := make([]float32, len(indices))
+	for i, index := range indices {
+		tf := float32(1 + math.Log(float64(termCounts[index])))
+		values[i] = tf * tfidfData.IdfValues[index]
+	}
+	Normalize(values)
+	return indices, values
+}
@@ -0,0 +1,450 @@
+// Package bundle reads and writes versioned artifact bundles: a zip or
+// (gzipped) tar archive holding the artifacts of one model release along
+// with a manifest of its categories, file checksums and vectorizer parameters.
+//
+// The archive and any artifacts directory share the layout
+//
+//	manifest.json
+//	tfidf/tfidf_data.json
+//	cbm/catboost_model_<category>.cbm
+//	linear/linear_model_<category>.json
+//	labels/json/labels_<category>.json
+//
+// A category has either a catboost model or a linear model trained with
+// `tagger train`, see package linear.
+package bundle
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-goal/tagger/internal/extract"
+	"github.com/go-goal/tagger/internal/tfidf"
+)
+
+// Paths of the artifacts inside a bundle or artifacts directory
+const (
+	ManifestPath    = "manifest.json"
+	TfIdfPath       = "tfidf/tfidf_data.json"
+	ModelsDir       = "cbm"
+	LinearModelsDir = "linear"
+	LabelsDir       = "labels/json"
+)
+
+// FormatVersion is the version of the manifest format written by this package
+const FormatVersion = 1
+
+// Archive formats
+const (
+	FormatZip   = "zip"
+	FormatTar   = "tar"
+	FormatTarGz = "tar.gz"
+)
+
+// ModelPath returns the path of the catboost model of a category
+func ModelPath(category string) string {
+	return path.Join(ModelsDir, fmt.Sprintf("catboost_model_%s.cbm", category))
+}
+
+// LinearModelPath returns the path of the linear model of a category
+func LinearModelPath(category string) string {
+	return path.Join(LinearModelsDir, fmt.Sprintf("linear_model_%s.json", category))
+}
+
+// FindModel returns the path of the model of a category in an artifacts file
+// system, its catboost model if it has both
+func FindModel(fsys fs.FS, category string) (string, error) {
+	for _, modelPath := range []string{ModelPath(category), LinearModelPath(category)} {
+		if _, err := fs.Stat(fsys, modelPath); err == nil {
+			return modelPath, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("no model of category %s: %w", category, fs.ErrNotExist)
+}
+
+// LabelsPath returns the path of the labels of a category
+func LabelsPath(category string) string {
+	return path.Join(LabelsDir, fmt.Sprintf("labels_%s.json", category))
+}
+
+// Manifest describes the artifacts of a model release
+type Manifest struct {
+	FormatVersion int        `json:"format_version"`
+	Version       string     `json:"version"`
+	CreatedAt     time.Time  `json:"created_at"`
+	Categories    []string   `json:"categories"`
+	Vectorizer    Vectorizer `json:"vectorizer"`
+	// Files maps the path of every artifact to its hex SHA-256 hash
+	Files map[string]string `json:"files"`
+}
+
+// Vectorizer holds the parameters of the TF-IDF vectorizer of the release
+type Vectorizer struct {
+	Analyzer   string `json:"analyzer"`
+	NgramRange [2]int `json:"ngram_range"`
+	Features   int    `json:"features"`
+}
+
+// Bundle is an opened bundle, an fs.FS of its artifacts
+type Bundle struct {
+	fs.FS
+	Manifest *Manifest
+}
+
+// Categories lists the categories with a catboost or linear model in an
+// artifacts file system
+func Categories(fsys fs.FS) ([]string, error) {
+	seen := make(map[string]bool)
+	var categories []string
+	for _, pattern := range []struct{ dir, prefix, suffix string }{
+		{ModelsDir, "catboost_model_", ".cbm"},
+		{LinearModelsDir, "linear_model_", ".json"},
+	} {
+		matches, err := fs.Glob(fsys, path.Join(pattern.dir, pattern.prefix+"*"+pattern.suffix))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			category := strings.TrimSuffix(strings.TrimPrefix(path.Base(match), pattern.prefix), pattern.suffix)
+			if !seen[category] {
+				seen[category] = true
+				categories = append(categories, category)
+			}
+		}
+	}
+	if len(categories) == 0 {
+		return nil, fmt.Errorf("no models found in %s or %s", ModelsDir, LinearModelsDir)
+	}
+
+	sort.Strings(categories)
+	return categories, nil
+}
+
+// NewManifest builds the manifest of the given categories of an artifacts
+// file system, all categories with a model if none are given
+func NewManifest(fsys fs.FS, version string, categories []string) (*Manifest, error) {
+	if len(categories) == 0 {
+		var err error
+		categories, err = Categories(fsys)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tfidfData, err := tfidf.LoadTfIdfDataFS(fsys, TfIdfPath)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{
+		FormatVersion: FormatVersion,
+		Version:       version,
+		CreatedAt:     time.Now().UTC().Truncate(time.Second),
+		Categories:    categories,
+		Vectorizer: Vectorizer{
+			Analyzer:   tfidf.Analyzer,
+			NgramRange: tfidf.NgramRange,
+			Features:   len(tfidfData.Vocabulary),
+		},
+		Files: make(map[string]string),
+	}
+
+	files := []string{TfIdfPath}
+	for _, category := range categories {
+		modelPath, err := FindModel(fsys, category)
+		if _, extracted := extract.Lookup(category); extracted && errors.Is(err, fs.ErrNotExist) {
+			// Served by the patterns of package extract alone
+			files = append(files, LabelsPath(category))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, modelPath, LabelsPath(category))
+	}
+	for _, file := range files {
+		hash, err := hashFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Files[file] = hash
+	}
+
+	return manifest, nil
+}
+
+// ReadManifest reads the manifest of an artifacts file system. The error
+// wraps fs.ErrNotExist if it has none.
+func ReadManifest(fsys fs.FS) (*Manifest, error) {
+	content, err := fs.ReadFile(fsys, ManifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal manifest: %v", err)
+	}
+	if manifest.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("unsupported manifest format version %d, expected at most %d", manifest.FormatVersion, FormatVersion)
+	}
+	return &manifest, nil
+}
+
+// Verify checks that the artifacts of fsys match the manifest checksums and
+// that the vectorizer parameters are supported. The error joins every mismatch.
+func (m *Manifest) Verify(fsys fs.FS) error {
+	var errs []error
+
+	if m.Vectorizer.Analyzer != tfidf.Analyzer || m.Vectorizer.NgramRange != tfidf.NgramRange {
+		errs = append(errs, fmt.Errorf("unsupported vectorizer %s %v, expected %s %v", m.Vectorizer.Analyzer, m.Vectorizer.NgramRange, tfidf.Analyzer, tfidf.NgramRange))
+	}
+
+	required := []string{TfIdfPath}
+	for _, category := range m.Categories {
+		if !m.HasModel(category) {
+			errs = append(errs, fmt.Errorf("%s: missing from manifest", ModelPath(category)))
+		}
+		required = append(required, LabelsPath(category))
+	}
+	for _, file := range required {
+		if _, exists := m.Files[file]; !exists {
+			errs = append(errs, fmt.Errorf("%s: missing from manifest", file))
+		}
+	}
+
+	files := make([]string, 0, len(m.Files))
+	for file := range m.Files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		hash, err := hashFile(fsys, file)
+		if err != nil {
+			errs = append(errs, err)
+		} else if hash != m.Files[file] {
+			errs = append(errs, fmt.Errorf("%s: checksum mismatch, expected %s, got %s", file, m.Files[file], hash))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// HasModel reports whether the manifest lists a catboost or linear model of
+// a category, or its labels if the patterns of package extract serve it
+func (m *Manifest) HasModel(category string) bool {
+	_, catboost := m.Files[ModelPath(category)]
+	_, linear := m.Files[LinearModelPath(category)]
+	if _, extracted := extract.Lookup(category); extracted {
+		_, labels := m.Files[LabelsPath(category)]
+		return catboost || linear || labels
+	}
+	return catboost || linear
+}
+
+func hashFile(fsys fs.FS, name string) (string, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("%s: %v", name, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Open reads a bundle archive and its manifest. The archive format is
+// detected from its content. Checksums are not verified, see Manifest.Verify.
+func Open(filePath string) (*Bundle, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %v", err)
+	}
+	return Read(data)
+}
+
+// Read is like Open for an archive held in memory, e.g. one embedded with //go:embed
+func Read(data []byte) (*Bundle, error) {
+	fsys, err := archiveFS(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %v", err)
+	}
+
+	manifest, err := ReadManifest(fsys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle manifest: %v", err)
+	}
+	return &Bundle{FS: fsys, Manifest: manifest}, nil
+}
+
+// archiveFS returns the file system of a zip, tar or gzipped tar archive.
+// Tar archives are repacked into an uncompressed in-memory zip since
+// archive/tar offers no random access.
+func archiveFS(data []byte) (fs.FS, error) {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	}
+
+	var reader io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unknown archive format: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: path.Clean(strings.TrimPrefix(header.Name, "./")), Method: zip.Store})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(w, tr); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+}
+
+// FormatFromFilename returns the archive format of a bundle file name
+func FormatFromFilename(filename string) (string, error) {
+	switch {
+	case strings.HasSuffix(filename, ".zip"):
+		return FormatZip, nil
+	case strings.HasSuffix(filename, ".tar.gz"), strings.HasSuffix(filename, ".tgz"):
+		return FormatTarGz, nil
+	case strings.HasSuffix(filename, ".tar"):
+		return FormatTar, nil
+	}
+	return "", fmt.Errorf("unknown bundle format of %s, expected .zip, .tar, .tar.gz or .tgz", filename)
+}
+
+// Write writes the manifest and the artifacts it lists from fsys as an archive
+func Write(w io.Writer, format string, fsys fs.FS, manifest *Manifest) error {
+	manifestContent, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	files := make([]string, 0, len(manifest.Files))
+	for file := range manifest.Files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	switch format {
+	case FormatZip:
+		zw := zip.NewWriter(w)
+		err = writeZip(zw, manifest.CreatedAt, manifestContent, fsys, files)
+		if closeErr := zw.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	case FormatTar, FormatTarGz:
+		var gz *gzip.Writer
+		if format == FormatTarGz {
+			gz = gzip.NewWriter(w)
+			w = gz
+		}
+		tw := tar.NewWriter(w)
+		err = writeTar(tw, manifest.CreatedAt, manifestContent, fsys, files)
+		if closeErr := tw.Close(); err == nil {
+			err = closeErr
+		}
+		if gz != nil {
+			if closeErr := gz.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}
+	return fmt.Errorf("unknown bundle format %s", format)
+}
+
+func writeZip(zw *zip.Writer, modified time.Time, manifestContent []byte, fsys fs.FS, files []string) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: ManifestPath, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(manifestContent); err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: file, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeTar(tw *tar.Writer, modified time.Time, manifestContent []byte, fsys fs.FS, files []string) error {
+	write := func(name string, content []byte) error {
+		header := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), ModTime: modified, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(content)
+		return err
+	}
+
+	if err := write(ManifestPath, manifestContent); err != nil {
+		return err
+	}
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		if err := write(file, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
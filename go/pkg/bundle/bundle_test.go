@@ -0,0 +1,104 @@
+package bundle
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const testArtifacts = "../../testdata/artifacts"
+
+// copyFS copies the regular files of fsys into memory
+func copyFS(t *testing.T, fsys fs.FS) fstest.MapFS {
+	t.Helper()
+	files := fstest.MapFS{}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := fs.ReadFile(fsys, name)
+		files[name] = &fstest.MapFile{Data: content}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestWriteRead(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		// tamper changes the artifacts after the manifest is created
+		tamper func(files fstest.MapFS)
+		// wantErr is part of the error of Verify, empty if it succeeds
+		wantErr string
+	}{
+		{name: "zip", format: FormatZip},
+		{name: "tar", format: FormatTar},
+		{name: "tar.gz", format: FormatTarGz},
+		{
+			name:   "changed labels",
+			format: FormatZip,
+			tamper: func(files fstest.MapFS) {
+				files[LabelsPath("class")].Data = []byte(`["suite", "room"]`)
+			},
+			wantErr: LabelsPath("class") + ": checksum mismatch",
+		},
+		{
+			name:   "changed model",
+			format: FormatTarGz,
+			tamper: func(files fstest.MapFS) {
+				model := files[LinearModelPath("view")]
+				model.Data = bytes.Replace(model.Data, []byte(`"bias": [-1, 0]`), []byte(`"bias": [0, 0]`), 1)
+			},
+			wantErr: LinearModelPath("view") + ": checksum mismatch",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := copyFS(t, os.DirFS(testArtifacts))
+			manifest, err := NewManifest(files, "test", []string{"class", "view"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.tamper != nil {
+				tt.tamper(files)
+			}
+
+			var archive bytes.Buffer
+			if err := Write(&archive, tt.format, files, manifest); err != nil {
+				t.Fatal(err)
+			}
+			b, err := Read(archive.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if b.Manifest.Version != "test" || len(b.Manifest.Files) != len(manifest.Files) {
+				t.Errorf("manifest %s with %d files, want %s with %d", b.Manifest.Version, len(b.Manifest.Files), manifest.Version, len(manifest.Files))
+			}
+			for name, file := range files {
+				content, err := fs.ReadFile(b, name)
+				if err != nil {
+					t.Errorf("%s: %v", name, err)
+				} else if !bytes.Equal(content, file.Data) {
+					t.Errorf("%s differs from the artifacts", name)
+				}
+			}
+
+			err = b.Manifest.Verify(b)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Verify: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Verify = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
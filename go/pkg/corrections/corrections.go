@@ -0,0 +1,457 @@
+// Package corrections stores human-verified tags of rate names, keyed by the
+// normalized name, so reviewed names are never predicted again.
+//
+// The store is a local JSON Lines file with one record per change. Later
+// records replace earlier ones for the same key and deletions are recorded as
+// records without tags. The file is compacted when it is opened.
+package corrections
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-goal/tagger/internal/extract"
+	"github.com/go-goal/tagger/internal/tfidf"
+)
+
+// Correction holds the verified tags of a rate name
+type Correction struct {
+	// Key is the normalized input, see Key
+	Key string `json:"key"`
+	// Input is the rate name as last corrected
+	Input string `json:"input"`
+	// Tags maps categories to their verified label. Categories without a
+	// correction are predicted by the model.
+	Tags      map[string]string `json:"tags,omitempty"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	// Author identifies who made the correction, e.g. the API client or an import file
+	Author string `json:"author,omitempty"`
+}
+
+// Key returns the key of a rate name: lowercased, without accents and with
+// runs of whitespace collapsed to single spaces
+func Key(input string) string {
+	return strings.Join(strings.Fields(tfidf.Preprocess(input)), " ")
+}
+
+// Store is a persistent set of corrections safe for concurrent use, also by
+// several processes sharing the file, such as the API and `tagger
+// corrections import`. Changes are made under an exclusive lock of the file
+// path with a ".lock" suffix, after reading the records other processes
+// appended, and Reload picks them up for reading.
+type Store struct {
+	path string
+	lock *os.File
+
+	mu          sync.RWMutex
+	file        *os.File
+	corrections map[string]Correction
+	// offset and lines are the bytes and lines of the file read so far
+	offset int64
+	lines  int
+}
+
+// Open opens the store of a file, creating it if it does not exist
+func Open(filePath string) (*Store, error) {
+	s := &Store{path: filePath, corrections: make(map[string]Correction)}
+
+	var err error
+	s.lock, err = os.OpenFile(filePath+".lock", os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open corrections lock file: %v", err)
+	}
+	if err := lockFile(s.lock); err != nil {
+		s.lock.Close()
+		return nil, fmt.Errorf("failed to lock corrections file: %v", err)
+	}
+	defer unlockFile(s.lock)
+
+	records, err := s.read()
+	if err == nil && records > len(s.corrections) {
+		err = s.compact()
+	}
+	if err == nil {
+		s.file, err = os.OpenFile(filePath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	}
+	if err != nil {
+		s.lock.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// read applies the records of the file after the offset and returns their
+// number
+func (s *Store) read() (int, error) {
+	file, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open corrections file: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.Seek(s.offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to read corrections file: %v", err)
+	}
+
+	records := 0
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) == 0 && err == io.EOF {
+			return records, nil
+		}
+		if err != nil && err != io.EOF {
+			return 0, fmt.Errorf("failed to read corrections file: %v", err)
+		}
+		s.offset += int64(len(line))
+		s.lines++
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		records++
+
+		var correction Correction
+		if err := json.Unmarshal(line, &correction); err != nil {
+			return 0, fmt.Errorf("failed to unmarshal correction on line %d of %s: %v", s.lines, s.path, err)
+		}
+		if len(correction.Tags) == 0 {
+			delete(s.corrections, correction.Key)
+		} else {
+			s.corrections[correction.Key] = correction
+		}
+	}
+}
+
+// refresh applies the records appended by other processes, and reads the
+// file again if another process compacted it. It reports whether the file
+// changed. The caller holds mu and the file lock.
+func (s *Store) refresh() (bool, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read corrections file: %v", err)
+	}
+	opened, err := s.file.Stat()
+	if err != nil {
+		return false, fmt.Errorf("failed to read corrections file: %v", err)
+	}
+
+	if os.SameFile(info, opened) && info.Size() >= s.offset {
+		if info.Size() == s.offset {
+			return false, nil
+		}
+		_, err := s.read()
+		return true, err
+	}
+
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return false, fmt.Errorf("failed to open corrections file: %v", err)
+	}
+	s.file.Close()
+	s.file = file
+	s.corrections = make(map[string]Correction)
+	s.offset, s.lines = 0, 0
+	_, err = s.read()
+	return true, err
+}
+
+// Reload applies the changes other processes made to the file since the
+// last call and reports whether there were any
+func (s *Store) Reload() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := lockFile(s.lock); err != nil {
+		return false, fmt.Errorf("failed to lock corrections file: %v", err)
+	}
+	defer unlockFile(s.lock)
+	return s.refresh()
+}
+
+// Watch calls Reload every interval until ctx is done. onReload is called
+// with the number of corrections after a change and with every error.
+func (s *Store) Watch(ctx context.Context, interval time.Duration, onReload func(int, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := s.Reload()
+			if (reloaded || err != nil) && onReload != nil {
+				onReload(s.Len(), err)
+			}
+		}
+	}
+}
+
+// compact rewrites the file with the current corrections only. The caller
+// holds the file lock, so other processes reopen the new file before
+// writing to it.
+func (s *Store) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to compact corrections file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	corrections := s.list()
+	for _, correction := range corrections {
+		if err := writeRecord(w, correction); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to compact corrections file: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to compact corrections file: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to compact corrections file: %v", err)
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to compact corrections file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to compact corrections file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to compact corrections file: %v", err)
+	}
+	s.offset, s.lines = info.Size(), len(corrections)
+	return nil
+}
+
+func writeRecord(w io.Writer, correction Correction) error {
+	line, err := json.Marshal(correction)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the file of the store
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lock.Close()
+	return s.file.Close()
+}
+
+// Len returns the number of corrected rate names
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.corrections)
+}
+
+// Get returns the correction of a rate name
+func (s *Store) Get(input string) (Correction, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	correction, exists := s.corrections[Key(input)]
+	return correction, exists
+}
+
+// List returns all corrections ordered by key
+func (s *Store) List() []Correction {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.list()
+}
+
+func (s *Store) list() []Correction {
+	corrections := make([]Correction, 0, len(s.corrections))
+	for _, correction := range s.corrections {
+		corrections = append(corrections, correction)
+	}
+	sort.Slice(corrections, func(a, b int) bool {
+		return corrections[a].Key < corrections[b].Key
+	})
+	return corrections
+}
+
+// write appends records to the file and syncs it, after applying the records
+// other processes appended, so that fn sees the current corrections. fn
+// returns the records to append, the caller applies them once written.
+func (s *Store) write(fn func() ([]Correction, error)) ([]Correction, error) {
+	if err := lockFile(s.lock); err != nil {
+		return nil, fmt.Errorf("failed to lock corrections file: %v", err)
+	}
+	defer unlockFile(s.lock)
+	if _, err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	records, err := fn()
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	w := bufio.NewWriter(s.file)
+	for _, record := range records {
+		if err := writeRecord(w, record); err != nil {
+			return nil, fmt.Errorf("failed to write corrections file: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to write corrections file: %v", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return nil, fmt.Errorf("failed to write corrections file: %v", err)
+	}
+	info, err := s.file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to write corrections file: %v", err)
+	}
+	s.offset = info.Size()
+	s.lines += len(records)
+	return records, nil
+}
+
+// Put stores corrections, replacing the tags of the categories they correct
+// and keeping those of other categories. Key and UpdatedAt are set if empty.
+func (s *Store) Put(corrections ...Correction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.write(func() ([]Correction, error) {
+		updated := make(map[string]Correction, len(corrections))
+		records := make([]Correction, 0, len(corrections))
+		for _, correction := range corrections {
+			if len(correction.Tags) == 0 {
+				return nil, fmt.Errorf("correction of %q has no tags", correction.Input)
+			}
+			if correction.Key == "" {
+				correction.Key = Key(correction.Input)
+			}
+			if correction.UpdatedAt.IsZero() {
+				correction.UpdatedAt = time.Now().UTC()
+			}
+
+			previous, exists := updated[correction.Key]
+			if !exists {
+				previous = s.corrections[correction.Key]
+			}
+			tags := make(map[string]string)
+			for category, label := range previous.Tags {
+				tags[category] = label
+			}
+			for category, label := range correction.Tags {
+				tags[category] = label
+			}
+			correction.Tags = tags
+
+			updated[correction.Key] = correction
+			records = append(records, correction)
+		}
+		return records, nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, correction := range records {
+		s.corrections[correction.Key] = correction
+	}
+	return nil
+}
+
+// Delete removes the correction of a rate name and reports whether it existed
+func (s *Store) Delete(input string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := Key(input)
+	records, err := s.write(func() ([]Correction, error) {
+		if _, exists := s.corrections[key]; !exists {
+			return nil, nil
+		}
+		return []Correction{{Key: key, Input: input, UpdatedAt: time.Now().UTC()}}, nil
+	})
+	if err != nil || len(records) == 0 {
+		return false, err
+	}
+	delete(s.corrections, key)
+	return true, nil
+}
+
+// FromRows converts a table shaped like the training data, an input column
+// followed by one column per category, to corrections. Empty cells are
+// extract.Undefined if it is a label of the category, and left to the model
+// otherwise. labels returns the labels of a category or an error if it is
+// unknown, columns that are no category are ignored. The error joins every
+// unknown label with its row number.
+func FromRows(headers []string, rows [][]string, inputCol string, labels func(category string) ([]string, error), author string) ([]Correction, error) {
+	inputIndex := -1
+	categories := make(map[int][]string)
+	for i, header := range headers {
+		if header == inputCol {
+			inputIndex = i
+			continue
+		}
+		if known, err := labels(header); err == nil {
+			categories[i] = known
+		}
+	}
+	if inputIndex == -1 {
+		return nil, fmt.Errorf("input column '%s' not found, available columns: %s", inputCol, strings.Join(headers, ", "))
+	}
+	if len(categories) == 0 {
+		return nil, fmt.Errorf("no category columns found in %s", strings.Join(headers, ", "))
+	}
+
+	var errs []error
+	corrections := make([]Correction, 0, len(rows))
+	for r, row := range rows {
+		if inputIndex >= len(row) || strings.TrimSpace(row[inputIndex]) == "" {
+			continue
+		}
+
+		correction := Correction{Input: row[inputIndex], Tags: make(map[string]string), Author: author}
+		for i, known := range categories {
+			label := ""
+			if i < len(row) {
+				label = strings.TrimSpace(row[i])
+			}
+			if label == "" {
+				if !slices.Contains(known, extract.Undefined) {
+					continue
+				}
+				label = extract.Undefined
+			}
+			if !slices.Contains(known, label) {
+				// Rows are numbered from 2, the header being row 1
+				errs = append(errs, fmt.Errorf("row %d: unknown label %q of category %s", r+2, label, headers[i]))
+				continue
+			}
+			correction.Tags[headers[i]] = label
+		}
+		if len(correction.Tags) > 0 {
+			corrections = append(corrections, correction)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return corrections, nil
+}
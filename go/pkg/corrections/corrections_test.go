@@ -0,0 +1,225 @@
+package corrections
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tags returns the tags of the corrections of a store by key
+func tags(s *Store) map[string]map[string]string {
+	all := make(map[string]map[string]string)
+	for _, correction := range s.List() {
+		all[correction.Key] = correction.Tags
+	}
+	return all
+}
+
+func equalTags(a, b map[string]map[string]string) bool {
+	return maps.EqualFunc(a, b, func(x, y map[string]string) bool { return maps.Equal(x, y) })
+}
+
+// countLines returns the number of lines of a file
+func countLines(t *testing.T, filePath string) int {
+	t.Helper()
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(content), "\n")
+}
+
+func TestOpenReplay(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]map[string]string
+		// wantLines is the number of lines left after compaction
+		wantLines int
+		wantErr   string
+	}{
+		{
+			name:    "empty file",
+			content: "",
+			want:    map[string]map[string]string{},
+		},
+		{
+			name: "later records replace earlier ones",
+			content: `{"key":"suite","input":"Suite","tags":{"class":"room"}}
+{"key":"suite","input":"Suite","tags":{"class":"suite"}}
+`,
+			want:      map[string]map[string]string{"suite": {"class": "suite"}},
+			wantLines: 1,
+		},
+		{
+			name: "tombstones delete",
+			content: `{"key":"suite","input":"Suite","tags":{"class":"suite"}}
+{"key":"room","input":"Room","tags":{"class":"room"}}
+{"key":"suite","input":"Suite"}
+`,
+			want:      map[string]map[string]string{"room": {"class": "room"}},
+			wantLines: 1,
+		},
+		{
+			name: "corrected again after a tombstone",
+			content: `{"key":"suite","input":"Suite","tags":{"class":"room"}}
+{"key":"suite","input":"Suite"}
+{"key":"suite","input":"SUITE","tags":{"view":"sea view"}}
+`,
+			want:      map[string]map[string]string{"suite": {"view": "sea view"}},
+			wantLines: 1,
+		},
+		{
+			name: "everything deleted",
+			content: `{"key":"suite","input":"Suite","tags":{"class":"suite"}}
+{"key":"suite","input":"Suite"}
+`,
+			want:      map[string]map[string]string{},
+			wantLines: 0,
+		},
+		{
+			name: "blank lines are skipped",
+			content: `{"key":"room","input":"Room","tags":{"class":"room"}}
+
+{"key":"suite","input":"Suite","tags":{"class":"suite"}}
+`,
+			want:      map[string]map[string]string{"room": {"class": "room"}, "suite": {"class": "suite"}},
+			wantLines: 3,
+		},
+		{
+			name: "unterminated last line",
+			content: `{"key":"suite","input":"Suite","tags":{"class":"room"}}
+{"key":"suite","input":"Suite","tags":{"class":"suite"}}`,
+			want:      map[string]map[string]string{"suite": {"class": "suite"}},
+			wantLines: 1,
+		},
+		{
+			name: "invalid record reports its line",
+			content: `{"key":"suite","input":"Suite","tags":{"class":"suite"}}
+
+not json
+`,
+			wantErr: "line 3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "corrections.jsonl")
+			if err := os.WriteFile(filePath, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			store, err := Open(filePath)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Open = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+
+			if got := tags(store); !equalTags(got, tt.want) {
+				t.Errorf("corrections %v, want %v", got, tt.want)
+			}
+			if lines := countLines(t, filePath); lines != tt.wantLines {
+				t.Errorf("%d lines after Open, want %d", lines, tt.wantLines)
+			}
+		})
+	}
+}
+
+// TestSharedFile checks that stores sharing a file, as the API and
+// `tagger corrections import` do, see each other's changes, also across the
+// compaction of the file by a store opened later
+func TestSharedFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "corrections.jsonl")
+	open := func() *Store {
+		t.Helper()
+		store, err := Open(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	}
+	a, b := open(), open()
+
+	steps := []struct {
+		name   string
+		change func() error
+		want   map[string]map[string]string
+	}{
+		{
+			name:   "put",
+			change: func() error { return a.Put(Correction{Input: "Suite", Tags: map[string]string{"class": "suite"}}) },
+			want:   map[string]map[string]string{"suite": {"class": "suite"}},
+		},
+		{
+			name:   "put merges categories",
+			change: func() error { return b.Put(Correction{Input: "SUITE", Tags: map[string]string{"view": "sea view"}}) },
+			want:   map[string]map[string]string{"suite": {"class": "suite", "view": "sea view"}},
+		},
+		{
+			name: "delete",
+			change: func() error {
+				_, err := b.Delete("suite")
+				return err
+			},
+			want: map[string]map[string]string{},
+		},
+		{
+			name:   "put after delete",
+			change: func() error { return a.Put(Correction{Input: "Room", Tags: map[string]string{"class": "room"}}) },
+			want:   map[string]map[string]string{"room": {"class": "room"}},
+		},
+		{
+			name: "compaction by another store",
+			change: func() error {
+				open()
+				return nil
+			},
+			want: map[string]map[string]string{"room": {"class": "room"}},
+		},
+		{
+			name:   "put after compaction",
+			change: func() error { return b.Put(Correction{Input: "Suite", Tags: map[string]string{"class": "suite"}}) },
+			want:   map[string]map[string]string{"room": {"class": "room"}, "suite": {"class": "suite"}},
+		},
+		{
+			name: "delete after compaction",
+			change: func() error {
+				_, err := a.Delete("room")
+				return err
+			},
+			want: map[string]map[string]string{"suite": {"class": "suite"}},
+		},
+	}
+
+	for _, step := range steps {
+		if err := step.change(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		for name, store := range map[string]*Store{"a": a, "b": b} {
+			if _, err := store.Reload(); err != nil {
+				t.Fatalf("%s: reload %s: %v", step.name, name, err)
+			}
+			if got := tags(store); !equalTags(got, step.want) {
+				t.Errorf("%s: store %s has %v, want %v", step.name, name, got, step.want)
+			}
+		}
+	}
+
+	reopened := open()
+	if got, want := tags(reopened), steps[len(steps)-1].want; !equalTags(got, want) {
+		t.Errorf("reopened store has %v, want %v", got, want)
+	}
+	if lines := countLines(t, filePath); lines != 1 {
+		t.Errorf("%d lines after reopening, want 1", lines)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package corrections
+
+import "os"
+
+// lockFile is a no-op without flock, so only one process may change the
+// store at a time
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
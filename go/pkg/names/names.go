@@ -0,0 +1,301 @@
+// Package names composes normalized, human-readable room names from the
+// predicted tags, e.g. "Deluxe Triple Room, Sea View, Balcony" for
+// class=room, quality=deluxe, capacity=triple, view=sea view and
+// balcony=balcony, with a template per locale.
+//
+// Templates are read from YAML:
+//
+//	version: "1"
+//	default_locale: en
+//	locales:
+//	  en:
+//	    separator: ", "
+//	    parts:
+//	      - "{club} {quality} {capacity} {class}"
+//	      - "{view}"
+//	      - "{balcony}"
+//	    labels:
+//	      capacity:
+//	        double: Double
+//	      balcony:
+//	        no balcony: ""
+//	  fr:
+//	    parts:
+//	      - "{class} {capacity} {quality}"
+//	      - "{view}"
+//	    labels:
+//	      class:
+//	        room: Chambre
+//
+// A placeholder {category} is replaced by the display text of the label of
+// the category: its entry under labels, or that of the default locale, or the
+// label with hyphens replaced by spaces and every word capitalized. Empty and
+// "undefined" labels, labels displayed as "" and categories that were not
+// predicted render nothing. Parts whose placeholders all render nothing are
+// dropped, the others are joined by the separator with runs of spaces
+// collapsed.
+package names
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrUnknownLocale is returned for locales without a template
+var ErrUnknownLocale = errors.New("unknown locale")
+
+// DefaultSeparator joins the parts of templates without a separator
+const DefaultSeparator = ", "
+
+// undefined is the label of categories the rate name states nothing about
+const undefined = "undefined"
+
+// Templates are the room name templates of every locale
+type Templates struct {
+	Version string `yaml:"version"`
+	// DefaultLocale is rendered when no locale is requested, and provides
+	// the display text of labels other locales do not translate
+	DefaultLocale string             `yaml:"default_locale"`
+	Locales       map[string]*Locale `yaml:"locales"`
+}
+
+// Locale is the template of a single locale
+type Locale struct {
+	Separator string   `yaml:"separator"`
+	Parts     []string `yaml:"parts"`
+	// Labels maps categories to the display text of their labels
+	Labels map[string]map[string]string `yaml:"labels"`
+
+	parts [][]segment
+}
+
+// segment is a literal text, or a placeholder if category is set
+type segment struct {
+	text     string
+	category string
+}
+
+var placeholderPattern = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// Load reads templates from a YAML file
+func Load(filePath string) (*Templates, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read names file: %v", err)
+	}
+	return Parse(content)
+}
+
+// Parse reads templates from YAML and compiles their parts. Locales are
+// normalized as in Resolve, so "de_AT" and "de-AT" name the same locale.
+func Parse(content []byte) (*Templates, error) {
+	var templates Templates
+	if err := yaml.Unmarshal(content, &templates); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal names: %v", err)
+	}
+	if templates.Version == "" {
+		return nil, errors.New("names have no version")
+	}
+	if len(templates.Locales) == 0 {
+		return nil, errors.New("names have no locales")
+	}
+	if templates.DefaultLocale == "" {
+		return nil, errors.New("names have no default_locale")
+	}
+
+	locales := make(map[string]*Locale, len(templates.Locales))
+	names := make(map[string]string, len(templates.Locales))
+	for _, name := range templates.LocaleNames() {
+		normalized := normalizeLocale(name)
+		if other, exists := names[normalized]; exists {
+			return nil, fmt.Errorf("locales %s and %s are the same locale %s", other, name, normalized)
+		}
+		names[normalized] = name
+		locales[normalized] = templates.Locales[name]
+	}
+	templates.Locales = locales
+	templates.DefaultLocale = normalizeLocale(templates.DefaultLocale)
+	if _, exists := templates.Locales[templates.DefaultLocale]; !exists {
+		return nil, fmt.Errorf("default locale %s has no template", templates.DefaultLocale)
+	}
+
+	for _, name := range templates.LocaleNames() {
+		locale := templates.Locales[name]
+		if locale == nil || len(locale.Parts) == 0 {
+			return nil, fmt.Errorf("locale %s has no parts", name)
+		}
+		if locale.Separator == "" {
+			locale.Separator = DefaultSeparator
+		}
+		locale.parts = make([][]segment, len(locale.Parts))
+		for i, part := range locale.Parts {
+			segments, err := compile(part)
+			if err != nil {
+				return nil, fmt.Errorf("locale %s: part %d: %v", name, i, err)
+			}
+			locale.parts[i] = segments
+		}
+	}
+	return &templates, nil
+}
+
+// compile splits a part into literal texts and placeholders
+func compile(part string) ([]segment, error) {
+	var segments []segment
+	hasPlaceholder := false
+	last := 0
+	for _, match := range placeholderPattern.FindAllStringSubmatchIndex(part, -1) {
+		category := strings.TrimSpace(part[match[2]:match[3]])
+		if category == "" {
+			return nil, errors.New("empty placeholder")
+		}
+		segments = append(segments, segment{text: part[last:match[0]]}, segment{category: category})
+		hasPlaceholder = true
+		last = match[1]
+	}
+	segments = append(segments, segment{text: part[last:]})
+
+	for _, s := range segments {
+		if strings.ContainsAny(s.text, "{}") {
+			return nil, fmt.Errorf("%q has an unbalanced brace", part)
+		}
+	}
+	if !hasPlaceholder {
+		return nil, fmt.Errorf("%q has no placeholder", part)
+	}
+	return segments, nil
+}
+
+// LocaleNames returns the locales with a template in alphabetical order
+func (t *Templates) LocaleNames() []string {
+	return sortedKeys(t.Locales)
+}
+
+// Resolve returns the locale of the template rendered for a requested
+// locale: the locale itself, its language without the region ("de" for
+// "de-AT" or "de_AT"), or the default locale if it is empty
+func (t *Templates) Resolve(locale string) (string, error) {
+	if locale == "" {
+		return t.DefaultLocale, nil
+	}
+	locale = normalizeLocale(locale)
+	if _, exists := t.Locales[locale]; exists {
+		return locale, nil
+	}
+	if language, _, found := strings.Cut(locale, "-"); found {
+		if _, exists := t.Locales[language]; exists {
+			return language, nil
+		}
+	}
+	return "", fmt.Errorf("%w %s, known locales: %s", ErrUnknownLocale, locale, strings.Join(t.LocaleNames(), ", "))
+}
+
+// normalizeLocale lowercases a locale and separates its region by "-"
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+}
+
+// Render composes the room name of the labels by category in a locale, see
+// Resolve for the locales accepted
+func (t *Templates) Render(locale string, labels map[string]string) (string, error) {
+	name, err := t.Resolve(locale)
+	if err != nil {
+		return "", err
+	}
+	template := t.Locales[name]
+
+	var parts []string
+	for _, segments := range template.parts {
+		var b strings.Builder
+		rendered := false
+		for _, s := range segments {
+			if s.category == "" {
+				b.WriteString(s.text)
+				continue
+			}
+			if text := t.display(template, s.category, labels[s.category]); text != "" {
+				b.WriteString(text)
+				rendered = true
+			}
+		}
+		if part := strings.Join(strings.Fields(b.String()), " "); rendered && part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, template.Separator), nil
+}
+
+// display returns the display text of a label in a locale
+func (t *Templates) display(locale *Locale, category, label string) string {
+	if label == "" || label == undefined {
+		return ""
+	}
+	if text, exists := locale.Labels[category][label]; exists {
+		return text
+	}
+	if text, exists := t.Locales[t.DefaultLocale].Labels[category][label]; exists {
+		return text
+	}
+	return titleCase(label)
+}
+
+// titleCase capitalizes every word of a label, hyphens separate words
+func titleCase(label string) string {
+	words := strings.Fields(strings.ReplaceAll(label, "-", " "))
+	for i, word := range words {
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		words[i] = string(runes)
+	}
+	return strings.Join(words, " ")
+}
+
+// Validate checks that every category and label of the templates exists.
+// labels returns the labels of a category or an error if it is unknown.
+func (t *Templates) Validate(labels func(category string) ([]string, error)) error {
+	var errs []error
+	for _, name := range t.LocaleNames() {
+		locale := t.Locales[name]
+		for _, segments := range locale.parts {
+			for _, s := range segments {
+				if s.category == "" {
+					continue
+				}
+				if _, err := labels(s.category); err != nil {
+					errs = append(errs, fmt.Errorf("locale %s: %w", name, err))
+				}
+			}
+		}
+
+		for _, category := range sortedKeys(locale.Labels) {
+			known, err := labels(category)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("locale %s: labels: %w", name, err))
+				continue
+			}
+			for _, label := range sortedKeys(locale.Labels[category]) {
+				if !slices.Contains(known, label) {
+					errs = append(errs, fmt.Errorf("locale %s: labels: unknown label %q of category %s", name, label, category))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
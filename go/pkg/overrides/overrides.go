@@ -0,0 +1,335 @@
+// Package overrides applies deterministic fixes to the tagger output without
+// retraining: ordered rules that match the normalized rate name by regular
+// expression or keywords and set or forbid labels of given categories.
+//
+// Overrides are read from YAML:
+//
+//	version: "2026-10-18"
+//	overrides:
+//	  - name: club-keyword
+//	    description: Club rates are always club
+//	    match:
+//	      keywords: [club]
+//	    set:
+//	      club: club
+//	  - name: no-sea-view-for-city
+//	    match:
+//	      regex: '\bcity view\b'
+//	    forbid:
+//	      view: [sea view]
+//
+// Names are lowercased and stripped of accents before matching. Keywords
+// match whole words or phrases. The first matching override that sets a
+// category wins, later ones are ignored for it. Forbidden labels are dropped
+// from the candidates of a category that no override sets, so the most
+// probable remaining label is predicted.
+package overrides
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/go-goal/tagger/internal/tfidf"
+	"github.com/go-goal/tagger/pkg/rules"
+)
+
+// Actions of a fired override
+const (
+	ActionSet    = "set"
+	ActionForbid = "forbid"
+)
+
+// Set is a versioned, ordered list of overrides
+type Set struct {
+	Version   string     `yaml:"version"`
+	Overrides []Override `yaml:"overrides"`
+}
+
+// Override sets or forbids labels of the names it matches
+type Override struct {
+	Name        string              `yaml:"name"`
+	Description string              `yaml:"description"`
+	Match       Match               `yaml:"match"`
+	Set         map[string]string   `yaml:"set"`
+	Forbid      map[string][]string `yaml:"forbid"`
+
+	pattern *regexp.Regexp
+}
+
+// Match selects the names an override applies to. A name matches if it
+// matches the regular expression or contains one of the keywords.
+type Match struct {
+	Regex    string   `yaml:"regex"`
+	Keywords []string `yaml:"keywords"`
+}
+
+// Firing is an override that changed the candidates of a category
+type Firing struct {
+	Category string `json:"category" yaml:"category"`
+	Override string `json:"override" yaml:"override"`
+	Action   string `json:"action" yaml:"action"`
+}
+
+// Load reads an override set from a YAML file
+func Load(filePath string) (*Set, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read overrides file: %v", err)
+	}
+	return Parse(content)
+}
+
+// Parse reads an override set from YAML and compiles its matches
+func Parse(content []byte) (*Set, error) {
+	var set Set
+	if err := yaml.Unmarshal(content, &set); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal overrides: %v", err)
+	}
+	if set.Version == "" {
+		return nil, errors.New("overrides have no version")
+	}
+
+	names := make(map[string]bool, len(set.Overrides))
+	for i := range set.Overrides {
+		override := &set.Overrides[i]
+		if override.Name == "" {
+			return nil, fmt.Errorf("override %d has no name", i)
+		}
+		if names[override.Name] {
+			return nil, fmt.Errorf("duplicate override %s", override.Name)
+		}
+		names[override.Name] = true
+
+		if len(override.Set) == 0 && len(override.Forbid) == 0 {
+			return nil, fmt.Errorf("override %s has neither set nor forbid", override.Name)
+		}
+		pattern, err := compile(override.Match)
+		if err != nil {
+			return nil, fmt.Errorf("override %s: %v", override.Name, err)
+		}
+		override.pattern = pattern
+	}
+
+	return &set, nil
+}
+
+// compile merges the regular expression and the keywords of a match into one
+// expression, keywords are matched as whole words of the normalized name
+func compile(match Match) (*regexp.Regexp, error) {
+	var alternatives []string
+	if match.Regex != "" {
+		if _, err := regexp.Compile(match.Regex); err != nil {
+			return nil, fmt.Errorf("invalid regex: %v", err)
+		}
+		alternatives = append(alternatives, "(?:"+match.Regex+")")
+	}
+	for _, keyword := range match.Keywords {
+		if keyword = tfidf.Preprocess(strings.TrimSpace(keyword)); keyword != "" {
+			alternatives = append(alternatives, `\b`+regexp.QuoteMeta(keyword)+`\b`)
+		}
+	}
+	if len(alternatives) == 0 {
+		return nil, errors.New("match has neither regex nor keywords")
+	}
+	return regexp.Compile(strings.Join(alternatives, "|"))
+}
+
+// Validate checks that every category and label of the overrides exists and
+// that no override forbids every label of a category. labels returns the
+// labels of a category or an error if it is unknown.
+func (s *Set) Validate(labels func(category string) ([]string, error)) error {
+	var errs []error
+	for _, override := range s.Overrides {
+		for _, category := range sortedKeys(override.Set) {
+			known, err := labels(category)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("override %s: set: %w", override.Name, err))
+			} else if !slices.Contains(known, override.Set[category]) {
+				errs = append(errs, fmt.Errorf("override %s: set: unknown label %q of category %s", override.Name, override.Set[category], category))
+			}
+		}
+
+		for _, category := range sortedKeys(override.Forbid) {
+			known, err := labels(category)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("override %s: forbid: %w", override.Name, err))
+				continue
+			}
+			allowed := len(known)
+			for _, label := range override.Forbid[category] {
+				if !slices.Contains(known, label) {
+					errs = append(errs, fmt.Errorf("override %s: forbid: unknown label %q of category %s", override.Name, label, category))
+				} else {
+					allowed--
+				}
+			}
+			if allowed <= 0 {
+				errs = append(errs, fmt.Errorf("override %s: forbid: every label of category %s is forbidden", override.Name, category))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Apply applies the overrides matching input to the candidates of the
+// predicted categories, labels by decreasing probability as used by
+// rules.RuleSet.Resolve. A set category keeps only its set label, forbidden
+// labels are removed. Categories that were not predicted are ignored. It
+// returns, ordered by category, the overrides that set a label and those
+// that forbade the most probable one.
+func (s *Set) Apply(input string, candidates map[string][]rules.Candidate) []Firing {
+	normalized := tfidf.Preprocess(input)
+
+	set := make(map[string]bool)
+	var firings []Firing
+	for i := range s.Overrides {
+		override := &s.Overrides[i]
+		if !override.pattern.MatchString(normalized) {
+			continue
+		}
+
+		for _, category := range sortedKeys(override.Set) {
+			ranked, exists := candidates[category]
+			if !exists || set[category] {
+				continue
+			}
+			set[category] = true
+
+			label := override.Set[category]
+			candidate := rules.Candidate{Label: label}
+			for _, c := range ranked {
+				if c.Label == label {
+					candidate = c
+				}
+			}
+			candidates[category] = []rules.Candidate{candidate}
+			firings = append(firings, Firing{Category: category, Override: override.Name, Action: ActionSet})
+		}
+
+		for _, category := range sortedKeys(override.Forbid) {
+			ranked, exists := candidates[category]
+			if !exists || set[category] {
+				continue
+			}
+
+			allowed := make([]rules.Candidate, 0, len(ranked))
+			for _, c := range ranked {
+				if !slices.Contains(override.Forbid[category], c.Label) {
+					allowed = append(allowed, c)
+				}
+			}
+			if len(allowed) == 0 {
+				continue
+			}
+			if allowed[0].Label != ranked[0].Label {
+				firings = append(firings, Firing{Category: category, Override: override.Name, Action: ActionForbid})
+			}
+			candidates[category] = allowed
+		}
+	}
+
+	sort.SliceStable(firings, func(a, b int) bool {
+		return firings[a].Category < firings[b].Category
+	})
+	return firings
+}
+
+// Watcher holds the override set of a file and reloads it when the file changes
+type Watcher struct {
+	path     string
+	validate func(*Set) error
+
+	mu      sync.RWMutex
+	current *Set
+	// modTime and size are those of the file at the last reload attempt
+	modTime time.Time
+	size    int64
+}
+
+// NewWatcher loads the overrides of a file. validate, if not nil, is run on
+// every loaded set, which is only used if it passes.
+func NewWatcher(filePath string, validate func(*Set) error) (*Watcher, error) {
+	w := &Watcher{path: filePath, validate: validate}
+	if _, err := w.Reload(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Current returns the last successfully loaded override set
+func (w *Watcher) Current() *Set {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.current
+}
+
+// Reload loads the file again if its modification time or size changed since
+// the last attempt. It reports whether a new set was loaded. On error the
+// current set is kept, and the same file is not retried until it changes.
+func (w *Watcher) Reload() (bool, error) {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read overrides file: %v", err)
+	}
+
+	w.mu.Lock()
+	unchanged := w.current != nil && info.ModTime().Equal(w.modTime) && info.Size() == w.size
+	w.modTime, w.size = info.ModTime(), info.Size()
+	w.mu.Unlock()
+	if unchanged {
+		return false, nil
+	}
+
+	set, err := Load(w.path)
+	if err != nil {
+		return false, err
+	}
+	if w.validate != nil {
+		if err := w.validate(set); err != nil {
+			return false, fmt.Errorf("invalid overrides in %s: %w", w.path, err)
+		}
+	}
+
+	w.mu.Lock()
+	w.current = set
+	w.mu.Unlock()
+	return true, nil
+}
+
+// Watch checks the file for changes every interval until ctx is done. onReload
+// is called after every reload attempt that loaded a set or failed.
+func (w *Watcher) Watch(ctx context.Context, interval time.Duration, onReload func(*Set, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := w.Reload()
+			if (reloaded || err != nil) && onReload != nil {
+				onReload(w.Current(), err)
+			}
+		}
+	}
+}
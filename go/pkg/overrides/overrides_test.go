@@ -0,0 +1,197 @@
+package overrides
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/go-goal/tagger/pkg/rules"
+)
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides string
+		input     string
+		// want holds the labels of the candidates left per category
+		want        map[string][]string
+		wantFirings []Firing
+	}{
+		{
+			name: "set",
+			overrides: `
+  - name: club-keyword
+    match:
+      keywords: [club]
+    set:
+      club: club`,
+			input:       "Club Room",
+			want:        map[string][]string{"club": {"club"}, "view": {"undefined", "sea view"}},
+			wantFirings: []Firing{{"club", "club-keyword", ActionSet}},
+		},
+		{
+			name: "keywords match whole words",
+			overrides: `
+  - name: club-keyword
+    match:
+      keywords: [club]
+    set:
+      club: club`,
+			input: "Clubhouse Room",
+			want:  map[string][]string{"club": {"undefined", "club"}, "view": {"undefined", "sea view"}},
+		},
+		{
+			name: "names are matched without accents",
+			overrides: `
+  - name: sea-view
+    match:
+      regex: '\bvue mer\b'
+    set:
+      view: sea view`,
+			input:       "Chambre Vue Mér",
+			want:        map[string][]string{"club": {"undefined", "club"}, "view": {"sea view"}},
+			wantFirings: []Firing{{"view", "sea-view", ActionSet}},
+		},
+		{
+			name: "first set wins",
+			overrides: `
+  - name: first
+    match:
+      keywords: [club]
+    set:
+      club: club
+  - name: second
+    match:
+      keywords: [room]
+    set:
+      club: undefined`,
+			input:       "Club Room",
+			want:        map[string][]string{"club": {"club"}, "view": {"undefined", "sea view"}},
+			wantFirings: []Firing{{"club", "first", ActionSet}},
+		},
+		{
+			name: "set takes precedence over a later forbid",
+			overrides: `
+  - name: set-club
+    match:
+      keywords: [club]
+    set:
+      club: club
+  - name: forbid-club
+    match:
+      keywords: [room]
+    forbid:
+      club: [club]`,
+			input:       "Club Room",
+			want:        map[string][]string{"club": {"club"}, "view": {"undefined", "sea view"}},
+			wantFirings: []Firing{{"club", "set-club", ActionSet}},
+		},
+		{
+			name: "set takes precedence over an earlier forbid",
+			overrides: `
+  - name: forbid-club
+    match:
+      keywords: [room]
+    forbid:
+      club: [club]
+  - name: set-club
+    match:
+      keywords: [club]
+    set:
+      club: club`,
+			input:       "Club Room",
+			want:        map[string][]string{"club": {"club"}, "view": {"undefined", "sea view"}},
+			wantFirings: []Firing{{"club", "set-club", ActionSet}},
+		},
+		{
+			name: "forbid the most probable label",
+			overrides: `
+  - name: city
+    match:
+      keywords: [city view]
+    forbid:
+      view: [undefined]`,
+			input:       "City View Room",
+			want:        map[string][]string{"club": {"undefined", "club"}, "view": {"sea view"}},
+			wantFirings: []Firing{{"view", "city", ActionForbid}},
+		},
+		{
+			name: "forbid a less probable label",
+			overrides: `
+  - name: city
+    match:
+      keywords: [city view]
+    forbid:
+      view: [sea view]`,
+			input: "City View Room",
+			want:  map[string][]string{"club": {"undefined", "club"}, "view": {"undefined"}},
+		},
+		{
+			name: "forbid every label",
+			overrides: `
+  - name: city
+    match:
+      keywords: [city view]
+    forbid:
+      view: [sea view, undefined]`,
+			input: "City View Room",
+			want:  map[string][]string{"club": {"undefined", "club"}, "view": {"undefined", "sea view"}},
+		},
+		{
+			name: "categories that were not predicted",
+			overrides: `
+  - name: two-bedrooms
+    match:
+      keywords: [2 bedrooms]
+    set:
+      bedrooms: 2 bedrooms
+      club: club`,
+			input:       "Club 2 Bedrooms",
+			want:        map[string][]string{"club": {"club"}, "view": {"undefined", "sea view"}},
+			wantFirings: []Firing{{"club", "two-bedrooms", ActionSet}},
+		},
+		{
+			name: "firings ordered by category",
+			overrides: `
+  - name: view
+    match:
+      keywords: [room]
+    set:
+      view: sea view
+  - name: club
+    match:
+      keywords: [room]
+    set:
+      club: club`,
+			input:       "Room",
+			want:        map[string][]string{"club": {"club"}, "view": {"sea view"}},
+			wantFirings: []Firing{{"club", "club", ActionSet}, {"view", "view", ActionSet}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := Parse([]byte("version: \"1\"\noverrides:" + tt.overrides))
+			if err != nil {
+				t.Fatal(err)
+			}
+			candidates := map[string][]rules.Candidate{
+				"club": {{Label: "undefined", Probability: 0.7}, {Label: "club", Probability: 0.3}},
+				"view": {{Label: "undefined", Probability: 0.6}, {Label: "sea view", Probability: 0.4}},
+			}
+
+			firings := set.Apply(tt.input, candidates)
+			if !slices.Equal(firings, tt.wantFirings) {
+				t.Errorf("firings %v, want %v", firings, tt.wantFirings)
+			}
+			for category, want := range tt.want {
+				var labels []string
+				for _, candidate := range candidates[category] {
+					labels = append(labels, candidate.Label)
+				}
+				if !slices.Equal(labels, want) {
+					t.Errorf("%s candidates %v, want %v", category, labels, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+// Package rules checks the tags predicted independently per category against
+// declarative cross-category consistency rules, and resolves violations by
+// choosing the jointly most likely consistent combination of labels.
+//
+// Rules are read from YAML:
+//
+//	version: "1"
+//	rules:
+//	  - name: single-capacity-single-bedding
+//	    description: A single room has no twin or double bedding
+//	    when:
+//	      tags:
+//	        capacity: [single]
+//	    forbid:
+//	      bedding: [twin/twin-or-double, double/double-or-twin]
+//	  - name: explicit-two-bedrooms
+//	    when:
+//	      input: '\b2[ -]?bedrooms?\b'
+//	    require:
+//	      bedrooms: [2 bedrooms]
+//
+// A rule applies when the normalized input matches the `input` regular
+// expression and every category of `tags` has one of the listed labels. An
+// applicable rule is violated if a category of `require` has none of its
+// listed labels, or a category of `forbid` has one of its listed labels.
+// Categories that were not predicted never match nor violate.
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/go-goal/tagger/internal/tfidf"
+)
+
+// MaxCandidates is the number of most probable labels per category considered by Resolve
+const MaxCandidates = 3
+
+// RuleSet is an ordered list of rules
+type RuleSet struct {
+	Version string `yaml:"version"`
+	Rules   []Rule `yaml:"rules"`
+}
+
+// Rule is a single consistency rule
+type Rule struct {
+	Name        string              `yaml:"name"`
+	Description string              `yaml:"description"`
+	When        Condition           `yaml:"when"`
+	Require     map[string][]string `yaml:"require"`
+	Forbid      map[string][]string `yaml:"forbid"`
+
+	input *regexp.Regexp
+}
+
+// Condition selects the predictions a rule applies to
+type Condition struct {
+	// Input is a regular expression matched against the normalized input
+	Input string `yaml:"input"`
+	// Tags maps categories to the labels that make the rule apply
+	Tags map[string][]string `yaml:"tags"`
+}
+
+// Violation is a rule broken by a combination of tags
+type Violation struct {
+	Rule        string `json:"rule" yaml:"rule"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Categories are the require and forbid categories that broke the rule
+	Categories []string `json:"categories" yaml:"categories"`
+}
+
+// Candidate is a label of a category with its predicted probability
+type Candidate struct {
+	Label       string
+	Probability float64
+}
+
+// Load reads a rule set from a YAML file
+func Load(filePath string) (*RuleSet, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %v", err)
+	}
+	return Parse(content)
+}
+
+// Parse reads a rule set from YAML and compiles its input expressions
+func Parse(content []byte) (*RuleSet, error) {
+	var ruleSet RuleSet
+	if err := yaml.Unmarshal(content, &ruleSet); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rules: %v", err)
+	}
+
+	names := make(map[string]bool, len(ruleSet.Rules))
+	for i := range ruleSet.Rules {
+		rule := &ruleSet.Rules[i]
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule %d has no name", i)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicate rule %s", rule.Name)
+		}
+		names[rule.Name] = true
+
+		if len(rule.Require) == 0 && len(rule.Forbid) == 0 {
+			return nil, fmt.Errorf("rule %s has neither require nor forbid", rule.Name)
+		}
+		if rule.When.Input != "" {
+			input, err := regexp.Compile(rule.When.Input)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: invalid input expression: %v", rule.Name, err)
+			}
+			rule.input = input
+		}
+	}
+
+	return &ruleSet, nil
+}
+
+// Validate checks that every category and label of the rules exists.
+// labels returns the labels of a category or an error if it is unknown.
+func (s *RuleSet) Validate(labels func(category string) ([]string, error)) error {
+	var errs []error
+	check := func(rule, field string, tags map[string][]string) {
+		for _, category := range sortedKeys(tags) {
+			values := tags[category]
+			known, err := labels(category)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("rule %s: %s: %w", rule, field, err))
+				continue
+			}
+			for _, value := range values {
+				if !slices.Contains(known, value) {
+					errs = append(errs, fmt.Errorf("rule %s: %s: unknown label %q of category %s", rule, field, value, category))
+				}
+			}
+		}
+	}
+
+	for _, rule := range s.Rules {
+		check(rule.Name, "when", rule.When.Tags)
+		check(rule.Name, "require", rule.Require)
+		check(rule.Name, "forbid", rule.Forbid)
+	}
+	return errors.Join(errs...)
+}
+
+func sortedKeys(tags map[string][]string) []string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Categories returns the categories referenced by the rules
+func (s *RuleSet) Categories() []string {
+	seen := make(map[string]bool)
+	for _, rule := range s.Rules {
+		for _, tags := range []map[string][]string{rule.When.Tags, rule.Require, rule.Forbid} {
+			for category := range tags {
+				seen[category] = true
+			}
+		}
+	}
+
+	categories := make([]string, 0, len(seen))
+	for category := range seen {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// Check returns the rules violated by the tags predicted for input, in rule order
+func (s *RuleSet) Check(input string, tags map[string]string) []Violation {
+	return s.check(tfidf.Preprocess(input), tags)
+}
+
+func (s *RuleSet) check(normalized string, tags map[string]string) []Violation {
+	var violations []Violation
+	for i := range s.Rules {
+		if violation, violated := s.Rules[i].check(normalized, tags); violated {
+			violations = append(violations, violation)
+		}
+	}
+	return violations
+}
+
+func (r *Rule) check(normalized string, tags map[string]string) (Violation, bool) {
+	if r.input != nil && !r.input.MatchString(normalized) {
+		return Violation{}, false
+	}
+	for category, values := range r.When.Tags {
+		label, exists := tags[category]
+		if !exists || !slices.Contains(values, label) {
+			return Violation{}, false
+		}
+	}
+
+	var broken []string
+	for category, values := range r.Require {
+		if label, exists := tags[category]; exists && !slices.Contains(values, label) {
+			broken = append(broken, category)
+		}
+	}
+	for category, values := range r.Forbid {
+		if label, exists := tags[category]; exists && slices.Contains(values, label) {
+			broken = append(broken, category)
+		}
+	}
+	if len(broken) == 0 {
+		return Violation{}, false
+	}
+
+	sort.Strings(broken)
+	return Violation{Rule: r.Name, Description: r.Description, Categories: broken}, true
+}
+
+// Resolve picks for every category referenced by the rules one of its
+// MaxCandidates most probable labels, so that the combination violates no rule
+// and has the highest joint probability. candidates holds the labels of every
+// predicted category by decreasing probability. It returns the chosen labels
+// of all categories and the violations left, which are those of the most
+// probable combination if no consistent one exists.
+func (s *RuleSet) Resolve(input string, candidates map[string][]Candidate) (map[string]string, []Violation) {
+	normalized := tfidf.Preprocess(input)
+
+	tags := make(map[string]string, len(candidates))
+	for category, labels := range candidates {
+		if len(labels) > 0 {
+			tags[category] = labels[0].Label
+		}
+	}
+
+	violations := s.check(normalized, tags)
+	if len(violations) == 0 {
+		return tags, nil
+	}
+
+	var categories []string
+	for _, category := range s.Categories() {
+		if len(candidates[category]) > 0 {
+			categories = append(categories, category)
+		}
+	}
+
+	// Exhaustive search over the top candidates of the rule categories
+	best := math.Inf(-1)
+	var bestTags map[string]string
+	choice := make(map[string]string, len(tags))
+	for category, label := range tags {
+		choice[category] = label
+	}
+
+	var search func(i int, score float64)
+	search = func(i int, score float64) {
+		if score <= best {
+			return
+		}
+		if i == len(categories) {
+			if len(s.check(normalized, choice)) == 0 {
+				best = score
+				bestTags = make(map[string]string, len(choice))
+				for category, label := range choice {
+					bestTags[category] = label
+				}
+			}
+			return
+		}
+
+		category := categories[i]
+		for _, candidate := range candidates[category][:min(MaxCandidates, len(candidates[category]))] {
+			choice[category] = candidate.Label
+			search(i+1, score+math.Log(math.Max(candidate.Probability, 1e-12)))
+		}
+		choice[category] = tags[category]
+	}
+	search(0, 0)
+
+	if bestTags == nil {
+		return tags, violations
+	}
+	return bestTags, nil
+}
@@ -0,0 +1,127 @@
+package rules
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+const testRules = `
+version: "1"
+rules:
+  - name: single-capacity-single-bedding
+    when:
+      tags:
+        capacity: [single]
+    forbid:
+      bedding: [double/double-or-twin]
+  - name: explicit-two-bedrooms
+    when:
+      input: '\b2[ -]?bedrooms?\b'
+    require:
+      bedrooms: [2 bedrooms]
+`
+
+func TestResolve(t *testing.T) {
+	ruleSet, err := Parse([]byte(testRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		input          string
+		candidates     map[string][]Candidate
+		want           map[string]string
+		wantViolations []string
+	}{
+		{
+			name:  "consistent top-1",
+			input: "Single Room",
+			candidates: map[string][]Candidate{
+				"capacity": {{"single", 0.9}, {"double", 0.1}},
+				"bedding":  {{"single", 0.6}, {"double/double-or-twin", 0.4}},
+			},
+			want: map[string]string{"capacity": "single", "bedding": "single"},
+		},
+		{
+			name:  "changes the less certain category",
+			input: "Single Room",
+			candidates: map[string][]Candidate{
+				"capacity": {{"single", 0.9}, {"double", 0.1}},
+				"bedding":  {{"double/double-or-twin", 0.6}, {"single", 0.4}},
+			},
+			want: map[string]string{"capacity": "single", "bedding": "single"},
+		},
+		{
+			name:  "highest joint probability",
+			input: "Single Room",
+			candidates: map[string][]Candidate{
+				"capacity": {{"single", 0.55}, {"double", 0.45}},
+				"bedding":  {{"double/double-or-twin", 0.9}, {"single", 0.1}},
+			},
+			want: map[string]string{"capacity": "double", "bedding": "double/double-or-twin"},
+		},
+		{
+			name:  "keeps categories outside the rules",
+			input: "Single Room Sea View",
+			candidates: map[string][]Candidate{
+				"capacity": {{"single", 0.9}, {"double", 0.1}},
+				"bedding":  {{"double/double-or-twin", 0.6}, {"single", 0.4}},
+				"view":     {{"sea view", 0.8}, {"undefined", 0.2}},
+			},
+			want: map[string]string{"capacity": "single", "bedding": "single", "view": "sea view"},
+		},
+		{
+			name:  "input rule",
+			input: "Apartment 2 Bedrooms",
+			candidates: map[string][]Candidate{
+				"bedrooms": {{"1 bedroom", 0.7}, {"3 bedrooms", 0.2}, {"2 bedrooms", 0.1}},
+			},
+			want: map[string]string{"bedrooms": "2 bedrooms"},
+		},
+		{
+			name:  "only the top candidates are considered",
+			input: "Apartment 2 Bedrooms",
+			candidates: map[string][]Candidate{
+				"bedrooms": {{"1 bedroom", 0.7}, {"3 bedrooms", 0.15}, {"4 bedrooms", 0.1}, {"2 bedrooms", 0.05}},
+			},
+			want:           map[string]string{"bedrooms": "1 bedroom"},
+			wantViolations: []string{"explicit-two-bedrooms"},
+		},
+		{
+			name:  "no consistent combination",
+			input: "Single Room",
+			candidates: map[string][]Candidate{
+				"capacity": {{"single", 1}},
+				"bedding":  {{"double/double-or-twin", 1}},
+			},
+			want:           map[string]string{"capacity": "single", "bedding": "double/double-or-twin"},
+			wantViolations: []string{"single-capacity-single-bedding"},
+		},
+		{
+			name:  "categories that were not predicted",
+			input: "Single Room",
+			candidates: map[string][]Candidate{
+				"bedding": {{"double/double-or-twin", 0.9}, {"single", 0.1}},
+			},
+			want: map[string]string{"bedding": "double/double-or-twin"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, violations := ruleSet.Resolve(tt.input, tt.candidates)
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("Resolve = %v, want %v", got, tt.want)
+			}
+			var names []string
+			for _, violation := range violations {
+				names = append(names, violation.Rule)
+			}
+			if !slices.Equal(names, tt.wantViolations) {
+				t.Errorf("violations %v, want %v", names, tt.wantViolations)
+			}
+		})
+	}
+}
@@ -0,0 +1,761 @@
+// Package tagger tags rate names with room attributes such as view, bedding
+// or room class, in-process and without going through the HTTP API.
+//
+// A Tagger is loaded once from an artifacts directory, file system or bundle
+// archive laid out as described in package bundle, and is safe for concurrent use:
+//
+//	t, err := tagger.New("artifacts", nil)
+//	if err != nil {
+//		return err
+//	}
+//	result, err := t.Predict(ctx, "Deluxe King Room Sea View", &tagger.PredictOptions{TopK: 3})
+//	fmt.Println(result.Tags["view"].Label)
+package tagger
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-goal/tagger/internal/attributes"
+	"github.com/go-goal/tagger/internal/model"
+	"github.com/go-goal/tagger/internal/tfidf"
+	"github.com/go-goal/tagger/pkg/bundle"
+	"github.com/go-goal/tagger/pkg/corrections"
+	"github.com/go-goal/tagger/pkg/names"
+	"github.com/go-goal/tagger/pkg/overrides"
+	"github.com/go-goal/tagger/pkg/rules"
+)
+
+// ErrUnknownCategory is returned for categories the Tagger has not loaded
+var ErrUnknownCategory = errors.New("unknown category")
+
+// CategoryError is the error of a single category, joined in the errors
+// returned by the Tagger
+type CategoryError = model.CategoryError
+
+// FailedCategories returns the categories of the *CategoryError values in err
+func FailedCategories(err error) []string {
+	return model.FailedCategories(err)
+}
+
+// Explanation reports how much each n-gram of an input contributed to the
+// label predicted for a category, see Tagger.Explain
+type Explanation = model.Explanation
+
+// Contribution is the effect of leaving a single n-gram out of the input
+type Contribution = model.Contribution
+
+// Attributes are the numbers stated in a rate name, such as bed counts, area
+// in square meters, occupancy, bedroom count and floor
+type Attributes = attributes.Attributes
+
+// Bed is a number of beds of a type
+type Bed = attributes.Bed
+
+// Conflict is a predicted label contradicted by an attribute of the input
+type Conflict = attributes.Conflict
+
+// ParseAttributes returns the attributes stated in a rate name
+func ParseAttributes(input string) Attributes {
+	return attributes.Parse(input)
+}
+
+// Cache is a least recently used cache of the model probabilities of rate
+// names, bounded in entries and age, see NewCache
+type Cache = model.Cache
+
+// CacheStats counts the lookups and evictions of a Cache
+type CacheStats = model.CacheStats
+
+// NewCache returns a cache of at most size entries, each kept at most ttl or
+// until evicted if ttl is zero. Rate names are cached by their normalized
+// form, lowercased without accents and with runs of whitespace collapsed,
+// along with the predicted categories and the model version, so a cache can
+// be shared by Taggers of different artifacts. Corrections, overrides, rules
+// and thresholds are applied after the cache and may change between calls.
+func NewCache(size int, ttl time.Duration) *Cache {
+	return model.NewCache(size, ttl)
+}
+
+// DedupStats counts the inputs predicted by the models and the unique ones
+// among them, see Tagger.DedupStats
+type DedupStats = model.DedupStats
+
+// Options configure how a Tagger is loaded
+type Options struct {
+	// Categories to load, all categories of the manifest or with a model in
+	// the artifacts if empty
+	Categories []string
+	// Concurrency bounds the number of categories loaded or predicted at
+	// the same time, zero means one goroutine per category
+	Concurrency int
+	// BestEffort skips the categories that fail to load, returning the Tagger
+	// along with their errors instead of failing
+	BestEffort bool
+	// Cache answers the rate names already predicted without the models,
+	// nil disables caching
+	Cache *Cache
+}
+
+// PredictOptions configure a single prediction call. A nil *PredictOptions
+// predicts the label of every loaded category.
+type PredictOptions struct {
+	// Categories to predict, all loaded categories if empty
+	Categories []string
+	// TopK is the number of most probable labels returned in Prediction.TopK, zero for none
+	TopK int
+	// Threshold is the probability the predicted label needs to reach,
+	// below it Prediction.Label is left empty
+	Threshold float64
+	// Thresholds overrides Threshold per category
+	Thresholds map[string]float64
+	// BestEffort returns the results of the categories that succeeded along
+	// with the error of the others instead of only an error
+	BestEffort bool
+	// Rules are checked against the predicted labels, violations are
+	// reported in Result.Violations
+	Rules *rules.RuleSet
+	// Resolve replaces the labels of violated rules by the jointly most
+	// likely consistent combination, see rules.RuleSet.Resolve
+	Resolve bool
+	// Overrides set or forbid labels of matching inputs regardless of the
+	// model, before Rules are checked
+	Overrides *overrides.Set
+	// Corrections replace the predictions of the categories they correct
+	// with human-verified labels
+	Corrections *corrections.Store
+	// Attributes parses the numbers stated in the inputs into
+	// Result.Attributes and cross-checks them against the capacity, bedrooms
+	// and bedding labels in Result.Conflicts
+	Attributes bool
+	// Names renders the labels of every input as a room name in
+	// Result.RoomName, in the template of Locale
+	Names *names.Templates
+	// Locale of the room names, the default locale of Names if empty
+	Locale string
+}
+
+// Result holds the predictions for one input
+type Result struct {
+	Input string `json:"input"`
+	// Tags maps each predicted category to its prediction. Categories that
+	// failed in best-effort mode are missing.
+	Tags map[string]Prediction `json:"tags"`
+	// Violations lists the consistency rules broken by the tags
+	Violations []rules.Violation `json:"violations,omitempty"`
+	// Attributes are the numbers stated in the input if requested
+	Attributes *Attributes `json:"attributes,omitempty"`
+	// Conflicts lists the labels contradicted by the attributes
+	Conflicts []Conflict `json:"conflicts,omitempty"`
+	// RoomName is the room name rendered from the labels if requested
+	RoomName string `json:"room_name,omitempty"`
+}
+
+// Prediction is the predicted label of a category
+type Prediction struct {
+	// Label is the predicted label, empty if its probability is below the threshold
+	Label string `json:"label"`
+	// Probability is the probability of the predicted label before the threshold is applied
+	Probability float64 `json:"probability"`
+	// TopK lists the most probable labels in decreasing order of probability
+	TopK []LabelProbability `json:"top_k,omitempty"`
+	// Original is the most probable label if an override or a consistency
+	// rule replaced it
+	Original string `json:"original,omitempty"`
+	// Override is the name of the override that fired for the category
+	Override string `json:"override,omitempty"`
+	// Source is SourceModel or SourceCorrection
+	Source string `json:"source"`
+}
+
+// Sources of a prediction
+const (
+	SourceModel      = "model"
+	SourceCorrection = "correction"
+	// SourceMixed is the source of results with tags of both sources, see Result.Source
+	SourceMixed = "mixed"
+)
+
+// LabelProbability is a label with its predicted probability
+type LabelProbability struct {
+	Label       string  `json:"label"`
+	Probability float64 `json:"probability"`
+}
+
+// Labels returns the predicted label of every category of the result
+func (r Result) Labels() map[string]string {
+	labels := make(map[string]string, len(r.Tags))
+	for category, prediction := range r.Tags {
+		labels[category] = prediction.Label
+	}
+	return labels
+}
+
+// Tagger predicts tags of rate names with the loaded models
+type Tagger struct {
+	predictor  *model.Predictor
+	categories []string
+	manifest   *bundle.Manifest
+}
+
+// New loads a Tagger from an artifacts directory
+func New(dir string, opts *Options) (*Tagger, error) {
+	return NewContext(context.Background(), os.DirFS(dir), opts)
+}
+
+// Open loads a Tagger from an artifacts directory or a bundle archive
+func Open(path string, opts *Options) (*Tagger, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return New(path, opts)
+	}
+
+	b, err := bundle.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewContext(context.Background(), b, opts)
+}
+
+// NewFS loads a Tagger from a file system holding the artifacts at its root,
+// such as an embed.FS or an opened *bundle.Bundle
+func NewFS(fsys fs.FS, opts *Options) (*Tagger, error) {
+	return NewContext(context.Background(), fsys, opts)
+}
+
+// NewContext is like NewFS but stops loading when ctx is done. In BestEffort
+// mode it may return both a Tagger and an error.
+//
+// If the artifacts have a manifest, their checksums and vectorizer are
+// verified against it before anything is loaded.
+func NewContext(ctx context.Context, fsys fs.FS, opts *Options) (*Tagger, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	manifest, err := bundle.ReadManifest(fsys)
+	if errors.Is(err, fs.ErrNotExist) {
+		manifest = nil
+	} else if err != nil {
+		return nil, err
+	} else if err := manifest.Verify(fsys); err != nil {
+		return nil, fmt.Errorf("artifacts do not match manifest %s: %w", manifest.Version, err)
+	}
+
+	categories := opts.Categories
+	if len(categories) == 0 && manifest != nil {
+		categories = manifest.Categories
+	}
+	if len(categories) == 0 {
+		categories, err = bundle.Categories(fsys)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if manifest != nil {
+		// Only artifacts covered by the checksums are loaded
+		for _, category := range categories {
+			if !manifest.HasModel(category) {
+				return nil, fmt.Errorf("%w %s, not in manifest %s", ErrUnknownCategory, category, manifest.Version)
+			}
+		}
+	}
+
+	tfidfData, err := tfidf.LoadTfIdfDataFS(fsys, bundle.TfIdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading TF-IDF data: %w", err)
+	}
+	if manifest != nil && manifest.Vectorizer.Features != len(tfidfData.Vocabulary) {
+		return nil, fmt.Errorf("manifest %s expects %d TF-IDF features, got %d", manifest.Version, manifest.Vectorizer.Features, len(tfidfData.Vocabulary))
+	}
+
+	predictor := model.NewPredictorFS(&tfidfData, fsys, bundle.ModelsDir, bundle.LabelsDir, categories)
+	predictor.LinearModelsDir = bundle.LinearModelsDir
+	predictor.Concurrency = opts.Concurrency
+	predictor.BestEffort = opts.BestEffort
+	err = predictor.LoadModelsContext(ctx)
+	if err != nil && (!opts.BestEffort || ctx.Err() != nil) {
+		return nil, fmt.Errorf("error loading models: %w", err)
+	}
+
+	var loaded []string
+	for _, category := range categories {
+		if _, exists := predictor.Labels(category); exists {
+			loaded = append(loaded, category)
+		}
+	}
+	if len(loaded) == 0 {
+		return nil, fmt.Errorf("error loading models: %w", err)
+	}
+
+	if opts.Cache != nil {
+		predictor.Version, err = cacheVersion(fsys, manifest, loaded)
+		if err != nil {
+			return nil, fmt.Errorf("error versioning models: %w", err)
+		}
+		predictor.Cache = opts.Cache
+	}
+
+	t := &Tagger{predictor: predictor, categories: loaded, manifest: manifest}
+	if err != nil {
+		return t, fmt.Errorf("error loading models: %w", err)
+	}
+	return t, nil
+}
+
+// cacheVersion identifies the artifacts of the categories in the keys of a
+// Cache by a digest of their checksums, so that retrained models never hit
+// the entries of the previous ones, even without a manifest or under the same
+// manifest version
+func cacheVersion(fsys fs.FS, manifest *bundle.Manifest, categories []string) (string, error) {
+	if manifest == nil {
+		var err error
+		if manifest, err = bundle.NewManifest(fsys, "", categories); err != nil {
+			return "", err
+		}
+	}
+	files := make([]string, 0, len(manifest.Files))
+	for file := range manifest.Files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	hash := sha256.New()
+	for _, file := range files {
+		fmt.Fprintf(hash, "%s %s\n", file, manifest.Files[file])
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16], nil
+}
+
+// Categories returns the loaded categories
+func (t *Tagger) Categories() []string {
+	return append([]string(nil), t.categories...)
+}
+
+// Manifest returns the manifest of the loaded artifacts, nil if they have none
+func (t *Tagger) Manifest() *bundle.Manifest {
+	return t.manifest
+}
+
+// Version returns the model release version of the manifest, "" if there is none
+func (t *Tagger) Version() string {
+	if t.manifest == nil {
+		return ""
+	}
+	return t.manifest.Version
+}
+
+// DedupStats returns the inputs passed to the models since the Tagger was
+// loaded and the unique ones among them. Inputs with the same normalized
+// form, lowercased without accents and with runs of whitespace collapsed,
+// are predicted once per call. Inputs corrected in every category are not
+// counted.
+func (t *Tagger) DedupStats() DedupStats {
+	return t.predictor.DedupStats()
+}
+
+// Labels returns the labels of a loaded category
+func (t *Tagger) Labels(category string) ([]string, error) {
+	labels, exists := t.predictor.Labels(category)
+	if !exists {
+		return nil, fmt.Errorf("%w %s", ErrUnknownCategory, category)
+	}
+	return append([]string(nil), labels...), nil
+}
+
+// Predict predicts the tags of a single input
+func (t *Tagger) Predict(ctx context.Context, input string, opts *PredictOptions) (Result, error) {
+	results, err := t.PredictBatch(ctx, []string{input}, opts)
+	if results == nil {
+		return Result{}, err
+	}
+	return results[0], err
+}
+
+// PredictBatch predicts the tags of every input, returning results in input
+// order. The error joins a *CategoryError for every category that failed.
+func (t *Tagger) PredictBatch(ctx context.Context, inputs []string, opts *PredictOptions) ([]Result, error) {
+	if opts == nil {
+		opts = &PredictOptions{}
+	}
+
+	categories := opts.Categories
+	if len(categories) == 0 {
+		categories = t.categories
+	}
+	for _, category := range categories {
+		if _, exists := t.predictor.Labels(category); !exists {
+			return nil, fmt.Errorf("%w %s, known categories: %s", ErrUnknownCategory, category, strings.Join(t.categories, ", "))
+		}
+	}
+	locale := ""
+	if opts.Names != nil {
+		var err error
+		if locale, err = opts.Names.Resolve(opts.Locale); err != nil {
+			return nil, err
+		}
+	}
+
+	// Corrected categories are not predicted, inputs corrected in every
+	// category skip the model
+	corrected := make([]map[string]string, len(inputs))
+	var modelInputs []string
+	var modelIndices []int
+	for i, input := range inputs {
+		if opts.Corrections != nil {
+			if correction, exists := opts.Corrections.Get(input); exists {
+				corrected[i] = correction.Tags
+			}
+		}
+		for _, category := range categories {
+			if _, exists := corrected[i][category]; !exists {
+				modelInputs = append(modelInputs, input)
+				modelIndices = append(modelIndices, i)
+				break
+			}
+		}
+	}
+
+	var probabilities map[string][][]float64
+	var err error
+	if len(modelInputs) > 0 {
+		probabilities, err = t.predictor.PredictProbaContext(ctx, modelInputs, categories, opts.BestEffort)
+		if probabilities == nil || (err != nil && !opts.BestEffort) {
+			return nil, err
+		}
+	}
+
+	results := make([]Result, len(inputs))
+	candidates := make([]map[string][]rules.Candidate, len(inputs))
+	for i, input := range inputs {
+		results[i] = Result{Input: input, Tags: make(map[string]Prediction, len(categories))}
+		candidates[i] = make(map[string][]rules.Candidate, len(categories))
+	}
+	for category, probs := range probabilities {
+		labels, _ := t.predictor.Labels(category)
+		for j, prob := range probs {
+			i := modelIndices[j]
+			if _, exists := corrected[i][category]; !exists {
+				candidates[i][category] = rankLabels(labels, prob)
+			}
+		}
+	}
+
+	for i := range results {
+		for category, ranked := range candidates[i] {
+			prediction := Prediction{Label: ranked[0].Label, Probability: ranked[0].Probability, Source: SourceModel}
+			for _, candidate := range ranked[:min(opts.TopK, len(ranked))] {
+				prediction.TopK = append(prediction.TopK, LabelProbability{Label: candidate.Label, Probability: candidate.Probability})
+			}
+			results[i].Tags[category] = prediction
+		}
+
+		// Overrides narrow the candidates, so rule resolution keeps their labels
+		set := make(map[string]bool)
+		if opts.Overrides != nil {
+			for _, firing := range opts.Overrides.Apply(results[i].Input, candidates[i]) {
+				prediction := results[i].Tags[firing.Category]
+				replaceLabel(&prediction, candidates[i][firing.Category][0])
+				prediction.Override = firing.Override
+				results[i].Tags[firing.Category] = prediction
+				set[firing.Category] = firing.Action == overrides.ActionSet
+			}
+		}
+
+		// Corrections are certain and never changed by overrides or rules
+		for _, category := range categories {
+			if label, exists := corrected[i][category]; exists {
+				results[i].Tags[category] = Prediction{Label: label, Probability: 1, Source: SourceCorrection}
+				candidates[i][category] = []rules.Candidate{{Label: label, Probability: 1}}
+				set[category] = true
+			}
+		}
+
+		if opts.Rules != nil {
+			applyRules(&results[i], candidates[i], opts.Rules, opts.Resolve)
+		}
+
+		for category, prediction := range results[i].Tags {
+			if set[category] {
+				// Labels set by an override or a correction are kept regardless of the model
+				continue
+			}
+			threshold := opts.Threshold
+			if value, exists := opts.Thresholds[category]; exists {
+				threshold = value
+			}
+			if prediction.Probability < threshold {
+				prediction.Label = ""
+				results[i].Tags[category] = prediction
+			}
+		}
+
+		if opts.Attributes {
+			parsed := attributes.Parse(results[i].Input)
+			results[i].Attributes = &parsed
+			results[i].Conflicts = parsed.Check(results[i].Labels())
+		}
+		if opts.Names != nil {
+			// The locale is resolved, so rendering cannot fail
+			results[i].RoomName, _ = opts.Names.Render(locale, results[i].Labels())
+		}
+	}
+
+	return results, err
+}
+
+// SparseVector returns the nonzero entries of the L2-normalized TF-IDF vector
+// of an input, the features of the models, by increasing index
+func (t *Tagger) SparseVector(input string) ([]int32, []float32) {
+	return tfidf.CalculateSparseTfIdfVector(input, t.predictor.TfidfData)
+}
+
+// Explain explains the label predicted for input in a category. Every n-gram
+// of the input is left out in turn and the resulting change in probability of
+// the predicted label is reported, largest changes first.
+func (t *Tagger) Explain(ctx context.Context, input string, category string) (*Explanation, error) {
+	if _, exists := t.predictor.Labels(category); !exists {
+		return nil, fmt.Errorf("%w %s, known categories: %s", ErrUnknownCategory, category, strings.Join(t.categories, ", "))
+	}
+	return t.predictor.ExplainContext(ctx, input, category)
+}
+
+// rankLabels returns the labels by decreasing probability, the predicted label first
+func rankLabels(labels []string, probabilities []float64) []rules.Candidate {
+	best := model.BestLabel(probabilities)
+	order := make([]int, 0, len(probabilities))
+	order = append(order, best)
+	for i := range probabilities {
+		if i != best {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order[1:], func(a, b int) bool {
+		return probabilities[order[1+a]] > probabilities[order[1+b]]
+	})
+
+	ranked := make([]rules.Candidate, len(order))
+	for i, index := range order {
+		ranked[i] = rules.Candidate{Label: labels[index], Probability: probabilities[index]}
+	}
+	return ranked
+}
+
+// applyRules records the violations of the predicted labels, or resolves them
+func applyRules(result *Result, candidates map[string][]rules.Candidate, ruleSet *rules.RuleSet, resolve bool) {
+	if !resolve {
+		result.Violations = ruleSet.Check(result.Input, result.Labels())
+		return
+	}
+
+	resolved, violations := ruleSet.Resolve(result.Input, candidates)
+	result.Violations = violations
+	for category, label := range resolved {
+		prediction := result.Tags[category]
+		if label == prediction.Label {
+			continue
+		}
+		for _, candidate := range candidates[category] {
+			if candidate.Label == label {
+				replaceLabel(&prediction, candidate)
+			}
+		}
+		result.Tags[category] = prediction
+	}
+}
+
+// replaceLabel replaces the predicted label, keeping the most probable one as Original
+func replaceLabel(prediction *Prediction, candidate rules.Candidate) {
+	if candidate.Label == prediction.Label {
+		return
+	}
+	if prediction.Original == "" {
+		prediction.Original = prediction.Label
+	}
+	prediction.Label = candidate.Label
+	prediction.Probability = candidate.Probability
+}
+
+// ViolationsColumn is the header of the violated rules column added by AppendViolations
+const ViolationsColumn = "violations"
+
+// ViolatedRules returns the names of the violated rules separated by semicolons
+func (r Result) ViolatedRules() string {
+	names := make([]string, len(r.Violations))
+	for i, violation := range r.Violations {
+		names[i] = violation.Rule
+	}
+	return strings.Join(names, ";")
+}
+
+// AppendViolations adds the violated rules of each result as a last column to
+// rows built from results, e.g. by Table
+func AppendViolations(headers []string, rows [][]string, results []Result) ([]string, [][]string) {
+	headers = append(headers, ViolationsColumn)
+	for i, result := range results {
+		rows[i] = append(rows[i], result.ViolatedRules())
+	}
+	return headers, rows
+}
+
+// SourceColumn is the header of the source column added by AppendSources
+const SourceColumn = "source"
+
+// Source returns the source of the tags of the result, SourceMixed if they
+// come from both the model and corrections
+func (r Result) Source() string {
+	source := ""
+	for _, prediction := range r.Tags {
+		switch {
+		case source == "":
+			source = prediction.Source
+		case source != prediction.Source:
+			return SourceMixed
+		}
+	}
+	if source == "" {
+		return SourceModel
+	}
+	return source
+}
+
+// AppendSources adds the source of each result as a last column to rows
+// built from results, e.g. by Table
+func AppendSources(headers []string, rows [][]string, results []Result) ([]string, [][]string) {
+	headers = append(headers, SourceColumn)
+	for i, result := range results {
+		rows[i] = append(rows[i], result.Source())
+	}
+	return headers, rows
+}
+
+// OverridesColumn is the header of the fired overrides column added by AppendOverrides
+const OverridesColumn = "overrides"
+
+// FiredOverrides returns the fired overrides as category=override pairs
+// separated by semicolons, ordered by category
+func (r Result) FiredOverrides() string {
+	var fired []string
+	for category, prediction := range r.Tags {
+		if prediction.Override != "" {
+			fired = append(fired, category+"="+prediction.Override)
+		}
+	}
+	sort.Strings(fired)
+	return strings.Join(fired, ";")
+}
+
+// AppendOverrides adds the fired overrides of each result as a last column to
+// rows built from results, e.g. by Table
+func AppendOverrides(headers []string, rows [][]string, results []Result) ([]string, [][]string) {
+	headers = append(headers, OverridesColumn)
+	for i, result := range results {
+		rows[i] = append(rows[i], result.FiredOverrides())
+	}
+	return headers, rows
+}
+
+// RoomNameColumn is the header of the room name column added by AppendRoomNames
+const RoomNameColumn = "room_name"
+
+// AppendRoomNames adds the room name of each result as a last column to rows
+// built from results, e.g. by Table
+func AppendRoomNames(headers []string, rows [][]string, results []Result) ([]string, [][]string) {
+	headers = append(headers, RoomNameColumn)
+	for i, result := range results {
+		rows[i] = append(rows[i], result.RoomName)
+	}
+	return headers, rows
+}
+
+// Attribute columns added by AppendAttributes
+const (
+	BedsColumn         = "beds"
+	AreaColumn         = "area_m2"
+	OccupancyColumn    = "occupancy"
+	BedroomCountColumn = "bedroom_count"
+	FloorNumberColumn  = "floor_number"
+	ConflictsColumn    = "conflicts"
+)
+
+// AttributeColumns are the headers of the columns added by AppendAttributes
+var AttributeColumns = []string{BedsColumn, AreaColumn, OccupancyColumn, BedroomCountColumn, FloorNumberColumn, ConflictsColumn}
+
+// AttributeValues returns the attributes of the result as strings keyed by
+// the attribute columns, empty for attributes not stated. Beds are separated
+// by semicolons, e.g. "1 sofa;2 twin", conflicts are the categories whose
+// label is contradicted.
+func (r Result) AttributeValues() map[string]string {
+	values := make(map[string]string, len(AttributeColumns))
+	for _, column := range AttributeColumns {
+		values[column] = ""
+	}
+	if r.Attributes == nil {
+		return values
+	}
+
+	beds := make([]string, len(r.Attributes.Beds))
+	for i, bed := range r.Attributes.Beds {
+		beds[i] = bed.String()
+	}
+	values[BedsColumn] = strings.Join(beds, ";")
+	if r.Attributes.AreaM2 > 0 {
+		values[AreaColumn] = strconv.FormatFloat(r.Attributes.AreaM2, 'f', -1, 64)
+	}
+	if r.Attributes.Occupancy > 0 {
+		values[OccupancyColumn] = strconv.Itoa(r.Attributes.Occupancy)
+	}
+	if r.Attributes.Bedrooms > 0 {
+		values[BedroomCountColumn] = strconv.Itoa(r.Attributes.Bedrooms)
+	}
+	if r.Attributes.Floor != nil {
+		values[FloorNumberColumn] = strconv.Itoa(*r.Attributes.Floor)
+	}
+	conflicts := make([]string, len(r.Conflicts))
+	for i, conflict := range r.Conflicts {
+		conflicts[i] = conflict.Category
+	}
+	values[ConflictsColumn] = strings.Join(conflicts, ";")
+	return values
+}
+
+// AppendAttributes adds the attribute columns of each result as last columns
+// to rows built from results, e.g. by Table
+func AppendAttributes(headers []string, rows [][]string, results []Result) ([]string, [][]string) {
+	headers = append(headers, AttributeColumns...)
+	for i, result := range results {
+		values := result.AttributeValues()
+		for _, column := range AttributeColumns {
+			rows[i] = append(rows[i], values[column])
+		}
+	}
+	return headers, rows
+}
+
+// Table converts results to rows of the input followed by the label of each
+// category, as written by the CLI and the tabular API formats
+func Table(inputHeader string, categories []string, results []Result) ([]string, [][]string) {
+	headers := append([]string{inputHeader}, categories...)
+	rows := make([][]string, len(results))
+	for i, result := range results {
+		row := make([]string, len(headers))
+		row[0] = result.Input
+		for j, category := range categories {
+			row[j+1] = result.Tags[category].Label
+		}
+		rows[i] = row
+	}
+	return headers, rows
+}
@@ -0,0 +1,293 @@
+package tagger
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-goal/tagger/pkg/corrections"
+	"github.com/go-goal/tagger/pkg/overrides"
+	"github.com/go-goal/tagger/pkg/rules"
+)
+
+// The linear models of testdata/artifacts predict, for the inputs below:
+//
+//	"suite"  class suite 0.95, room 0.05  view undefined 0.73, sea view 0.27
+//	"room"   class room 0.73, suite 0.27  view undefined 0.73, sea view 0.27
+const testArtifacts = "../../testdata/artifacts"
+
+func newTestTagger(t *testing.T, opts *Options) *Tagger {
+	t.Helper()
+	tagger, err := NewFS(os.DirFS(testArtifacts), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tagger
+}
+
+// tag is the part of a Prediction set by the pipeline stages
+type tag struct {
+	Label    string
+	Source   string
+	Original string
+	Override string
+}
+
+func TestPredictBatchPipelineOrder(t *testing.T) {
+	tagger := newTestTagger(t, nil)
+
+	const suiteNeedsSeaView = `
+version: "1"
+rules:
+  - name: suite-sea-view
+    when:
+      tags:
+        class: [suite]
+    require:
+      view: [sea view]
+`
+	tests := []struct {
+		name        string
+		input       string
+		corrections map[string]string
+		overrides   string
+		rules       string
+		threshold   float64
+		thresholds  map[string]float64
+		want        map[string]tag
+	}{
+		{
+			name:  "model",
+			input: "suite",
+			want: map[string]tag{
+				"class": {Label: "suite", Source: SourceModel},
+				"view":  {Label: "undefined", Source: SourceModel},
+			},
+		},
+		{
+			name:       "thresholds empty uncertain labels",
+			input:      "room",
+			threshold:  0.8,
+			thresholds: map[string]float64{"view": 0.5},
+			want: map[string]tag{
+				"class": {Label: "", Source: SourceModel},
+				"view":  {Label: "undefined", Source: SourceModel},
+			},
+		},
+		{
+			name:  "overrides set labels before thresholds",
+			input: "room",
+			overrides: `
+version: "1"
+overrides:
+  - name: room-is-suite
+    match:
+      keywords: [room]
+    set:
+      class: suite
+`,
+			threshold: 0.8,
+			want: map[string]tag{
+				"class": {Label: "suite", Source: SourceModel, Original: "room", Override: "room-is-suite"},
+				"view":  {Label: "", Source: SourceModel},
+			},
+		},
+		{
+			name:  "thresholds apply to the labels left by forbidding overrides",
+			input: "suite",
+			overrides: `
+version: "1"
+overrides:
+  - name: always-a-view
+    match:
+      keywords: [suite]
+    forbid:
+      view: [undefined]
+`,
+			threshold: 0.5,
+			want: map[string]tag{
+				"class": {Label: "suite", Source: SourceModel},
+				"view":  {Label: "", Source: SourceModel, Original: "undefined", Override: "always-a-view"},
+			},
+		},
+		{
+			name:        "corrections take precedence over overrides",
+			input:       "suite",
+			corrections: map[string]string{"class": "room"},
+			overrides: `
+version: "1"
+overrides:
+  - name: suite-keyword
+    match:
+      keywords: [suite]
+    set:
+      class: suite
+`,
+			want: map[string]tag{
+				"class": {Label: "room", Source: SourceCorrection},
+				"view":  {Label: "undefined", Source: SourceModel},
+			},
+		},
+		{
+			name:  "rules resolve the labels left by overrides",
+			input: "suite",
+			overrides: `
+version: "1"
+overrides:
+  - name: suite-is-room
+    match:
+      keywords: [suite]
+    set:
+      class: room
+`,
+			rules: suiteNeedsSeaView,
+			want: map[string]tag{
+				"class": {Label: "room", Source: SourceModel, Original: "suite", Override: "suite-is-room"},
+				"view":  {Label: "undefined", Source: SourceModel},
+			},
+		},
+		{
+			name:  "rules resolve the model labels",
+			input: "suite",
+			rules: suiteNeedsSeaView,
+			want: map[string]tag{
+				"class": {Label: "suite", Source: SourceModel},
+				"view":  {Label: "sea view", Source: SourceModel, Original: "undefined"},
+			},
+		},
+		{
+			name:        "rules resolve around corrections",
+			input:       "suite",
+			corrections: map[string]string{"view": "undefined"},
+			rules:       suiteNeedsSeaView,
+			want: map[string]tag{
+				"class": {Label: "room", Source: SourceModel, Original: "suite"},
+				"view":  {Label: "undefined", Source: SourceCorrection},
+			},
+		},
+		{
+			name:        "corrections feed the rules",
+			input:       "room",
+			corrections: map[string]string{"class": "suite"},
+			rules:       suiteNeedsSeaView,
+			threshold:   0.5,
+			want: map[string]tag{
+				"class": {Label: "suite", Source: SourceCorrection},
+				"view":  {Label: "", Source: SourceModel, Original: "undefined"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &PredictOptions{Threshold: tt.threshold, Thresholds: tt.thresholds, Resolve: true}
+			if tt.corrections != nil {
+				store, err := corrections.Open(filepath.Join(t.TempDir(), "corrections.jsonl"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer store.Close()
+				if err := store.Put(corrections.Correction{Input: tt.input, Tags: tt.corrections}); err != nil {
+					t.Fatal(err)
+				}
+				opts.Corrections = store
+			}
+			if tt.overrides != "" {
+				set, err := overrides.Parse([]byte(tt.overrides))
+				if err != nil {
+					t.Fatal(err)
+				}
+				opts.Overrides = set
+			}
+			if tt.rules != "" {
+				ruleSet, err := rules.Parse([]byte(tt.rules))
+				if err != nil {
+					t.Fatal(err)
+				}
+				opts.Rules = ruleSet
+			}
+
+			result, err := tagger.Predict(context.Background(), tt.input, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for category, want := range tt.want {
+				prediction := result.Tags[category]
+				got := tag{Label: prediction.Label, Source: prediction.Source, Original: prediction.Original, Override: prediction.Override}
+				if got != want {
+					t.Errorf("%s = %+v, want %+v", category, got, want)
+				}
+			}
+			if len(result.Violations) != 0 {
+				t.Errorf("violations %v, want none", result.Violations)
+			}
+		})
+	}
+}
+
+func TestPredictBatchDedup(t *testing.T) {
+	cache := NewCache(10, 0)
+	tagger := newTestTagger(t, &Options{Cache: cache})
+
+	tests := []struct {
+		name      string
+		inputs    []string
+		want      []string
+		wantDedup DedupStats
+		wantCache CacheStats
+	}{
+		{
+			name:      "duplicates are predicted once",
+			inputs:    []string{"Suite", "room", " SUITE ", "Room", "Suïte", "suite"},
+			want:      []string{"suite", "room", "suite", "room", "suite", "suite"},
+			wantDedup: DedupStats{Inputs: 6, Unique: 2},
+			wantCache: CacheStats{Misses: 2, Entries: 2},
+		},
+		{
+			name:      "cached across batches",
+			inputs:    []string{"ROOM", "Sea Suite", "room", "suite"},
+			want:      []string{"room", "suite", "room", "suite"},
+			wantDedup: DedupStats{Inputs: 10, Unique: 5},
+			wantCache: CacheStats{Hits: 2, Misses: 3, Entries: 3},
+		},
+		{
+			name:      "only cache hits",
+			inputs:    []string{"sea  suite", "Room"},
+			want:      []string{"suite", "room"},
+			wantDedup: DedupStats{Inputs: 12, Unique: 7},
+			wantCache: CacheStats{Hits: 4, Misses: 3, Entries: 3},
+		},
+	}
+
+	// probabilities holds the class probability of every normalized rate name
+	probabilities := make(map[string]float64)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := tagger.PredictBatch(context.Background(), tt.inputs, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != len(tt.inputs) {
+				t.Fatalf("%d results for %d inputs", len(results), len(tt.inputs))
+			}
+			for i, result := range results {
+				if result.Input != tt.inputs[i] || result.Tags["class"].Label != tt.want[i] {
+					t.Errorf("result %d = %q %s, want %q %s", i, result.Input, result.Tags["class"].Label, tt.inputs[i], tt.want[i])
+				}
+				// Every form of a rate name gets the probability of the first one
+				key, probability := corrections.Key(result.Input), result.Tags["class"].Probability
+				if want, exists := probabilities[key]; exists && probability != want {
+					t.Errorf("result %d %q has probability %v, want %v", i, result.Input, probability, want)
+				}
+				probabilities[key] = probability
+			}
+			if stats := tagger.DedupStats(); stats != tt.wantDedup {
+				t.Errorf("dedup stats %+v, want %+v", stats, tt.wantDedup)
+			}
+			if stats := cache.Stats(); stats != tt.wantCache {
+				t.Errorf("cache stats %+v, want %+v", stats, tt.wantCache)
+			}
+		})
+	}
+}
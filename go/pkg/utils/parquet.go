@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// printParquet writes headers and rows as a Parquet file with one string column per header
+func printParquet(w io.Writer, headers []string, rows [][]string) error {
+	group := parquet.Group{}
+	for _, header := range headers {
+		if _, exists := group[header]; exists {
+			return fmt.Errorf("duplicate column '%s' in Parquet output", header)
+		}
+		group[header] = parquet.String()
+	}
+	schema := parquet.NewSchema("predictions", group)
+
+	// Group fields are sorted by name, so map each header to its leaf column index
+	columnIndexes := make([]int, len(headers))
+	for i, path := range schema.Columns() {
+		columnIndexes[IndexOf(headers, path[0])] = i
+	}
+
+	parquetRows := make([]parquet.Row, len(rows))
+	for i, row := range rows {
+		parquetRow := make(parquet.Row, len(headers))
+		for j := range headers {
+			var value string
+			if j < len(row) {
+				value = row[j]
+			}
+			parquetRow[columnIndexes[j]] = parquet.ByteArrayValue([]byte(value)).Level(0, 0, columnIndexes[j])
+		}
+		parquetRows[i] = parquetRow
+	}
+
+	writer := parquet.NewWriter(w, schema)
+	if _, err := writer.WriteRows(parquetRows); err != nil {
+		return fmt.Errorf("error writing Parquet rows: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("error writing Parquet file: %v", err)
+	}
+	return nil
+}
@@ -1,16 +1,96 @@
 package utils
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
+// Supported input and output formats
+const (
+	FormatCSV     = "csv"
+	FormatTSV     = "tsv"
+	FormatJSON    = "json"
+	FormatJSONL   = "jsonl"
+	FormatYAML    = "yaml"
+	FormatParquet = "parquet"
+)
+
+// Formats lists all supported formats
+var Formats = []string{FormatCSV, FormatTSV, FormatJSON, FormatJSONL, FormatYAML, FormatParquet}
+
+// formatMediaTypes maps each format to its media types, the first one being canonical
+var formatMediaTypes = map[string][]string{
+	FormatCSV:     {"text/csv", "application/csv"},
+	FormatTSV:     {"text/tab-separated-values", "text/tsv"},
+	FormatJSON:    {"application/json", "text/json"},
+	FormatJSONL:   {"application/x-ndjson", "application/jsonl", "application/x-jsonlines"},
+	FormatYAML:    {"application/yaml", "application/x-yaml", "text/yaml"},
+	FormatParquet: {"application/vnd.apache.parquet", "application/x-parquet"},
+}
+
+// ParseFormat normalizes a format name and checks that it is supported
+func ParseFormat(format string) (string, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	switch format {
+	case "ndjson":
+		format = FormatJSONL
+	case "yml":
+		format = FormatYAML
+	}
+	if _, ok := formatMediaTypes[format]; !ok {
+		return "", fmt.Errorf("unsupported format: %s", format)
+	}
+	return format, nil
+}
+
+// ContentType returns the canonical media type of a format
+func ContentType(format string) string {
+	if mediaTypes, ok := formatMediaTypes[format]; ok {
+		return mediaTypes[0]
+	}
+	return "application/octet-stream"
+}
+
+// MediaTypes returns all media types of a format, the canonical one first
+func MediaTypes(format string) []string {
+	return formatMediaTypes[format]
+}
+
+// FormatFromContentType returns the format matching a media type or "" if there is none
+func FormatFromContentType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	for format, mediaTypes := range formatMediaTypes {
+		for _, t := range mediaTypes {
+			if t == mediaType {
+				return format
+			}
+		}
+	}
+	return ""
+}
+
+// FormatFromFilename returns the format matching a file extension or "" if there is none
+func FormatFromFilename(filename string) string {
+	format, err := ParseFormat(strings.TrimPrefix(filepath.Ext(filename), "."))
+	if err != nil {
+		return ""
+	}
+	return format
+}
+
 // ReadFirstCSVColumn reads a CSV file and returns a slice of strings for a specified column
 func ReadFirstCSVColumn(filePath string, columnName string) ([]string, error) {
 	file, err := os.Open(filePath)
@@ -29,25 +109,44 @@ func ReadFirstCSVColumn(filePath string, columnName string) ([]string, error) {
 		return nil, fmt.Errorf("no records found in CSV")
 	}
 
-	if len(records[0]) == 0 {
-		return nil, fmt.Errorf("provide a valid CSV file")
+	return selectColumn(records[0], records[1:], columnName)
+}
+
+// ReadColumn reads a file in the format given by its extension (CSV by default)
+// and returns a slice of strings for a specified column
+func ReadColumn(filePath string, columnName string) ([]string, error) {
+	format := FormatFromFilename(filePath)
+	if format == "" || format == FormatCSV {
+		return ReadFirstCSVColumn(filePath, columnName)
 	}
 
-	// Find the index of the specified column
-	columnIndex := -1
-	for i, header := range records[0] {
-		if header == columnName {
-			columnIndex = i
-			break
-		}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %v", err)
 	}
+	defer file.Close()
 
+	headers, rows, err := ReadRows(file, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return selectColumn(headers, rows, columnName)
+}
+
+// selectColumn returns the values of the named column
+func selectColumn(headers []string, rows [][]string, columnName string) ([]string, error) {
+	if len(headers) == 0 {
+		return nil, fmt.Errorf("provide a valid CSV file")
+	}
+
+	columnIndex := IndexOf(headers, columnName)
 	if columnIndex == -1 {
 		return nil, fmt.Errorf("column '%s' not found in CSV", columnName)
 	}
 
-	columnRecords := make([]string, len(records)-1)
-	for i, record := range records[1:] {
+	columnRecords := make([]string, len(rows))
+	for i, record := range rows {
 		if len(record) > columnIndex {
 			columnRecords[i] = record[columnIndex]
 		} else {
@@ -58,6 +157,151 @@ func ReadFirstCSVColumn(filePath string, columnName string) ([]string, error) {
 	return columnRecords, nil
 }
 
+// IndexOf returns the index of the first occurrence of value in values or -1
+func IndexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// ReadRows reads a table in the given format and returns its headers and rows.
+//
+// JSON input must be an array of objects, JSONL input one object per line.
+// Headers are collected in order of first appearance.
+func ReadRows(r io.Reader, format string) ([]string, [][]string, error) {
+	switch format {
+	case FormatCSV, FormatTSV:
+		reader := csv.NewReader(r)
+		if format == FormatTSV {
+			reader.Comma = '\t'
+			reader.LazyQuotes = true
+		}
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading %s: %v", strings.ToUpper(format), err)
+		}
+		if len(records) == 0 {
+			return nil, nil, fmt.Errorf("no records found in %s", strings.ToUpper(format))
+		}
+		return records[0], records[1:], nil
+	case FormatJSON:
+		decoder := json.NewDecoder(r)
+		if err := expectDelim(decoder, '['); err != nil {
+			return nil, nil, fmt.Errorf("error decoding JSON: expected an array of objects: %v", err)
+		}
+		var objects []orderedObject
+		for decoder.More() {
+			object, err := decodeOrderedObject(decoder)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error decoding JSON: %v", err)
+			}
+			objects = append(objects, object)
+		}
+		headers, rows := objectsToRows(objects)
+		return headers, rows, nil
+	case FormatJSONL:
+		var objects []orderedObject
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			object, err := decodeOrderedObject(json.NewDecoder(bytes.NewReader(scanner.Bytes())))
+			if err != nil {
+				return nil, nil, fmt.Errorf("error decoding JSONL line %d: %v", line, err)
+			}
+			objects = append(objects, object)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, nil, fmt.Errorf("error reading JSONL: %v", err)
+		}
+		headers, rows := objectsToRows(objects)
+		return headers, rows, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported input format: %s", format)
+	}
+}
+
+// orderedObject is a flat JSON object with its keys in document order
+type orderedObject struct {
+	keys   []string
+	values map[string]string
+}
+
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := token.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("unexpected token %v", token)
+	}
+	return nil
+}
+
+func decodeOrderedObject(decoder *json.Decoder) (orderedObject, error) {
+	object := orderedObject{values: make(map[string]string)}
+	if err := expectDelim(decoder, '{'); err != nil {
+		return object, fmt.Errorf("expected an object: %v", err)
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return object, err
+		}
+		key := token.(string)
+
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return object, err
+		}
+
+		var value string
+		switch {
+		case bytes.Equal(raw, []byte("null")):
+		case len(raw) > 0 && raw[0] == '"':
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return object, err
+			}
+		default:
+			value = string(raw)
+		}
+
+		if _, exists := object.values[key]; !exists {
+			object.keys = append(object.keys, key)
+		}
+		object.values[key] = value
+	}
+	return object, expectDelim(decoder, '}')
+}
+
+func objectsToRows(objects []orderedObject) ([]string, [][]string) {
+	var headers []string
+	seen := make(map[string]bool)
+	for _, object := range objects {
+		for _, key := range object.keys {
+			if !seen[key] {
+				seen[key] = true
+				headers = append(headers, key)
+			}
+		}
+	}
+
+	rows := make([][]string, len(objects))
+	for i, object := range objects {
+		row := make([]string, len(headers))
+		for j, header := range headers {
+			row[j] = object.values[header]
+		}
+		rows[i] = row
+	}
+	return headers, rows
+}
+
 // ReadJsonStringArray loads a JSON file and returns a slice of strings
 func ReadJsonStringArray(filePath string) ([]string, error) {
 	file, err := os.Open(filePath)
@@ -75,32 +319,88 @@ func ReadJsonStringArray(filePath string) ([]string, error) {
 	return labels, nil
 }
 
-// WriteCSV writes a CSV file with the provided headers, first column data, and row data.
+// BuildRows converts first column data and row data into rows ordered by headers.
 //
 // rowData is a map of the form map[string]map[string]string
 // where the keys of the outer map are the first column data and the keys of the inner map are the headers
-func WriteCSV(outputFile string, headers []string, firstColData []string, rowData map[string]map[string]string) error {
+func BuildRows(headers []string, firstColData []string, rowData map[string]map[string]string) [][]string {
+	rows := make([][]string, len(firstColData))
+	for i, inputValue := range firstColData {
+		row := make([]string, len(headers))
+		row[0] = inputValue
+		for j := 1; j < len(headers); j++ {
+			row[j] = rowData[inputValue][headers[j]]
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+// PrintRows writes headers and rows to the given writer in the specified format
+func PrintRows(w io.Writer, format string, headers []string, rows [][]string) error {
+	switch format {
+	case FormatCSV, FormatTSV:
+		writer := csv.NewWriter(w)
+		if format == FormatTSV {
+			writer.Comma = '\t'
+		}
+		writer.Write(headers)
+		writer.WriteAll(rows)
+		return writer.Error()
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rowsToMaps(headers, rows))
+	case FormatJSONL:
+		encoder := json.NewEncoder(w)
+		for _, row := range rowsToMaps(headers, rows) {
+			if err := encoder.Encode(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatYAML:
+		encoder := yaml.NewEncoder(w)
+		return encoder.Encode(rowsToMaps(headers, rows))
+	case FormatParquet:
+		return printParquet(w, headers, rows)
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// WriteRows writes headers and rows to a file in the specified format
+func WriteRows(outputFile, format string, headers []string, rows [][]string) error {
 	file, err := os.Create(outputFile)
 	if err != nil {
 		return fmt.Errorf("error creating output file: %v", err)
 	}
 	defer file.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	return PrintRows(file, format, headers, rows)
+}
 
-	writer.Write(headers)
+// PrintOutput prints the output to the given writer in the specified format
+func PrintOutput(w io.Writer, format string, headers []string, firstColData []string, rowData map[string]map[string]string) error {
+	format, err := ParseFormat(format)
+	if err != nil {
+		return err
+	}
+	return PrintRows(w, format, headers, BuildRows(headers, firstColData, rowData))
+}
 
-	for _, inputValue := range firstColData {
-		row := make([]string, len(headers))
-		row[0] = inputValue
-		for j := 1; j < len(headers); j++ {
-			row[j] = rowData[inputValue][headers[j]]
-		}
-		writer.Write(row)
+// WriteOutput writes the output in the specified format
+func WriteOutput(outputFile, format string, headers []string, firstColData []string, rowData map[string]map[string]string) error {
+	format, err := ParseFormat(format)
+	if err != nil {
+		return err
 	}
+	return WriteRows(outputFile, format, headers, BuildRows(headers, firstColData, rowData))
+}
 
-	return nil
+// WriteCSV writes a CSV file with the provided headers, first column data, and row data.
+func WriteCSV(outputFile string, headers []string, firstColData []string, rowData map[string]map[string]string) error {
+	return WriteRows(outputFile, FormatCSV, headers, BuildRows(headers, firstColData, rowData))
 }
 
 // IsFile checks if a file exists and is not a directory
@@ -112,136 +412,69 @@ func IsFile(path string) bool {
 	return !info.IsDir()
 }
 
-// WriteOutput writes the output in the specified format
-func WriteOutput(outputFile, format string, headers []string, firstColData []string, rowData map[string]map[string]string) error {
-	switch strings.ToLower(format) {
-	case "csv":
-		return WriteCSV(outputFile, headers, firstColData, rowData)
-	case "json":
-		return WriteJSON(outputFile, headers, firstColData, rowData)
-	case "tsv":
-		return WriteTSV(outputFile, headers, firstColData, rowData)
-	case "yaml":
-		return WriteYAML(outputFile, headers, firstColData, rowData)
-	default:
-		return fmt.Errorf("unsupported format: %s", format)
-	}
-}
-
 // PrintCSV prints CSV data to the given writer
 func PrintCSV(w io.Writer, headers []string, firstColData []string, rowData map[string]map[string]string) {
-	writer := csv.NewWriter(w)
-	defer writer.Flush()
-
-	writer.Write(headers)
-
-	for _, inputValue := range firstColData {
-		row := make([]string, len(headers))
-		row[0] = inputValue
-		for j := 1; j < len(headers); j++ {
-			row[j] = rowData[inputValue][headers[j]]
-		}
-		writer.Write(row)
-	}
+	PrintRows(w, FormatCSV, headers, BuildRows(headers, firstColData, rowData))
 }
 
 // PrintJSON prints JSON data to the given writer
 func PrintJSON(w io.Writer, headers []string, firstColData []string, rowData map[string]map[string]string) {
-	data := prepareOutputData(headers, firstColData, rowData)
-	encoder := json.NewEncoder(w)
-	encoder.SetIndent("", "  ")
-	encoder.Encode(data)
+	PrintRows(w, FormatJSON, headers, BuildRows(headers, firstColData, rowData))
+}
+
+// PrintJSONL prints JSON Lines data to the given writer
+func PrintJSONL(w io.Writer, headers []string, firstColData []string, rowData map[string]map[string]string) {
+	PrintRows(w, FormatJSONL, headers, BuildRows(headers, firstColData, rowData))
 }
 
 // PrintTSV prints TSV data to the given writer
 func PrintTSV(w io.Writer, headers []string, firstColData []string, rowData map[string]map[string]string) {
-	writer := csv.NewWriter(w)
-	writer.Comma = '\t'
-	defer writer.Flush()
-
-	writer.Write(headers)
-
-	for _, inputValue := range firstColData {
-		row := make([]string, len(headers))
-		row[0] = inputValue
-		for j := 1; j < len(headers); j++ {
-			row[j] = rowData[inputValue][headers[j]]
-		}
-		writer.Write(row)
-	}
+	PrintRows(w, FormatTSV, headers, BuildRows(headers, firstColData, rowData))
 }
 
 // PrintYAML prints YAML data to the given writer
 func PrintYAML(w io.Writer, headers []string, firstColData []string, rowData map[string]map[string]string) {
-	data := prepareOutputData(headers, firstColData, rowData)
-	encoder := yaml.NewEncoder(w)
-	encoder.Encode(data)
+	PrintRows(w, FormatYAML, headers, BuildRows(headers, firstColData, rowData))
 }
 
 // WriteJSON writes JSON data to a file
 func WriteJSON(outputFile string, headers []string, firstColData []string, rowData map[string]map[string]string) error {
-	file, err := os.Create(outputFile)
-	if err != nil {
-		return fmt.Errorf("error creating output file: %v", err)
-	}
-	defer file.Close()
+	return WriteRows(outputFile, FormatJSON, headers, BuildRows(headers, firstColData, rowData))
+}
 
-	data := prepareOutputData(headers, firstColData, rowData)
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(data)
+// WriteJSONL writes JSON Lines data to a file
+func WriteJSONL(outputFile string, headers []string, firstColData []string, rowData map[string]map[string]string) error {
+	return WriteRows(outputFile, FormatJSONL, headers, BuildRows(headers, firstColData, rowData))
 }
 
 // WriteTSV writes TSV data to a file
 func WriteTSV(outputFile string, headers []string, firstColData []string, rowData map[string]map[string]string) error {
-	file, err := os.Create(outputFile)
-	if err != nil {
-		return fmt.Errorf("error creating output file: %v", err)
-	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	writer.Comma = '\t'
-	defer writer.Flush()
-
-	writer.Write(headers)
-
-	for _, inputValue := range firstColData {
-		row := make([]string, len(headers))
-		row[0] = inputValue
-		for j := 1; j < len(headers); j++ {
-			row[j] = rowData[inputValue][headers[j]]
-		}
-		writer.Write(row)
-	}
-
-	return nil
+	return WriteRows(outputFile, FormatTSV, headers, BuildRows(headers, firstColData, rowData))
 }
 
 // WriteYAML writes YAML data to a file
 func WriteYAML(outputFile string, headers []string, firstColData []string, rowData map[string]map[string]string) error {
-	file, err := os.Create(outputFile)
-	if err != nil {
-		return fmt.Errorf("error creating output file: %v", err)
-	}
-	defer file.Close()
-
-	data := prepareOutputData(headers, firstColData, rowData)
-	encoder := yaml.NewEncoder(file)
-	return encoder.Encode(data)
+	return WriteRows(outputFile, FormatYAML, headers, BuildRows(headers, firstColData, rowData))
 }
 
+// WriteParquet writes Parquet data to a file
+func WriteParquet(outputFile string, headers []string, firstColData []string, rowData map[string]map[string]string) error {
+	return WriteRows(outputFile, FormatParquet, headers, BuildRows(headers, firstColData, rowData))
+}
 
-// prepareOutputData prepares the data for JSON and YAML output
-func prepareOutputData(headers []string, firstColData []string, rowData map[string]map[string]string) []map[string]string {
-	var data []map[string]string
-	for _, inputValue := range firstColData {
-		row := make(map[string]string)
-		row[headers[0]] = inputValue
-		for j := 1; j < len(headers); j++ {
-			row[headers[j]] = rowData[inputValue][headers[j]]
+// rowsToMaps prepares the data for JSON and YAML output
+func rowsToMaps(headers []string, rows [][]string) []map[string]string {
+	data := make([]map[string]string, 0, len(rows))
+	for _, row := range rows {
+		item := make(map[string]string, len(headers))
+		for j, header := range headers {
+			if j < len(row) {
+				item[header] = row[j]
+			} else {
+				item[header] = ""
+			}
 		}
-		data = append(data, row)
+		data = append(data, item)
 	}
 	return data
 }